@@ -95,6 +95,11 @@ func main() {
 		HealthProbeBindAddress: fmt.Sprintf(":%d", opts.HealthProbePort),
 	})
 	cloudProvider := registry.NewCloudProvider(ctx, cloudprovider.Options{ClientSet: clientSet, KubeClient: manager.GetClient()})
+	if preloader, ok := cloudProvider.(cloudprovider.Preloader); ok {
+		if err := manager.AddReadyzCheck("preloaded", controllers.Preloaded(preloader)); err != nil {
+			panic(fmt.Sprintf("Failed to add preload probe, %s", err))
+		}
+	}
 	cloudProvider = cloudprovidermetrics.Decorate(cloudProvider)
 
 	cfg, err := config.New(ctx, clientSet, cmw)
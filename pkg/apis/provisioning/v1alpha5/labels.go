@@ -26,6 +26,7 @@ var (
 	ArchitectureAmd64    = "amd64"
 	ArchitectureArm64    = "arm64"
 	OperatingSystemLinux = "linux"
+	OperatingSystemMacOS = "macos"
 
 	// Karpenter specific domains and labels
 	KarpenterLabelDomain = "karpenter.sh"
@@ -35,7 +35,7 @@ type AL2 struct {
 // SSMAlias returns the AMI Alias to query SSM
 func (a AL2) SSMAlias(version string, instanceType cloudprovider.InstanceType) string {
 	amiSuffix := ""
-	if !resources.IsZero(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]) || !resources.IsZero(instanceType.Resources()[v1alpha1.ResourceAWSNeuron]) {
+	if v1alpha1.HasNVIDIAGPUs(instanceType.Resources()) || !resources.IsZero(instanceType.Resources()[v1alpha1.ResourceAWSNeuron]) {
 		amiSuffix = "-gpu"
 	} else if instanceType.Requirements().Get(v1.LabelArchStable).Has(v1alpha5.ArchitectureArm64) {
 		amiSuffix = fmt.Sprintf("-%s", v1alpha5.ArchitectureArm64)
@@ -55,13 +55,13 @@ func (a AL2) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.
 	return bootstrap.EKS{
 		ContainerRuntime: *containerRuntime,
 		Options: bootstrap.Options{
-			ClusterName:             a.Options.ClusterName,
-			ClusterEndpoint:         a.Options.ClusterEndpoint,
-			AWSENILimitedPodDensity: a.Options.AWSENILimitedPodDensity,
-			KubeletConfig:           kubeletConfig,
-			Taints:                  taints,
-			Labels:                  labels,
-			CABundle:                caBundle,
+			ClusterName:     a.Options.ClusterName,
+			ClusterEndpoint: a.Options.ClusterEndpoint,
+			MaxPods:         kubeletMaxPods(instanceTypes),
+			KubeletConfig:   kubeletConfig,
+			Taints:          taints,
+			Labels:          labels,
+			CABundle:        caBundle,
 		},
 	}
 }
@@ -22,15 +22,15 @@ import (
 
 // Options is the node bootstrapping parameters passed from Karpenter to the provisioning node
 type Options struct {
-	ClusterName             string
-	ClusterEndpoint         string
-	KubeletConfig           *v1alpha5.KubeletConfiguration
-	Taints                  []core.Taint      `hash:"set"`
-	Labels                  map[string]string `hash:"set"`
-	CABundle                *string
-	AWSENILimitedPodDensity bool
-	ContainerRuntime        *string
-	CustomUserData          *string
+	ClusterName      string
+	ClusterEndpoint  string
+	KubeletConfig    *v1alpha5.KubeletConfiguration
+	Taints           []core.Taint      `hash:"set"`
+	Labels           map[string]string `hash:"set"`
+	CABundle         *string
+	MaxPods          *int32
+	ContainerRuntime *string
+	CustomUserData   *string
 }
 
 // Bootstrapper can be implemented to generate a bootstrap script
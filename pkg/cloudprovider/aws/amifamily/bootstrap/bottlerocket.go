@@ -41,8 +41,8 @@ func (b Bottlerocket) Script() (string, error) {
 	if b.KubeletConfig != nil && len(b.KubeletConfig.ClusterDNS) > 0 {
 		s.Settings.Kubernetes.ClusterDNSIP = &b.KubeletConfig.ClusterDNS[0]
 	}
-	if !b.AWSENILimitedPodDensity {
-		s.Settings.Kubernetes.MaxPods = aws.Int(110)
+	if b.MaxPods != nil {
+		s.Settings.Kubernetes.MaxPods = aws.Int(int(*b.MaxPods))
 	}
 	s.Settings.Kubernetes.NodeTaints = map[string][]string{}
 	for _, taint := range b.Taints {
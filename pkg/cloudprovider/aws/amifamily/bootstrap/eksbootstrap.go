@@ -42,9 +42,9 @@ func (e EKS) Script() (string, error) {
 
 	kubeletExtraArgs := strings.Join([]string{e.nodeLabelArg(), e.nodeTaintArg()}, " ")
 
-	if !e.AWSENILimitedPodDensity {
+	if e.MaxPods != nil {
 		userData.WriteString(" \\\n--use-max-pods false")
-		kubeletExtraArgs += " --max-pods=110"
+		kubeletExtraArgs += fmt.Sprintf(" --max-pods=%d", *e.MaxPods)
 	}
 	if e.ContainerRuntime != "" {
 		userData.WriteString(fmt.Sprintf(" \\\n--container-runtime %s", e.ContainerRuntime))
@@ -0,0 +1,31 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"encoding/base64"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// MacOS passes custom user data through unchanged. There's no EKS bootstrap script for macOS, so joining the cluster is
+// left to the custom user data, which ec2-macos-init runs at launch.
+type MacOS struct {
+	Options
+}
+
+func (m MacOS) Script() (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(aws.StringValue(m.CustomUserData))), nil
+}
@@ -17,8 +17,6 @@ package amifamily
 import (
 	"fmt"
 
-	"github.com/aws/karpenter/pkg/utils/resources"
-
 	"github.com/aws/aws-sdk-go/aws"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -37,7 +35,7 @@ type Bottlerocket struct {
 func (b Bottlerocket) SSMAlias(version string, instanceType cloudprovider.InstanceType) string {
 	arch := "x86_64"
 	amiSuffix := ""
-	if !resources.IsZero(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]) {
+	if v1alpha1.HasNVIDIAGPUs(instanceType.Resources()) {
 		amiSuffix = "-nvidia"
 	}
 	if instanceType.Requirements().Get(v1.LabelArchStable).Has(v1alpha5.ArchitectureArm64) {
@@ -47,17 +45,17 @@ func (b Bottlerocket) SSMAlias(version string, instanceType cloudprovider.Instan
 }
 
 // UserData returns the default userdata script for the AMI Family
-func (b Bottlerocket) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, _ []cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
+func (b Bottlerocket) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, instanceTypes []cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
 	return bootstrap.Bottlerocket{
 		Options: bootstrap.Options{
-			ClusterName:             b.Options.ClusterName,
-			ClusterEndpoint:         b.Options.ClusterEndpoint,
-			AWSENILimitedPodDensity: b.Options.AWSENILimitedPodDensity,
-			KubeletConfig:           kubeletConfig,
-			Taints:                  taints,
-			Labels:                  labels,
-			CABundle:                caBundle,
-			CustomUserData:          customUserData,
+			ClusterName:     b.Options.ClusterName,
+			ClusterEndpoint: b.Options.ClusterEndpoint,
+			MaxPods:         kubeletMaxPods(instanceTypes),
+			KubeletConfig:   kubeletConfig,
+			Taints:          taints,
+			Labels:          labels,
+			CABundle:        caBundle,
+			CustomUserData:  customUserData,
 		},
 	}
 }
@@ -78,6 +76,7 @@ func (b Bottlerocket) DefaultBlockDeviceMappings() []*v1alpha1.BlockDeviceMappin
 	}
 }
 
+// EphemeralBlockDevice is Bottlerocket's data volume, as its OS volume only holds the read only root filesystem
 func (b Bottlerocket) EphemeralBlockDevice() *string {
 	return aws.String("/dev/xvdb")
 }
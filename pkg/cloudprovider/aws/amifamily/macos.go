@@ -0,0 +1,79 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amifamily
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/aws/karpenter/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/amifamily/bootstrap"
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+)
+
+// MacOSRelease is the macOS release that AMIs are resolved for
+const MacOSRelease = "sonoma"
+
+type MacOS struct {
+	*Options
+}
+
+// SSMAlias returns the AMI Alias to query SSM. macOS AMIs aren't built per Kubernetes version.
+func (m MacOS) SSMAlias(_ string, instanceType cloudprovider.InstanceType) string {
+	arch := "x86_64_mac"
+	if instanceType.Requirements().Get(v1.LabelArchStable).Has(v1alpha5.ArchitectureArm64) {
+		arch = "arm64_mac"
+	}
+	return fmt.Sprintf("/aws/service/ec2-macos/%s/%s/latest/image_id", MacOSRelease, arch)
+}
+
+// UserData returns the custom user data, which is responsible for joining the node to the cluster
+func (m MacOS) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, _ []cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
+	return bootstrap.MacOS{
+		Options: bootstrap.Options{
+			ClusterName:     m.Options.ClusterName,
+			ClusterEndpoint: m.Options.ClusterEndpoint,
+			KubeletConfig:   kubeletConfig,
+			Taints:          taints,
+			Labels:          labels,
+			CABundle:        caBundle,
+			CustomUserData:  customUserData,
+		},
+	}
+}
+
+// DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family. macOS AMIs are much larger
+// than Linux AMIs, so the root volume must be too.
+func (m MacOS) DefaultBlockDeviceMappings() []*v1alpha1.BlockDeviceMapping {
+	ebs := DefaultEBS
+	ebs.VolumeSize = resource.NewScaledQuantity(200, resource.Giga)
+	return []*v1alpha1.BlockDeviceMapping{{
+		DeviceName: m.EphemeralBlockDevice(),
+		EBS:        &ebs,
+	}}
+}
+
+func (m MacOS) EphemeralBlockDevice() *string {
+	return aws.String("/dev/sda1")
+}
+
+// EphemeralBlockDeviceOverhead accounts for the macOS system, which shares the root volume with ephemeral storage
+func (m MacOS) EphemeralBlockDeviceOverhead() resource.Quantity {
+	return resource.MustParse("60Gi")
+}
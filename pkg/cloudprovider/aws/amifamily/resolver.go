@@ -45,11 +45,11 @@ type Resolver struct {
 
 // Options define the static launch template parameters
 type Options struct {
-	ClusterName             string
-	ClusterEndpoint         string
-	AWSENILimitedPodDensity bool
-	InstanceProfile         string
-	CABundle                *string `hash:"ignore"`
+	ClusterName           string
+	ClusterEndpoint       string
+	InstanceProfile       string
+	CapacityReservationID string
+	CABundle              *string `hash:"ignore"`
 	// Level-triggered fields that may change out of sync.
 	KubernetesVersion string
 	SecurityGroupsIDs []string
@@ -67,12 +67,21 @@ type LaunchTemplate struct {
 	InstanceTypes       []cloudprovider.InstanceType `hash:"ignore"`
 }
 
+// MaxPodsConfigurer can be implemented by instance types whose pod density kubelet is configured with, as the AMI's
+// bootstrap doesn't compute it on its own. Instance types are only launched from the same launch template if they're
+// configured with the same max pods.
+type MaxPodsConfigurer interface {
+	KubeletMaxPods() *int32
+}
+
 // AMIFamily can be implemented to override the default logic for generating dynamic launch template parameters
 type AMIFamily interface {
 	UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []core.Taint, labels map[string]string, caBundle *string, instanceTypes []cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper
 	SSMAlias(version string, instanceType cloudprovider.InstanceType) string
 	DefaultBlockDeviceMappings() []*v1alpha1.BlockDeviceMapping
 	DefaultMetadataOptions() *v1alpha1.MetadataOptions
+	// EphemeralBlockDevice is the device whose volume holds container ephemeral storage, which is the root volume of
+	// most AMI families, but the data volume of AMI families that split the OS and data volumes, such as Bottlerocket
 	EphemeralBlockDevice() *string
 	EphemeralBlockDeviceOverhead() resource.Quantity
 }
@@ -96,26 +105,36 @@ func (r Resolver) Resolve(ctx context.Context, provider *v1alpha1.AWS, nodeReque
 		return nil, err
 	}
 	amiFamily := GetAMIFamily(provider.AMIFamily, options)
-	amiIDs := map[string][]cloudprovider.InstanceType{}
+	// Instance types share a launch template if they use the same AMI and kubelet is configured with the same max pods,
+	// where a max pods of -1 is left to the AMI's bootstrap
+	type launchTemplateKey struct {
+		amiID   string
+		maxPods int32
+	}
+	groups := map[launchTemplateKey][]cloudprovider.InstanceType{}
 	for _, instanceType := range nodeRequest.InstanceTypeOptions {
 		amiID, err := r.amiProvider.Get(ctx, instanceType, amiFamily.SSMAlias(options.KubernetesVersion, instanceType))
 		if err != nil {
 			return nil, err
 		}
-		amiIDs[amiID] = append(amiIDs[amiID], instanceType)
+		key := launchTemplateKey{amiID: amiID, maxPods: -1}
+		if maxPods := kubeletMaxPods([]cloudprovider.InstanceType{instanceType}); maxPods != nil {
+			key.maxPods = *maxPods
+		}
+		groups[key] = append(groups[key], instanceType)
 	}
 	var resolvedTemplates []*LaunchTemplate
-	for amiID, instanceTypes := range amiIDs {
+	for key, instanceTypes := range groups {
 		resolved := &LaunchTemplate{
 			Options:             options,
 			UserData:            amiFamily.UserData(nodeRequest.Template.KubeletConfiguration, nodeRequest.Template.Taints, options.Labels, options.CABundle, instanceTypes, aws.String(userDataString)),
 			BlockDeviceMappings: provider.BlockDeviceMappings,
 			MetadataOptions:     provider.MetadataOptions,
-			AMIID:               amiID,
+			AMIID:               key.amiID,
 			InstanceTypes:       instanceTypes,
 		}
 		if resolved.BlockDeviceMappings == nil {
-			resolved.BlockDeviceMappings = amiFamily.DefaultBlockDeviceMappings()
+			resolved.BlockDeviceMappings = defaultBlockDeviceMappings(amiFamily, provider)
 		}
 		if resolved.MetadataOptions == nil {
 			resolved.MetadataOptions = amiFamily.DefaultMetadataOptions()
@@ -125,12 +144,55 @@ func (r Resolver) Resolve(ctx context.Context, provider *v1alpha1.AWS, nodeReque
 	return resolvedTemplates, nil
 }
 
+// kubeletMaxPods is the max pods that kubelet is configured with for instance types launched from the same launch
+// template, or nil if it's left to the AMI's bootstrap
+func kubeletMaxPods(instanceTypes []cloudprovider.InstanceType) *int32 {
+	if len(instanceTypes) == 0 {
+		return nil
+	}
+	if configurer, ok := instanceTypes[0].(MaxPodsConfigurer); ok {
+		return configurer.KubeletMaxPods()
+	}
+	return nil
+}
+
+// DefaultVolumeSize is the size of the ephemeral volume in the AMI family's default block device mappings, falling back
+// to the size of DefaultEBS
+func DefaultVolumeSize(amiFamily AMIFamily) resource.Quantity {
+	for _, blockDevice := range amiFamily.DefaultBlockDeviceMappings() {
+		if aws.StringValue(blockDevice.DeviceName) == aws.StringValue(amiFamily.EphemeralBlockDevice()) && blockDevice.EBS != nil && blockDevice.EBS.VolumeSize != nil {
+			return *blockDevice.EBS.VolumeSize
+		}
+	}
+	return *DefaultEBS.VolumeSize
+}
+
+// defaultBlockDeviceMappings are the AMI family's default block device mappings, with the ephemeral volume resized to
+// the provider's default volume size
+func defaultBlockDeviceMappings(amiFamily AMIFamily, provider *v1alpha1.AWS) []*v1alpha1.BlockDeviceMapping {
+	blockDeviceMappings := amiFamily.DefaultBlockDeviceMappings()
+	if provider.DefaultVolumeSize == nil {
+		return blockDeviceMappings
+	}
+	for _, blockDevice := range blockDeviceMappings {
+		if aws.StringValue(blockDevice.DeviceName) == aws.StringValue(amiFamily.EphemeralBlockDevice()) && blockDevice.EBS != nil {
+			// Default mappings may share DefaultEBS, so resize a copy
+			ebs := *blockDevice.EBS
+			ebs.VolumeSize = provider.DefaultVolumeSize
+			blockDevice.EBS = &ebs
+		}
+	}
+	return blockDeviceMappings
+}
+
 func GetAMIFamily(amiFamily *string, options *Options) AMIFamily {
 	switch aws.StringValue(amiFamily) {
 	case v1alpha1.AMIFamilyBottlerocket:
 		return &Bottlerocket{Options: options}
 	case v1alpha1.AMIFamilyUbuntu:
 		return &Ubuntu{Options: options}
+	case v1alpha1.AMIFamilyMacOS:
+		return &MacOS{Options: options}
 	default:
 		return &AL2{Options: options}
 	}
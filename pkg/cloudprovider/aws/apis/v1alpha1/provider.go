@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -45,6 +46,166 @@ type AWS struct {
 	// Tags to be applied on ec2 resources like instances and launch templates.
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
+	// HugePages is the amount of memory reserved for huge pages on provisioned nodes, keyed by page size resource
+	// (hugepages-2Mi or hugepages-1Gi). Huge pages must be preallocated at boot (e.g. through kernel arguments in
+	// userData), since EC2 doesn't report them. Reserved huge pages are not available as regular memory.
+	// +optional
+	HugePages v1.ResourceList `json:"hugePages,omitempty"`
+	// ExtraResources are added to the capacity of every provisioned node, modeling resources advertised by generic
+	// device plugins (e.g. squat.ai/fuse). Defaults to a smarter-devices/fuse device for each pod that fits on the
+	// instance type. Resources with a quantity of zero aren't advertised, so the default can be disabled with
+	// {"smarter-devices/fuse": 0}.
+	// +optional
+	ExtraResources v1.ResourceList `json:"extraResources,omitempty"`
+	// ExcludedInstanceFamilies are instance families (e.g. p2) that will never be launched.
+	// +optional
+	ExcludedInstanceFamilies []string `json:"excludedInstanceFamilies,omitempty"`
+	// InstanceFamilyPatterns restrict the instance families that will be launched to those matching one of the glob
+	// patterns, e.g. c7* for c7g and c7i. Patterns use the syntax of path.Match.
+	// +optional
+	InstanceFamilyPatterns []string `json:"instanceFamilyPatterns,omitempty"`
+	// ArchitectureMappings maps processor architectures reported by EC2 to kubernetes architectures, so that instance
+	// types with architectures that aren't yet recognized can be launched, e.g. {"riscv64": "riscv64"}. The built-in
+	// mappings, e.g. for x86_64 and arm64, can't be overridden.
+	// +optional
+	ArchitectureMappings map[string]string `json:"architectureMappings,omitempty"`
+	// ExcludeMetal prevents bare metal instance types from being launched.
+	// +optional
+	ExcludeMetal *bool `json:"excludeMetal,omitempty"`
+	// GPUReplicaFactor is the number of nvidia.com/gpu replicas advertised for each physical NVIDIA GPU. Set this to
+	// match the NVIDIA device plugin's time-slicing replicas. Defaults to 1.
+	// +optional
+	GPUReplicaFactor *int64 `json:"gpuReplicaFactor,omitempty"`
+	// MinAllocatableEphemeralStorage is the least ephemeral storage left allocatable to pods. The estimated system
+	// overhead is reduced on small volumes so that at least this much remains.
+	// +optional
+	MinAllocatableEphemeralStorage *resource.Quantity `json:"minAllocatableEphemeralStorage,omitempty"`
+	// ImageGCReservationPercentage is the percentage of the ephemeral storage reserved for the image cache, which kubelet
+	// only garbage collects above its imageGCHighThresholdPercent. Set this to 100 minus the kubelet's
+	// imageGCHighThresholdPercent. Defaults to 15.
+	// +optional
+	ImageGCReservationPercentage *int64 `json:"imageGCReservationPercentage,omitempty"`
+	// EnableGPUMemoryResource advertises the total GPU memory of each instance type, in MiB, as the
+	// karpenter.k8s.aws/gpu-memory extended resource. Stock device plugins don't advertise this resource, so it must be
+	// provided on the node by a custom device plugin or scheduler extender.
+	// +optional
+	EnableGPUMemoryResource *bool `json:"enableGPUMemoryResource,omitempty"`
+	// EnableLocalStorageResource advertises the total size of each instance type's instance store volumes as the
+	// karpenter.k8s.aws/local-storage extended resource, so that pods can request raw local disk rather than
+	// ephemeral-storage. It must be provided on the node by a custom device plugin, e.g. a local volume provisioner.
+	// +optional
+	EnableLocalStorageResource *bool `json:"enableLocalStorageResource,omitempty"`
+	// MIGProfiles partition the NVIDIA GPUs of the given instance families into MIG devices of a single profile, e.g.
+	// {"p4d": "1g.5gb"}, matching the NVIDIA device plugin's mixed strategy. GPUs of these families are advertised as
+	// nvidia.com/mig-<profile> devices instead of nvidia.com/gpu.
+	// +optional
+	MIGProfiles map[string]string `json:"migProfiles,omitempty"`
+	// GPUReservedMemory overrides the host memory that the driver of each GPU reserves, keyed by the lower kabob cased
+	// GPU name (e.g. t4). Reserved memory is deducted from the memory available to pods.
+	// +optional
+	GPUReservedMemory map[string]resource.Quantity `json:"gpuReservedMemory,omitempty"`
+	// SpotDiscountPercentage discounts the price of spot offerings whose spot price isn't known relative to on-demand
+	// offerings of the same instance type, so that spot offerings rank as cheaper. Defaults to 0.
+	// +optional
+	SpotDiscountPercentage *int64 `json:"spotDiscountPercentage,omitempty"`
+	// InstanceFamilyPricePercentages scales the price of instance families to the given percentage, e.g. to reflect the
+	// effective price of families covered by reserved instances or savings plans. Families that aren't listed are
+	// priced at 100%.
+	// +optional
+	InstanceFamilyPricePercentages map[string]int64 `json:"instanceFamilyPricePercentages,omitempty"`
+	// MaxPrice is the highest price of an offering that will be launched, compared against the price that instance
+	// types are ranked by. Offerings are priced per capacity type, so e.g. a spot offering may be launched when the
+	// on-demand offering of the same instance type is too expensive.
+	// +optional
+	MaxPrice *float64 `json:"maxPrice,omitempty"`
+	// MaxPricePerPod is the highest price, per pod that fits on the instance type, of instance types that will be
+	// launched, e.g. to only launch dense instance types. Instance types are priced the same way as for MaxPrice.
+	// +optional
+	MaxPricePerPod *float64 `json:"maxPricePerPod,omitempty"`
+	// ScoringProfile selects the profile that instance types are ranked by, e.g. to weight memory for batch workloads
+	// and clock speed for latency sensitive workloads. Profiles are registered with the controller, and providers that
+	// select an unknown profile are ranked by the default scorer.
+	// +optional
+	ScoringProfile *string `json:"scoringProfile,omitempty"`
+	// MinMemoryPerVCPU is the least memory, in GiB, that instance types must have for each vCPU, e.g. to only launch
+	// memory optimized instance types without listing their families. Memory is estimated after the VM overhead.
+	// +optional
+	MinMemoryPerVCPU *float64 `json:"minMemoryPerVCPU,omitempty"`
+	// MinVCPU is the least number of vCPUs that instance types must have, e.g. to avoid tiny nodes dominated by their
+	// overhead. Burstable instance types are compared by their baseline vCPUs if a baseline is configured.
+	// +optional
+	MinVCPU *int64 `json:"minVCPU,omitempty"`
+	// MinGPUs is the least number of NVIDIA and AMD GPUs that instance types must have, e.g. to keep distributed
+	// training off of single GPU instance types. GPUs are counted before time-slicing or MIG partitioning, and instance
+	// types without GPUs are excluded.
+	// +optional
+	MinGPUs *int64 `json:"minGPUs,omitempty"`
+	// MaxPodsPerInstanceType overrides the maximum number of pods for the given instance types, taking precedence over
+	// both the ENI limited and the cluster wide pod density.
+	// +optional
+	MaxPodsPerInstanceType map[string]int32 `json:"maxPodsPerInstanceType,omitempty"`
+	// PodPIDsLimit is the number of process IDs reserved for each pod, matching kubelet's --pod-max-pids. Pod density is
+	// capped so that every pod's reservation fits within the kernel's default pid_max for the instance type.
+	// +optional
+	PodPIDsLimit *int64 `json:"podPidsLimit,omitempty"`
+	// ContainerdDataDevice is the name of the block device mapping whose volume holds containerd's data directory, for
+	// AMIs that mount it separately from the root volume. Ephemeral storage is computed from this volume when set.
+	// +optional
+	ContainerdDataDevice *string `json:"containerdDataDevice,omitempty"`
+	// InstanceStorePolicy determines how local instance store volumes are used. With RAID0, the NVMe instance store
+	// volumes are assumed to be combined into a RAID0 array that backs ephemeral storage, e.g. by custom user data.
+	// +optional
+	InstanceStorePolicy *string `json:"instanceStorePolicy,omitempty"`
+	// Swap is the size of swap space configured on the NVMe instance store of provisioned nodes (e.g. by custom user
+	// data) for the NodeSwap feature. Instance types without enough NVMe instance store are excluded, and swap is
+	// deducted from ephemeral storage when the instance store also backs ephemeral storage.
+	// +optional
+	Swap *resource.Quantity `json:"swap,omitempty"`
+	// ExcludedZones are zones that instances will never be launched in, even if the provider has subnets in them.
+	// +optional
+	ExcludedZones []string `json:"excludedZones,omitempty"`
+	// ZoneTypes restrict the zones that instances will be launched in to availability-zone, local-zone, or
+	// wavelength-zone. Defaults to every zone type that the provider has subnets in.
+	// +optional
+	ZoneTypes []string `json:"zoneTypes,omitempty"`
+	// VirtualizationTypes restrict instance types to those that support at least one of hvm or paravirtual, e.g. to
+	// match the virtualization type of custom AMIs. Defaults to hvm.
+	// +optional
+	VirtualizationTypes []string `json:"virtualizationTypes,omitempty"`
+	// BurstableVCPUBaseline is the fraction of each vCPU that burstable instance types (e.g. t3) are counted as, such as
+	// 200m for a baseline of 20%. It's used when burstable instances run in standard mode, so that pods aren't scheduled
+	// against CPU that's only available while the instance has CPU credits.
+	// +optional
+	BurstableVCPUBaseline *resource.Quantity `json:"burstableVCPUBaseline,omitempty"`
+	// CustomNetworking indicates that VPC CNI custom networking is enabled, so the primary ENI is reserved for the node
+	// and pods are only assigned IPs from the remaining ENIs.
+	// +optional
+	CustomNetworking *bool `json:"customNetworking,omitempty"`
+	// IPFamily is the IP family of the cluster, ipv4 or ipv6, which determines how pod density is limited by ENIs.
+	// Defaults to ipv4.
+	// +optional
+	IPFamily *string `json:"ipFamily,omitempty"`
+	// DedicatedHosts indicates that instances are launched onto dedicated hosts, e.g. through the host placement of a
+	// launch template. Instance types that can only run on dedicated hosts are excluded unless this is set.
+	// +optional
+	DedicatedHosts *bool `json:"dedicatedHosts,omitempty"`
+	// CapacityReservationSelector discovers open On-Demand Capacity Reservations by tags, or by id with the aws-ids key.
+	// Instance types are offered in the selected reservations, which are preferred over on-demand capacity.
+	// +optional
+	CapacityReservationSelector map[string]string `json:"capacityReservationSelector,omitempty"`
+	// InstanceTypeOverrides replace the computed overhead and pod density of the given instance types, e.g. with
+	// reservations measured by the operator. Values that aren't set in an override are still computed.
+	// +optional
+	InstanceTypeOverrides map[string]InstanceTypeOverride `json:"instanceTypeOverrides,omitempty"`
+	// DaemonSetOverhead replaces the AMI family's baseline reservation for the system DaemonSets and host containers,
+	// e.g. kube-proxy and the CNI, that run on every node. Only cpu, memory, and ephemeral-storage may be reserved.
+	// +optional
+	DaemonSetOverhead v1.ResourceList `json:"daemonSetOverhead,omitempty"`
+	// ReservedCPUs is the number of whole vCPUs that are isolated for system daemons, e.g. with the kubelet's
+	// reservedSystemCPUs, and are added to the system-reserved CPU of every instance type. Instance types without any
+	// vCPUs left over are excluded.
+	// +optional
+	ReservedCPUs *int64 `json:"reservedCPUs,omitempty"`
 	// LaunchTemplate parameters to use when generating an LT
 	LaunchTemplate `json:",inline,omitempty"`
 }
@@ -74,6 +235,10 @@ type LaunchTemplate struct {
 	// BlockDeviceMappings to be applied to provisioned nodes.
 	// +optionals
 	BlockDeviceMappings []*BlockDeviceMapping `json:"blockDeviceMappings,omitempty"`
+	// DefaultVolumeSize replaces the size of the ephemeral volume in the AMI family's default block device mappings,
+	// which are used when no block device mappings are specified.
+	// +optional
+	DefaultVolumeSize *resource.Quantity `json:"defaultVolumeSize,omitempty"`
 }
 
 // MetadataOptions contains parameters for specifying the exposure of the
@@ -120,6 +285,22 @@ type MetadataOptions struct {
 	HTTPTokens *string `json:"httpTokens,omitempty"`
 }
 
+// InstanceTypeOverride replaces the computed overhead and pod density of an instance type
+type InstanceTypeOverride struct {
+	// CPU is the cpu overhead reserved for the system and kubelet.
+	// +optional
+	CPU *resource.Quantity `json:"cpu,omitempty"`
+	// Memory is the memory overhead reserved for the system and kubelet.
+	// +optional
+	Memory *resource.Quantity `json:"memory,omitempty"`
+	// EphemeralStorage is the ephemeral storage overhead reserved for the system and kubelet.
+	// +optional
+	EphemeralStorage *resource.Quantity `json:"ephemeralStorage,omitempty"`
+	// Pods is the maximum number of pods, taking precedence over every other pod density.
+	// +optional
+	Pods *int32 `json:"pods,omitempty"`
+}
+
 type BlockDeviceMapping struct {
 	// The device name (for example, /dev/sdh or xvdh).
 	DeviceName *string `json:"deviceName,omitempty"`
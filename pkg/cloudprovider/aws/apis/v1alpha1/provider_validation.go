@@ -16,10 +16,12 @@ package v1alpha1
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"knative.dev/pkg/apis"
 
@@ -28,13 +30,46 @@ import (
 )
 
 const (
-	launchTemplatePath          = "launchTemplate"
-	securityGroupSelectorPath   = "securityGroupSelector"
-	fieldPathSubnetSelectorPath = "subnetSelector"
-	amiFamilyPath               = "amiFamily"
-	metadataOptionsPath         = "metadataOptions"
-	instanceProfilePath         = "instanceProfile"
-	blockDeviceMappingsPath     = "blockDeviceMappings"
+	launchTemplatePath               = "launchTemplate"
+	securityGroupSelectorPath        = "securityGroupSelector"
+	fieldPathSubnetSelectorPath      = "subnetSelector"
+	amiFamilyPath                    = "amiFamily"
+	metadataOptionsPath              = "metadataOptions"
+	instanceProfilePath              = "instanceProfile"
+	blockDeviceMappingsPath          = "blockDeviceMappings"
+	containerdDataDevicePath         = "containerdDataDevice"
+	hugePagesPath                    = "hugePages"
+	extraResourcesPath               = "extraResources"
+	excludedInstanceFamiliesPath     = "excludedInstanceFamilies"
+	instanceFamilyPatternsPath       = "instanceFamilyPatterns"
+	architectureMappingsPath         = "architectureMappings"
+	gpuReplicaFactorPath             = "gpuReplicaFactor"
+	gpuReservedMemoryPath            = "gpuReservedMemory"
+	migProfilesPath                  = "migProfiles"
+	minAllocatableEphemeralPath      = "minAllocatableEphemeralStorage"
+	spotDiscountPercentagePath       = "spotDiscountPercentage"
+	familyPricePercentagesPath       = "instanceFamilyPricePercentages"
+	maxPricePath                     = "maxPrice"
+	maxPricePerPodPath               = "maxPricePerPod"
+	scoringProfilePath               = "scoringProfile"
+	minMemoryPerVCPUPath             = "minMemoryPerVCPU"
+	minVCPUPath                      = "minVCPU"
+	minGPUsPath                      = "minGPUs"
+	imageGCReservationPercentagePath = "imageGCReservationPercentage"
+	maxPodsPerInstanceTypePath       = "maxPodsPerInstanceType"
+	podPIDsLimitPath                 = "podPidsLimit"
+	instanceStorePolicyPath          = "instanceStorePolicy"
+	ipFamilyPath                     = "ipFamily"
+	swapPath                         = "swap"
+	excludedZonesPath                = "excludedZones"
+	zoneTypesPath                    = "zoneTypes"
+	virtualizationTypesPath          = "virtualizationTypes"
+	burstableVCPUBaselinePath        = "burstableVCPUBaseline"
+	instanceTypeOverridesPath        = "instanceTypeOverrides"
+	capacityReservationsPath         = "capacityReservationSelector"
+	daemonSetOverheadPath            = "daemonSetOverhead"
+	reservedCPUsPath                 = "reservedCPUs"
+	defaultVolumeSizePath            = "defaultVolumeSize"
 )
 
 var (
@@ -42,6 +77,8 @@ var (
 	maxVolumeSize      = *resource.NewScaledQuantity(64, resource.Tera)
 	subnetRegex        = regexp.MustCompile("subnet-[0-9a-z]+")
 	securityGroupRegex = regexp.MustCompile("sg-[0-9a-z]+")
+	reservationRegex   = regexp.MustCompile("cr-[0-9a-z]+")
+	migProfileRegex    = regexp.MustCompile(`^[1-7]g\.[0-9]+gb$`)
 )
 
 func (a *AWS) Validate(provisioner v1alpha5.Provisioner) (errs *apis.FieldError) {
@@ -66,6 +103,39 @@ func (a *AWS) validate() (errs *apis.FieldError) {
 		a.validateMetadataOptions(),
 		a.validateAMIFamily(),
 		a.validateBlockDeviceMappings(),
+		a.validateContainerdDataDevice(),
+		a.validateHugePages(),
+		a.validateExtraResources(),
+		a.validateExcludedInstanceFamilies(),
+		a.validateInstanceFamilyPatterns(),
+		a.validateArchitectureMappings(),
+		a.validateGPUReplicaFactor(),
+		a.validateGPUReservedMemory(),
+		a.validateMIGProfiles(),
+		a.validateMinAllocatableEphemeralStorage(),
+		a.validateSpotDiscountPercentage(),
+		a.validateInstanceFamilyPricePercentages(),
+		a.validateMaxPrice(),
+		a.validateMaxPricePerPod(),
+		a.validateScoringProfile(),
+		a.validateMinMemoryPerVCPU(),
+		a.validateMinVCPU(),
+		a.validateMinGPUs(),
+		a.validateImageGCReservationPercentage(),
+		a.validateMaxPodsPerInstanceType(),
+		a.validatePodPIDsLimit(),
+		a.validateInstanceStorePolicy(),
+		a.validateIPFamily(),
+		a.validateSwap(),
+		a.validateExcludedZones(),
+		a.validateZoneTypes(),
+		a.validateVirtualizationTypes(),
+		a.validateBurstableVCPUBaseline(),
+		a.validateInstanceTypeOverrides(),
+		a.validateCapacityReservations(),
+		a.validateDaemonSetOverhead(),
+		a.validateReservedCPUs(),
+		a.validateDefaultVolumeSize(),
 	)
 }
 
@@ -88,6 +158,9 @@ func (a *AWS) validateLaunchTemplate() (errs *apis.FieldError) {
 	if len(a.BlockDeviceMappings) != 0 {
 		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, blockDeviceMappingsPath))
 	}
+	if a.DefaultVolumeSize != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, defaultVolumeSizePath))
+	}
 	return errs
 }
 
@@ -209,14 +282,35 @@ func (a *AWS) validateStringEnum(value, field string, validValues []string) *api
 }
 
 func (a *AWS) validateBlockDeviceMappings() (errs *apis.FieldError) {
+	deviceNames := map[string]struct{}{}
 	for i, blockDeviceMapping := range a.BlockDeviceMappings {
 		if err := a.validateBlockDeviceMapping(blockDeviceMapping); err != nil {
 			errs = errs.Also(err.ViaFieldIndex(blockDeviceMappingsPath, i))
 		}
+		// Block device mappings are looked up by device name, so only the first of duplicates would take effect
+		if blockDeviceMapping.DeviceName == nil {
+			continue
+		}
+		if _, ok := deviceNames[*blockDeviceMapping.DeviceName]; ok {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s is the device name of another block device mapping", *blockDeviceMapping.DeviceName), "deviceName").ViaFieldIndex(blockDeviceMappingsPath, i))
+		}
+		deviceNames[*blockDeviceMapping.DeviceName] = struct{}{}
 	}
 	return errs
 }
 
+func (a *AWS) validateContainerdDataDevice() *apis.FieldError {
+	if a.ContainerdDataDevice == nil {
+		return nil
+	}
+	for _, blockDeviceMapping := range a.BlockDeviceMappings {
+		if blockDeviceMapping.DeviceName != nil && *blockDeviceMapping.DeviceName == *a.ContainerdDataDevice {
+			return nil
+		}
+	}
+	return apis.ErrInvalidValue(*a.ContainerdDataDevice, containerdDataDevicePath, "must be the device name of a block device mapping")
+}
+
 func (a *AWS) validateBlockDeviceMapping(blockDeviceMapping *BlockDeviceMapping) (errs *apis.FieldError) {
 	return errs.Also(a.validateDeviceName(blockDeviceMapping), a.validateEBS(blockDeviceMapping))
 }
@@ -256,12 +350,315 @@ func (a *AWS) validateVolumeSize(blockDeviceMapping *BlockDeviceMapping) *apis.F
 		return nil
 	} else if blockDeviceMapping.EBS.VolumeSize == nil {
 		return apis.ErrMissingField("volumeSize")
+	} else if blockDeviceMapping.EBS.VolumeSize.Sign() <= 0 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%s must be positive", blockDeviceMapping.EBS.VolumeSize.String()), "volumeSize")
 	} else if blockDeviceMapping.EBS.VolumeSize.Cmp(minVolumeSize) == -1 || blockDeviceMapping.EBS.VolumeSize.Cmp(maxVolumeSize) == 1 {
 		return apis.ErrOutOfBoundsValue(blockDeviceMapping.EBS.VolumeSize.String(), minVolumeSize.String(), maxVolumeSize.String(), "volumeSize")
 	}
 	return nil
 }
 
+// validateHugePages checks that each reservation is a whole number of pages of a supported size. Whether the reservation
+// fits in memory depends on the instance type, so instance types too small to hold it are excluded at launch instead.
+func (a *AWS) validateHugePages() (errs *apis.FieldError) {
+	for name, quantity := range a.HugePages {
+		pageSize, ok := SupportedHugePageSizes[name]
+		if !ok {
+			errs = errs.Also(apis.ErrInvalidKeyName(string(name), hugePagesPath, fmt.Sprintf("must be one of %s, %s", ResourceHugePages2Mi, ResourceHugePages1Gi)))
+			continue
+		}
+		if quantity.Sign() < 0 || quantity.Value()%pageSize.Value() != 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s must be a non-negative multiple of %s", quantity.String(), pageSize.String()), fmt.Sprintf("%s['%s']", hugePagesPath, name)))
+		}
+	}
+	return errs
+}
+
+// validateExtraResources checks that extra resources are non-negative and don't collide with the resources that
+// Karpenter computes for each instance type
+func (a *AWS) validateExtraResources() (errs *apis.FieldError) {
+	for name, quantity := range a.ExtraResources {
+		if ComputedResources.Has(string(name)) || strings.HasPrefix(string(name), v1.ResourceHugePagesPrefix) || strings.HasPrefix(string(name), ResourceNVIDIAMIGPrefix) {
+			errs = errs.Also(apis.ErrInvalidKeyName(string(name), extraResourcesPath, "is computed by karpenter"))
+			continue
+		}
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s must be non-negative", quantity.String()), fmt.Sprintf("%s['%s']", extraResourcesPath, name)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateExcludedInstanceFamilies() (errs *apis.FieldError) {
+	for i, family := range a.ExcludedInstanceFamilies {
+		if family == "" || strings.Contains(family, ".") {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%q is not an instance family", family), excludedInstanceFamiliesPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateInstanceFamilyPatterns() (errs *apis.FieldError) {
+	for i, pattern := range a.InstanceFamilyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" || strings.Contains(pattern, ".") {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%q is not an instance family pattern", pattern), instanceFamilyPatternsPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateArchitectureMappings() (errs *apis.FieldError) {
+	for architecture, kubeArchitecture := range a.ArchitectureMappings {
+		if architecture == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(architecture, architectureMappingsPath, "must be an architecture"))
+		}
+		if _, ok := AWSToKubeArchitectures[architecture]; ok {
+			errs = errs.Also(apis.ErrInvalidKeyName(architecture, architectureMappingsPath, "must not override a built-in architecture"))
+		}
+		if kubeArchitecture == "" {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must not be empty", kubeArchitecture), fmt.Sprintf("%s['%s']", architectureMappingsPath, architecture)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateGPUReplicaFactor() *apis.FieldError {
+	if a.GPUReplicaFactor == nil {
+		return nil
+	}
+	if *a.GPUReplicaFactor < 1 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%d must be at least 1", *a.GPUReplicaFactor), gpuReplicaFactorPath)
+	}
+	return nil
+}
+
+func (a *AWS) validateMIGProfiles() (errs *apis.FieldError) {
+	for family, profile := range a.MIGProfiles {
+		if family == "" || strings.Contains(family, ".") {
+			errs = errs.Also(apis.ErrInvalidKeyName(family, migProfilesPath, "must be an instance family"))
+		}
+		if !migProfileRegex.MatchString(profile) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be a MIG profile, e.g. 1g.5gb", profile), fmt.Sprintf("%s['%s']", migProfilesPath, family)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateMinAllocatableEphemeralStorage() *apis.FieldError {
+	if a.MinAllocatableEphemeralStorage == nil {
+		return nil
+	}
+	if a.MinAllocatableEphemeralStorage.Sign() < 0 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%s must not be negative", a.MinAllocatableEphemeralStorage.String()), minAllocatableEphemeralPath)
+	}
+	return nil
+}
+
+func (a *AWS) validateSpotDiscountPercentage() *apis.FieldError {
+	if a.SpotDiscountPercentage == nil {
+		return nil
+	}
+	if discount := *a.SpotDiscountPercentage; discount < 0 || discount > 99 {
+		return apis.ErrOutOfBoundsValue(discount, 0, 99, spotDiscountPercentagePath)
+	}
+	return nil
+}
+
+func (a *AWS) validateMaxPrice() *apis.FieldError {
+	if a.MaxPrice == nil {
+		return nil
+	}
+	if *a.MaxPrice <= 0 {
+		return apis.ErrInvalidValue(*a.MaxPrice, maxPricePath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateMaxPricePerPod() *apis.FieldError {
+	if a.MaxPricePerPod == nil {
+		return nil
+	}
+	if *a.MaxPricePerPod <= 0 {
+		return apis.ErrInvalidValue(*a.MaxPricePerPod, maxPricePerPodPath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateScoringProfile() *apis.FieldError {
+	if a.ScoringProfile == nil {
+		return nil
+	}
+	if *a.ScoringProfile == "" {
+		return apis.ErrInvalidValue(*a.ScoringProfile, scoringProfilePath, "must not be empty")
+	}
+	return nil
+}
+
+func (a *AWS) validateMinMemoryPerVCPU() *apis.FieldError {
+	if a.MinMemoryPerVCPU == nil {
+		return nil
+	}
+	if *a.MinMemoryPerVCPU <= 0 {
+		return apis.ErrInvalidValue(*a.MinMemoryPerVCPU, minMemoryPerVCPUPath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateMinVCPU() *apis.FieldError {
+	if a.MinVCPU == nil {
+		return nil
+	}
+	if *a.MinVCPU <= 0 {
+		return apis.ErrInvalidValue(*a.MinVCPU, minVCPUPath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateMinGPUs() *apis.FieldError {
+	if a.MinGPUs == nil {
+		return nil
+	}
+	if *a.MinGPUs <= 0 {
+		return apis.ErrInvalidValue(*a.MinGPUs, minGPUsPath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateImageGCReservationPercentage() *apis.FieldError {
+	if a.ImageGCReservationPercentage == nil {
+		return nil
+	}
+	if percentage := *a.ImageGCReservationPercentage; percentage < 0 || percentage > 99 {
+		return apis.ErrOutOfBoundsValue(percentage, 0, 99, imageGCReservationPercentagePath)
+	}
+	return nil
+}
+
+func (a *AWS) validateGPUReservedMemory() (errs *apis.FieldError) {
+	for name, quantity := range a.GPUReservedMemory {
+		if name == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(name, gpuReservedMemoryPath, "must be a GPU name"))
+		}
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s must be non-negative", quantity.String()), fmt.Sprintf("%s['%s']", gpuReservedMemoryPath, name)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateInstanceFamilyPricePercentages() (errs *apis.FieldError) {
+	for family, percentage := range a.InstanceFamilyPricePercentages {
+		if family == "" || strings.Contains(family, ".") {
+			errs = errs.Also(apis.ErrInvalidKeyName(family, familyPricePercentagesPath, "must be an instance family"))
+		}
+		if percentage <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%d must be positive", percentage), fmt.Sprintf("%s['%s']", familyPricePercentagesPath, family)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateMaxPodsPerInstanceType() (errs *apis.FieldError) {
+	for instanceType, maxPods := range a.MaxPodsPerInstanceType {
+		if instanceType == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(instanceType, maxPodsPerInstanceTypePath, "must be an instance type"))
+		}
+		if maxPods <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%d must be positive", maxPods), fmt.Sprintf("%s['%s']", maxPodsPerInstanceTypePath, instanceType)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validatePodPIDsLimit() *apis.FieldError {
+	if a.PodPIDsLimit == nil {
+		return nil
+	}
+	if *a.PodPIDsLimit <= 0 {
+		return apis.ErrInvalidValue(*a.PodPIDsLimit, podPIDsLimitPath, "must be greater than 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateInstanceStorePolicy() *apis.FieldError {
+	if a.InstanceStorePolicy == nil {
+		return nil
+	}
+	return a.validateStringEnum(*a.InstanceStorePolicy, instanceStorePolicyPath, SupportedInstanceStorePolicies)
+}
+
+func (a *AWS) validateIPFamily() *apis.FieldError {
+	if a.IPFamily == nil {
+		return nil
+	}
+	return a.validateStringEnum(*a.IPFamily, ipFamilyPath, SupportedIPFamilies)
+}
+
+func (a *AWS) validateSwap() *apis.FieldError {
+	if a.Swap == nil {
+		return nil
+	}
+	if a.Swap.Sign() <= 0 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%s must be positive", a.Swap.String()), swapPath)
+	}
+	return nil
+}
+
+func (a *AWS) validateBurstableVCPUBaseline() *apis.FieldError {
+	if a.BurstableVCPUBaseline == nil {
+		return nil
+	}
+	if a.BurstableVCPUBaseline.Sign() <= 0 || a.BurstableVCPUBaseline.MilliValue() > 1000 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%s must be positive and at most one vCPU", a.BurstableVCPUBaseline.String()), burstableVCPUBaselinePath)
+	}
+	return nil
+}
+
+func (a *AWS) validateExcludedZones() (errs *apis.FieldError) {
+	for i, zone := range a.ExcludedZones {
+		if zone == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(zone, excludedZonesPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateZoneTypes() (errs *apis.FieldError) {
+	for i, zoneType := range a.ZoneTypes {
+		if err := a.validateStringEnum(zoneType, zoneTypesPath, SupportedZoneTypes); err != nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(zoneType, zoneTypesPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateVirtualizationTypes() (errs *apis.FieldError) {
+	for i, virtualizationType := range a.VirtualizationTypes {
+		if err := a.validateStringEnum(virtualizationType, virtualizationTypesPath, SupportedVirtualizationTypes); err != nil {
+			errs = errs.Also(apis.ErrInvalidArrayValue(virtualizationType, virtualizationTypesPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateCapacityReservations() (errs *apis.FieldError) {
+	for key, value := range a.CapacityReservationSelector {
+		if key == "" || value == "" {
+			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", capacityReservationsPath, key)))
+		}
+		if key == "aws-ids" {
+			for _, capacityReservationID := range functional.SplitCommaSeparatedString(value) {
+				if !reservationRegex.MatchString(capacityReservationID) {
+					fieldValue := fmt.Sprintf("\"%s\"", capacityReservationID)
+					message := fmt.Sprintf("%s['%s'] must be a valid capacity-reservation-id (regex: %s)", capacityReservationsPath, key, reservationRegex.String())
+					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
+				}
+			}
+		}
+	}
+	return errs
+}
+
 func (a *AWS) validateKubeletConfiguration(kubeletConfig *v1alpha5.KubeletConfiguration) *apis.FieldError {
 	if kubeletConfig == nil {
 		return nil
@@ -279,4 +676,58 @@ func (a *AWS) validateKubeletConfiguration(kubeletConfig *v1alpha5.KubeletConfig
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (a *AWS) validateInstanceTypeOverrides() (errs *apis.FieldError) {
+	for instanceType, override := range a.InstanceTypeOverrides {
+		if instanceType == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(instanceType, instanceTypeOverridesPath, "must be an instance type"))
+		}
+		path := fmt.Sprintf("%s['%s']", instanceTypeOverridesPath, instanceType)
+		for field, quantity := range map[string]*resource.Quantity{"cpu": override.CPU, "memory": override.Memory, "ephemeralStorage": override.EphemeralStorage} {
+			if quantity != nil && quantity.Sign() < 0 {
+				errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s must not be negative", quantity.String()), fmt.Sprintf("%s.%s", path, field)))
+			}
+		}
+		if override.Pods != nil && *override.Pods <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%d must be positive", *override.Pods), fmt.Sprintf("%s.pods", path)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateDaemonSetOverhead() (errs *apis.FieldError) {
+	for name, quantity := range a.DaemonSetOverhead {
+		if name != v1.ResourceCPU && name != v1.ResourceMemory && name != v1.ResourceEphemeralStorage {
+			errs = errs.Also(apis.ErrInvalidKeyName(string(name), daemonSetOverheadPath, "must be cpu, memory, or ephemeral-storage"))
+			continue
+		}
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s must be non-negative", quantity.String()), fmt.Sprintf("%s['%s']", daemonSetOverheadPath, name)))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateReservedCPUs() *apis.FieldError {
+	if a.ReservedCPUs == nil {
+		return nil
+	}
+	if *a.ReservedCPUs < 0 {
+		return apis.ErrInvalidValue(*a.ReservedCPUs, reservedCPUsPath, "must be greater than or equal to 0")
+	}
+	return nil
+}
+
+func (a *AWS) validateDefaultVolumeSize() (errs *apis.FieldError) {
+	if a.DefaultVolumeSize == nil {
+		return nil
+	}
+	if len(a.BlockDeviceMappings) != 0 {
+		errs = errs.Also(apis.ErrMultipleOneOf(blockDeviceMappingsPath, defaultVolumeSizePath))
+	}
+	if a.DefaultVolumeSize.Cmp(minVolumeSize) == -1 || a.DefaultVolumeSize.Cmp(maxVolumeSize) == 1 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(a.DefaultVolumeSize.String(), minVolumeSize.String(), maxVolumeSize.String(), defaultVolumeSizePath))
+	}
+	return errs
+}
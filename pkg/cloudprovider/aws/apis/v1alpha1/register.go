@@ -16,6 +16,7 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -29,11 +30,17 @@ import (
 var (
 	LabelDomain = "karpenter.k8s.aws"
 
-	CapacityTypeSpot       = ec2.DefaultTargetCapacityTypeSpot
-	CapacityTypeOnDemand   = ec2.DefaultTargetCapacityTypeOnDemand
+	CapacityTypeSpot     = ec2.DefaultTargetCapacityTypeSpot
+	CapacityTypeOnDemand = ec2.DefaultTargetCapacityTypeOnDemand
+	// CapacityTypeCapacityBlock is reserved capacity purchased through Capacity Blocks for ML
+	CapacityTypeCapacityBlock = "capacity-block"
+	// AWSToKubeArchitectures maps processor architectures reported by EC2 to kubernetes architectures. Mac instance
+	// types report their own architectures, as they only run macOS.
 	AWSToKubeArchitectures = map[string]string{
 		"x86_64":                   v1alpha5.ArchitectureAmd64,
 		v1alpha5.ArchitectureArm64: v1alpha5.ArchitectureArm64,
+		"x86_64_mac":               v1alpha5.ArchitectureAmd64,
+		"arm64_mac":                v1alpha5.ArchitectureArm64,
 	}
 	RestrictedLabelDomains = []string{
 		LabelDomain,
@@ -41,30 +48,114 @@ var (
 	AMIFamilyBottlerocket = "Bottlerocket"
 	AMIFamilyAL2          = "AL2"
 	AMIFamilyUbuntu       = "Ubuntu"
+	AMIFamilyMacOS        = "MacOS"
 	SupportedAMIFamilies  = []string{
 		AMIFamilyBottlerocket,
 		AMIFamilyAL2,
 		AMIFamilyUbuntu,
+		AMIFamilyMacOS,
+	}
+	InstanceStorePolicyRAID0       = "RAID0"
+	SupportedInstanceStorePolicies = []string{
+		InstanceStorePolicyRAID0,
+	}
+	IPFamilyIPv4        = "ipv4"
+	IPFamilyIPv6        = "ipv6"
+	SupportedIPFamilies = []string{
+		IPFamilyIPv4,
+		IPFamilyIPv6,
+	}
+	ZoneTypeAvailabilityZone = "availability-zone"
+	ZoneTypeLocalZone        = "local-zone"
+	ZoneTypeWavelengthZone   = "wavelength-zone"
+	SupportedZoneTypes       = []string{
+		ZoneTypeAvailabilityZone,
+		ZoneTypeLocalZone,
+		ZoneTypeWavelengthZone,
+	}
+	VirtualizationTypeHVM         = "hvm"
+	VirtualizationTypeParavirtual = "paravirtual"
+	SupportedVirtualizationTypes  = []string{
+		VirtualizationTypeHVM,
+		VirtualizationTypeParavirtual,
 	}
 	SupportedContainerRuntimesByAMIFamily = map[string]sets.String{
 		AMIFamilyBottlerocket: sets.NewString("containerd"),
 		AMIFamilyAL2:          sets.NewString("dockerd", "containerd"),
 		AMIFamilyUbuntu:       sets.NewString("dockerd", "containerd"),
+		// The container runtime of macOS nodes is configured by custom user data
+		AMIFamilyMacOS: sets.NewString(),
 	}
-	ResourceNVIDIAGPU v1.ResourceName = "nvidia.com/gpu"
-	ResourceAMDGPU    v1.ResourceName = "amd.com/gpu"
-	ResourceAWSNeuron v1.ResourceName = "aws.amazon.com/neuron"
-	ResourceAWSPodENI v1.ResourceName = "vpc.amazonaws.com/pod-eni"
+	ResourceNVIDIAGPU          v1.ResourceName = "nvidia.com/gpu"
+	ResourceAMDGPU             v1.ResourceName = "amd.com/gpu"
+	ResourceAWSNeuron          v1.ResourceName = "aws.amazon.com/neuron"
+	ResourceHabanaGaudi        v1.ResourceName = "habana.ai/gaudi"
+	ResourceAWSPodENI          v1.ResourceName = "vpc.amazonaws.com/pod-eni"
 	ResourceSmarterDevicesFuse v1.ResourceName = "smarter-devices/fuse"
+	ResourceGPUMemory          v1.ResourceName = "karpenter.k8s.aws/gpu-memory"
+	ResourceLocalStorage       v1.ResourceName = "karpenter.k8s.aws/local-storage"
+	ResourceHugePages2Mi       v1.ResourceName = v1.ResourceHugePagesPrefix + "2Mi"
+	ResourceHugePages1Gi       v1.ResourceName = v1.ResourceHugePagesPrefix + "1Gi"
+	// ResourceNVIDIAMIGPrefix prefixes the MIG devices advertised by the NVIDIA device plugin's mixed strategy
+	ResourceNVIDIAMIGPrefix = "nvidia.com/mig-"
+	SupportedHugePageSizes  = map[v1.ResourceName]resource.Quantity{
+		ResourceHugePages2Mi: resource.MustParse("2Mi"),
+		ResourceHugePages1Gi: resource.MustParse("1Gi"),
+	}
+	// ComputedResources are derived from each instance type, so they can't be configured as extraResources
+	ComputedResources = sets.NewString(
+		string(v1.ResourceCPU),
+		string(v1.ResourceMemory),
+		string(v1.ResourceEphemeralStorage),
+		string(v1.ResourcePods),
+		string(ResourceNVIDIAGPU),
+		string(ResourceAMDGPU),
+		string(ResourceAWSNeuron),
+		string(ResourceHabanaGaudi),
+		string(ResourceAWSPodENI),
+		string(ResourceGPUMemory),
+		string(ResourceLocalStorage),
+	)
+
+	AcceleratorGPU        = "gpu"
+	AcceleratorInferentia = "inferentia"
+	AcceleratorTrainium   = "trainium"
 
-	InstanceFamilyLabelKey          = LabelDomain + "/instance.family"
-	InstanceSizeLabelKey            = LabelDomain + "/instance.size"
-	InstanceCPULabelKey             = LabelDomain + "/instance.cpu"
-	InstanceMemoryLabelKey          = LabelDomain + "/instance.memory"
-	InstanceGPUNameLabelKey         = LabelDomain + "/instance.gpu.name"
-	InstanceGPUManufacturerLabelKey = LabelDomain + "/instance.gpu.manufacturer"
-	InstanceGPUCountLabelKey        = LabelDomain + "/instance.gpu.count"
-	InstanceGPUMemoryLabelKey       = LabelDomain + "/instance.gpu.memory"
+	InstanceFamilyLabelKey                  = LabelDomain + "/instance.family"
+	InstanceSizeLabelKey                    = LabelDomain + "/instance.size"
+	InstanceSizeOrdinalLabelKey             = LabelDomain + "/instance.size-ordinal"
+	InstanceCPULabelKey                     = LabelDomain + "/instance.cpu"
+	InstanceMemoryLabelKey                  = LabelDomain + "/instance.memory"
+	InstanceMemoryBandwidthLabelKey         = LabelDomain + "/instance.memory-bandwidth"
+	InstanceNUMANodesLabelKey               = LabelDomain + "/instance.numa-nodes"
+	InstanceSocketsLabelKey                 = LabelDomain + "/instance.sockets"
+	InstanceCoresPerSocketLabelKey          = LabelDomain + "/instance.cores-per-socket"
+	InstanceMaxENIsLabelKey                 = LabelDomain + "/instance.max-enis"
+	InstanceZoneCountLabelKey               = LabelDomain + "/instance.zone-count"
+	InstanceZoneTypeLabelKey                = LabelDomain + "/instance.zone-type"
+	InstanceEncryptionInTransitLabelKey     = LabelDomain + "/instance.encryption-in-transit-supported"
+	InstanceENAExpressLabelKey              = LabelDomain + "/instance.ena-express-supported"
+	InstanceDedicatedHostOnlyLabelKey       = LabelDomain + "/instance.dedicated-host-only"
+	InstanceRootDeviceTypeLabelKey          = LabelDomain + "/instance.root-device-type"
+	InstanceEBSDedicatedBandwidthLabelKey   = LabelDomain + "/instance.ebs-dedicated-bandwidth"
+	InstanceEBSIOPSLabelKey                 = LabelDomain + "/instance.ebs-iops"
+	InstanceLocalDiskCountLabelKey          = LabelDomain + "/instance.local-disk-count"
+	InstanceLocalDiskTypeLabelKey           = LabelDomain + "/instance.local-disk-type"
+	InstanceSpotInterruptionRateLabelKey    = LabelDomain + "/instance.spot-interruption-rate"
+	InstanceNVMeSupportLabelKey             = LabelDomain + "/instance.nvme-support"
+	InstanceSupportedBootModesLabelKey      = LabelDomain + "/instance.supported-boot-modes"
+	InstanceVirtualizationTypeLabelKey      = LabelDomain + "/instance.virtualization-type"
+	InstanceNestedVirtualizationLabelKey    = LabelDomain + "/instance.nested-virtualization"
+	InstanceAcceleratorLabelKey             = LabelDomain + "/instance.accelerator"
+	InstanceAcceleratorNameLabelKey         = LabelDomain + "/instance.accelerator.name"
+	InstanceAcceleratorManufacturerLabelKey = LabelDomain + "/instance.accelerator.manufacturer"
+	InstanceGPUNameLabelKey                 = LabelDomain + "/instance.gpu.name"
+	InstanceGPUManufacturerLabelKey         = LabelDomain + "/instance.gpu.manufacturer"
+	InstanceGPUCountLabelKey                = LabelDomain + "/instance.gpu.count"
+	InstanceGPUMemoryLabelKey               = LabelDomain + "/instance.gpu.memory"
+	InstanceGPUMemoryTierLabelKey           = LabelDomain + "/instance.gpu.memory-tier"
+	InstanceGPUInterconnectLabelKey         = LabelDomain + "/instance.gpu.interconnect"
+	LabelCapacityReservationID              = LabelDomain + "/capacity-reservation-id"
 )
 
 var (
@@ -78,11 +169,38 @@ func init() {
 	v1alpha5.WellKnownLabels = v1alpha5.WellKnownLabels.Insert(
 		InstanceFamilyLabelKey,
 		InstanceSizeLabelKey,
+		InstanceSizeOrdinalLabelKey,
 		InstanceCPULabelKey,
 		InstanceMemoryLabelKey,
+		InstanceMemoryBandwidthLabelKey,
+		InstanceNUMANodesLabelKey,
+		InstanceSocketsLabelKey,
+		InstanceCoresPerSocketLabelKey,
+		InstanceMaxENIsLabelKey,
+		InstanceZoneCountLabelKey,
+		InstanceZoneTypeLabelKey,
+		InstanceEncryptionInTransitLabelKey,
+		InstanceENAExpressLabelKey,
+		InstanceDedicatedHostOnlyLabelKey,
+		InstanceRootDeviceTypeLabelKey,
+		InstanceEBSDedicatedBandwidthLabelKey,
+		InstanceEBSIOPSLabelKey,
+		InstanceLocalDiskCountLabelKey,
+		InstanceLocalDiskTypeLabelKey,
+		InstanceNVMeSupportLabelKey,
+		InstanceSpotInterruptionRateLabelKey,
+		InstanceSupportedBootModesLabelKey,
+		InstanceVirtualizationTypeLabelKey,
+		InstanceNestedVirtualizationLabelKey,
+		InstanceAcceleratorLabelKey,
+		InstanceAcceleratorNameLabelKey,
+		InstanceAcceleratorManufacturerLabelKey,
 		InstanceGPUNameLabelKey,
 		InstanceGPUManufacturerLabelKey,
 		InstanceGPUCountLabelKey,
 		InstanceGPUMemoryLabelKey,
+		InstanceGPUMemoryTierLabelKey,
+		InstanceGPUInterconnectLabelKey,
+		LabelCapacityReservationID,
 	)
 }
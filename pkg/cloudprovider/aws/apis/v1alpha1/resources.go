@@ -0,0 +1,31 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// HasNVIDIAGPUs returns true if the resources include NVIDIA GPUs, either whole or partitioned into MIG devices
+func HasNVIDIAGPUs(resources v1.ResourceList) bool {
+	for name, quantity := range resources {
+		if (name == ResourceNVIDIAGPU || strings.HasPrefix(string(name), ResourceNVIDIAMIGPrefix)) && !quantity.IsZero() {
+			return true
+		}
+	}
+	return false
+}
@@ -20,6 +20,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -58,6 +60,211 @@ func (in *AWS) DeepCopyInto(out *AWS) {
 			(*out)[key] = val
 		}
 	}
+	if in.HugePages != nil {
+		in, out := &in.HugePages, &out.HugePages
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ExtraResources != nil {
+		in, out := &in.ExtraResources, &out.ExtraResources
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ExcludedInstanceFamilies != nil {
+		in, out := &in.ExcludedInstanceFamilies, &out.ExcludedInstanceFamilies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceFamilyPatterns != nil {
+		in, out := &in.InstanceFamilyPatterns, &out.InstanceFamilyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ArchitectureMappings != nil {
+		in, out := &in.ArchitectureMappings, &out.ArchitectureMappings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExcludeMetal != nil {
+		in, out := &in.ExcludeMetal, &out.ExcludeMetal
+		*out = new(bool)
+		**out = **in
+	}
+	if in.GPUReplicaFactor != nil {
+		in, out := &in.GPUReplicaFactor, &out.GPUReplicaFactor
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MinAllocatableEphemeralStorage != nil {
+		in, out := &in.MinAllocatableEphemeralStorage, &out.MinAllocatableEphemeralStorage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.ImageGCReservationPercentage != nil {
+		in, out := &in.ImageGCReservationPercentage, &out.ImageGCReservationPercentage
+		*out = new(int64)
+		**out = **in
+	}
+	if in.EnableGPUMemoryResource != nil {
+		in, out := &in.EnableGPUMemoryResource, &out.EnableGPUMemoryResource
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableLocalStorageResource != nil {
+		in, out := &in.EnableLocalStorageResource, &out.EnableLocalStorageResource
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MIGProfiles != nil {
+		in, out := &in.MIGProfiles, &out.MIGProfiles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.GPUReservedMemory != nil {
+		in, out := &in.GPUReservedMemory, &out.GPUReservedMemory
+		*out = make(map[string]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.SpotDiscountPercentage != nil {
+		in, out := &in.SpotDiscountPercentage, &out.SpotDiscountPercentage
+		*out = new(int64)
+		**out = **in
+	}
+	if in.InstanceFamilyPricePercentages != nil {
+		in, out := &in.InstanceFamilyPricePercentages, &out.InstanceFamilyPricePercentages
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MaxPrice != nil {
+		in, out := &in.MaxPrice, &out.MaxPrice
+		*out = new(float64)
+		**out = **in
+	}
+	if in.MaxPricePerPod != nil {
+		in, out := &in.MaxPricePerPod, &out.MaxPricePerPod
+		*out = new(float64)
+		**out = **in
+	}
+	if in.ScoringProfile != nil {
+		in, out := &in.ScoringProfile, &out.ScoringProfile
+		*out = new(string)
+		**out = **in
+	}
+	if in.MinMemoryPerVCPU != nil {
+		in, out := &in.MinMemoryPerVCPU, &out.MinMemoryPerVCPU
+		*out = new(float64)
+		**out = **in
+	}
+	if in.MinVCPU != nil {
+		in, out := &in.MinVCPU, &out.MinVCPU
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MinGPUs != nil {
+		in, out := &in.MinGPUs, &out.MinGPUs
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxPodsPerInstanceType != nil {
+		in, out := &in.MaxPodsPerInstanceType, &out.MaxPodsPerInstanceType
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodPIDsLimit != nil {
+		in, out := &in.PodPIDsLimit, &out.PodPIDsLimit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ContainerdDataDevice != nil {
+		in, out := &in.ContainerdDataDevice, &out.ContainerdDataDevice
+		*out = new(string)
+		**out = **in
+	}
+	if in.InstanceStorePolicy != nil {
+		in, out := &in.InstanceStorePolicy, &out.InstanceStorePolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.Swap != nil {
+		in, out := &in.Swap, &out.Swap
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.ExcludedZones != nil {
+		in, out := &in.ExcludedZones, &out.ExcludedZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ZoneTypes != nil {
+		in, out := &in.ZoneTypes, &out.ZoneTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VirtualizationTypes != nil {
+		in, out := &in.VirtualizationTypes, &out.VirtualizationTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BurstableVCPUBaseline != nil {
+		in, out := &in.BurstableVCPUBaseline, &out.BurstableVCPUBaseline
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.CustomNetworking != nil {
+		in, out := &in.CustomNetworking, &out.CustomNetworking
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IPFamily != nil {
+		in, out := &in.IPFamily, &out.IPFamily
+		*out = new(string)
+		**out = **in
+	}
+	if in.DedicatedHosts != nil {
+		in, out := &in.DedicatedHosts, &out.DedicatedHosts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CapacityReservationSelector != nil {
+		in, out := &in.CapacityReservationSelector, &out.CapacityReservationSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.InstanceTypeOverrides != nil {
+		in, out := &in.InstanceTypeOverrides, &out.InstanceTypeOverrides
+		*out = make(map[string]InstanceTypeOverride, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.DaemonSetOverhead != nil {
+		in, out := &in.DaemonSetOverhead, &out.DaemonSetOverhead
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ReservedCPUs != nil {
+		in, out := &in.ReservedCPUs, &out.ReservedCPUs
+		*out = new(int64)
+		**out = **in
+	}
 	in.LaunchTemplate.DeepCopyInto(&out.LaunchTemplate)
 }
 
@@ -159,6 +366,41 @@ func (in *BlockDeviceMapping) DeepCopy() *BlockDeviceMapping {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceTypeOverride) DeepCopyInto(out *InstanceTypeOverride) {
+	*out = *in
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.EphemeralStorage != nil {
+		in, out := &in.EphemeralStorage, &out.EphemeralStorage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceTypeOverride.
+func (in *InstanceTypeOverride) DeepCopy() *InstanceTypeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceTypeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LaunchTemplate) DeepCopyInto(out *LaunchTemplate) {
 	*out = *in
@@ -183,6 +425,11 @@ func (in *LaunchTemplate) DeepCopyInto(out *LaunchTemplate) {
 			}
 		}
 	}
+	if in.DefaultVolumeSize != nil {
+		in, out := &in.DefaultVolumeSize, &out.DefaultVolumeSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LaunchTemplate.
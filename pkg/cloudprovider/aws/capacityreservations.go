@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/patrickmn/go-cache"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/utils/functional"
+	"github.com/aws/karpenter/pkg/utils/pretty"
+)
+
+type CapacityReservationProvider struct {
+	sync.Mutex
+	ec2api ec2iface.EC2API
+	cache  *cache.Cache
+}
+
+func NewCapacityReservationProvider(ec2api ec2iface.EC2API) *CapacityReservationProvider {
+	return &CapacityReservationProvider{
+		ec2api: ec2api,
+		cache:  cache.New(CacheTTL, CacheCleanupInterval),
+	}
+}
+
+// Get returns the active capacity reservations selected by the provider that have instances available. Reservations are
+// only discovered if the provider has a capacity reservation selector. Targeted reservations are only used by launches
+// that target them explicitly, so they're ignored unless they're capacity blocks, which Karpenter launches into by
+// targeting them.
+func (p *CapacityReservationProvider) Get(ctx context.Context, provider *v1alpha1.AWS) ([]*ec2.CapacityReservation, error) {
+	if len(provider.CapacityReservationSelector) == 0 {
+		return nil, nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	filters := getCapacityReservationFilters(provider)
+	hash, err := hashstructure.Hash(filters, hashstructure.FormatV2, nil)
+	if err != nil {
+		return nil, err
+	}
+	if capacityReservations, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+		return capacityReservations.([]*ec2.CapacityReservation), nil
+	}
+	var capacityReservations []*ec2.CapacityReservation
+	if err := p.ec2api.DescribeCapacityReservationsPagesWithContext(ctx, &ec2.DescribeCapacityReservationsInput{Filters: filters}, func(output *ec2.DescribeCapacityReservationsOutput, _ bool) bool {
+		for _, capacityReservation := range output.CapacityReservations {
+			if aws.Int64Value(capacityReservation.AvailableInstanceCount) > 0 && (aws.StringValue(capacityReservation.InstanceMatchCriteria) == ec2.InstanceMatchCriteriaOpen ||
+				aws.StringValue(capacityReservation.ReservationType) == ec2.CapacityReservationTypeCapacityBlock) {
+				capacityReservations = append(capacityReservations, capacityReservation)
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing capacity reservations %s, %w", pretty.Concise(filters), err)
+	}
+	p.cache.SetDefault(fmt.Sprint(hash), capacityReservations)
+	logging.FromContext(ctx).Debugf("Discovered capacity reservations: %s", prettyCapacityReservations(capacityReservations))
+	return capacityReservations, nil
+}
+
+func getCapacityReservationFilters(provider *v1alpha1.AWS) []*ec2.Filter {
+	filters := []*ec2.Filter{
+		{Name: aws.String("state"), Values: aws.StringSlice([]string{ec2.CapacityReservationStateActive})},
+	}
+	for key, value := range provider.CapacityReservationSelector {
+		if key == "aws-ids" {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String("capacity-reservation-id"),
+				Values: aws.StringSlice(functional.SplitCommaSeparatedString(value)),
+			})
+		} else if value == "*" {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(key)},
+			})
+		} else {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String(fmt.Sprintf("tag:%s", key)),
+				Values: []*string{aws.String(value)},
+			})
+		}
+	}
+	return filters
+}
+
+func prettyCapacityReservations(capacityReservations []*ec2.CapacityReservation) []string {
+	names := []string{}
+	for _, capacityReservation := range capacityReservations {
+		names = append(names, fmt.Sprintf("%s (%s, %s)", aws.StringValue(capacityReservation.CapacityReservationId),
+			aws.StringValue(capacityReservation.InstanceType), aws.StringValue(capacityReservation.AvailabilityZone)))
+	}
+	return names
+}
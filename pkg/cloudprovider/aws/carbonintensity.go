@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"github.com/aws/karpenter/pkg/cloudprovider"
+)
+
+// CarbonIntensitySource provides the carbon intensity of the electricity grid that powers a zone, in grams of CO2
+// equivalent per kWh, e.g. from a grid emissions API. It returns false if the carbon intensity of the zone is unknown.
+type CarbonIntensitySource interface {
+	CarbonIntensity(zone string) (float64, bool)
+}
+
+// StaticCarbonIntensitySource is a CarbonIntensitySource of fixed carbon intensities, keyed by zone
+type StaticCarbonIntensitySource map[string]float64
+
+func (s StaticCarbonIntensitySource) CarbonIntensity(zone string) (float64, bool) {
+	carbonIntensity, ok := s[zone]
+	return carbonIntensity, ok
+}
+
+// CarbonScorer biases a Scorer toward offerings in zones with a lower carbon intensity, adding the offering's carbon
+// intensity multiplied by the weight to the score. Offerings of unknown carbon intensity are scored by the Scorer alone.
+type CarbonScorer struct {
+	// Scorer scores offerings before their carbon intensity is considered, and defaults to the PriceScorer
+	Scorer Scorer
+	// Weight is added to the score for each gram of CO2 equivalent per kWh
+	Weight float64
+}
+
+func (c CarbonScorer) Score(instanceType *InstanceType, offering cloudprovider.Offering) float64 {
+	scorer := c.Scorer
+	if scorer == nil {
+		scorer = PriceScorer{}
+	}
+	return scorer.Score(instanceType, offering) + c.Weight*offering.CarbonIntensity
+}
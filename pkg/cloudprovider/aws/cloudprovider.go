@@ -37,10 +37,12 @@ import (
 	"github.com/aws/karpenter/pkg/utils/project"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/transport"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
+	"knative.dev/pkg/system"
 )
 
 const (
@@ -82,7 +84,12 @@ func NewCloudProvider(ctx context.Context, options cloudprovider.Options) *Cloud
 	logging.FromContext(ctx).Debugf("Using AWS region %s", *sess.Config.Region)
 	ec2api := ec2.New(sess)
 	subnetProvider := NewSubnetProvider(ec2api)
-	instanceTypeProvider := NewInstanceTypeProvider(ec2api, subnetProvider)
+	region := *sess.Config.Region
+	instanceTypeProvider := NewInstanceTypeProvider(ec2api, region, subnetProvider, NewCapacityReservationProvider(ec2api), NewPriceProvider(ec2api, region, injection.GetOptions(ctx).AWSPriceRefreshInterval, injection.GetOptions(ctx).AWSSpotPriceMaxAge))
+	if injection.GetOptions(ctx).AWSEnableMemoryCorrection {
+		instanceTypeProvider.memoryCorrections.Start(ctx, options.ClientSet, system.Namespace())
+	}
+	preload(ctx, options, instanceTypeProvider)
 	return &CloudProvider{
 		instanceTypeProvider: instanceTypeProvider,
 		subnetProvider:       subnetProvider,
@@ -99,6 +106,47 @@ func NewCloudProvider(ctx context.Context, options cloudprovider.Options) *Cloud
 	}
 }
 
+// Preloaded returns a channel that's closed once the instance types of existing provisioners are computed at startup,
+// or right away if instance types aren't preloaded.
+func (c *CloudProvider) Preloaded() <-chan struct{} {
+	return c.instanceTypeProvider.Preloaded()
+}
+
+// preload starts preloading instance types in the background if it's enabled, and otherwise marks them preloaded right
+// away so that readiness doesn't wait for them
+func preload(ctx context.Context, options cloudprovider.Options, instanceTypeProvider *InstanceTypeProvider) {
+	if !injection.GetOptions(ctx).AWSPreloadInstanceTypes {
+		instanceTypeProvider.markPreloaded()
+		return
+	}
+	go preloadInstanceTypes(ctx, options, instanceTypeProvider)
+}
+
+// preloadInstanceTypes computes the instance types of existing provisioners so that the first provisioning decision
+// doesn't wait for them. Provisioners can't be listed until the manager's cache starts, so listing is retried until then.
+func preloadInstanceTypes(ctx context.Context, options cloudprovider.Options, instanceTypeProvider *InstanceTypeProvider) {
+	provisioners := &v1alpha5.ProvisionerList{}
+	if err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		return options.KubeClient.List(ctx, provisioners) == nil, nil
+	}, ctx.Done()); err != nil {
+		return
+	}
+	var providers []*v1alpha1.AWS
+	for i := range provisioners.Items {
+		provider, err := v1alpha1.Deserialize(provisioners.Items[i].Spec.Provider)
+		if err != nil {
+			logging.FromContext(ctx).Debugf("Skipping preload of instance types for provisioner %s, %s", provisioners.Items[i].Name, err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	if err := instanceTypeProvider.Preload(ctx, providers); err != nil {
+		logging.FromContext(ctx).Errorf("Preloading instance types, %s", err)
+		return
+	}
+	logging.FromContext(ctx).Infof("Preloaded instance types for %d provisioners", len(providers))
+}
+
 // Create a node given the constraints.
 func (c *CloudProvider) Create(ctx context.Context, nodeRequest *cloudprovider.NodeRequest) (*v1.Node, error) {
 	vendorConstraints, err := v1alpha1.Deserialize(nodeRequest.Template.Provider)
@@ -127,6 +175,9 @@ func (c *CloudProvider) Validate(ctx context.Context, provisioner *v1alpha5.Prov
 	if err != nil {
 		return apis.ErrGeneric(err.Error())
 	}
+	if err := ephemeralStorageWarning(provider); err != nil {
+		logging.FromContext(ctx).Warnf("Provisioner %s may be misconfigured, %s", provisioner.Name, err)
+	}
 	return provider.Validate(*provisioner)
 }
 
@@ -26,6 +26,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	set "github.com/deckarep/golang-set"
+	"github.com/samber/lo"
 
 	"github.com/aws/karpenter/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
@@ -48,6 +49,9 @@ type EC2Behavior struct {
 	DescribeInstanceTypesOutput         *ec2.DescribeInstanceTypesOutput
 	DescribeInstanceTypeOfferingsOutput *ec2.DescribeInstanceTypeOfferingsOutput
 	DescribeAvailabilityZonesOutput     *ec2.DescribeAvailabilityZonesOutput
+	DescribeSpotPriceHistoryOutput      *ec2.DescribeSpotPriceHistoryOutput
+	DescribeSpotPriceHistoryErr         error
+	DescribeCapacityReservationsOutput  *ec2.DescribeCapacityReservationsOutput
 	CalledWithCreateFleetInput          set.Set
 	CalledWithCreateLaunchTemplateInput set.Set
 	Instances                           sync.Map
@@ -87,6 +91,9 @@ func (e *EC2API) Reset() {
 	e.DescribeInstanceTypesOutput = nil
 	e.DescribeInstanceTypeOfferingsOutput = nil
 	e.DescribeAvailabilityZonesOutput = nil
+	e.DescribeSpotPriceHistoryOutput = nil
+	e.DescribeSpotPriceHistoryErr = nil
+	e.DescribeCapacityReservationsOutput = nil
 	e.CalledWithCreateFleetInput = set.NewSet()
 	e.CalledWithCreateLaunchTemplateInput = set.NewSet()
 	e.Instances = sync.Map{}
@@ -103,10 +110,25 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 	instanceIds := []*string{}
 	skippedPools := []CapacityPool{}
 	var spotInstanceRequestID *string
+	var instanceLifecycle *string
 
 	if aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) == v1alpha1.CapacityTypeSpot {
 		spotInstanceRequestID = aws.String(randomdata.SillyName())
 	}
+	if aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) == v1alpha1.CapacityTypeCapacityBlock {
+		instanceLifecycle = aws.String(v1alpha1.CapacityTypeCapacityBlock)
+	}
+
+	var capacityReservationID *string
+	if (instanceLifecycle != nil || input.OnDemandOptions != nil && input.OnDemandOptions.CapacityReservationOptions != nil) && e.DescribeCapacityReservationsOutput != nil {
+		for _, capacityReservation := range e.DescribeCapacityReservationsOutput.CapacityReservations {
+			if aws.StringValue(capacityReservation.InstanceType) == aws.StringValue(input.LaunchTemplateConfigs[0].Overrides[0].InstanceType) &&
+				aws.StringValue(capacityReservation.AvailabilityZone) == aws.StringValue(input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone) &&
+				(aws.StringValue(capacityReservation.ReservationType) == ec2.CapacityReservationTypeCapacityBlock) == (instanceLifecycle != nil) {
+				capacityReservationID = capacityReservation.CapacityReservationId
+			}
+		}
+	}
 
 	for i := 0; i < int(*input.TargetCapacitySpecification.TotalTargetCapacity); i++ {
 		skipInstance := false
@@ -128,6 +150,8 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 			PrivateDnsName:        aws.String(randomdata.IpV4Address()),
 			InstanceType:          input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
 			SpotInstanceRequestId: spotInstanceRequestID,
+			InstanceLifecycle:     instanceLifecycle,
+			CapacityReservationId: capacityReservationID,
 		})
 		e.Instances.Store(*instances[i].InstanceId, instances[i])
 		instanceIds = append(instanceIds, instances[i].InstanceId)
@@ -275,7 +299,27 @@ func (e *EC2API) DescribeAvailabilityZonesWithContext(context.Context, *ec2.Desc
 	}}, nil
 }
 
-func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2.DescribeInstanceTypesInput, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool, _ ...request.Option) error {
+func (e *EC2API) DescribeSpotPriceHistoryPagesWithContext(_ context.Context, _ *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, _ ...request.Option) error {
+	if e.DescribeSpotPriceHistoryErr != nil {
+		return e.DescribeSpotPriceHistoryErr
+	}
+	if e.DescribeSpotPriceHistoryOutput != nil {
+		fn(e.DescribeSpotPriceHistoryOutput, false)
+	}
+	return nil
+}
+
+func (e *EC2API) DescribeCapacityReservationsPagesWithContext(_ context.Context, _ *ec2.DescribeCapacityReservationsInput, fn func(*ec2.DescribeCapacityReservationsOutput, bool) bool, _ ...request.Option) error {
+	if e.DescribeCapacityReservationsOutput != nil {
+		fn(e.DescribeCapacityReservationsOutput, false)
+	}
+	return nil
+}
+
+// DescribeInstanceTypesPagesWithContext only returns instance types that match the architecture and virtualization type
+// filters of the input, like EC2
+func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, input *ec2.DescribeInstanceTypesInput, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool, _ ...request.Option) error {
+	fn = filterInstanceTypes(input.Filters, fn)
 	if e.DescribeInstanceTypesOutput != nil {
 		fn(e.DescribeInstanceTypesOutput, false)
 		return nil
@@ -415,6 +459,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				},
 				InferenceAcceleratorInfo: &ec2.InferenceAcceleratorInfo{
 					Accelerators: []*ec2.InferenceDeviceInfo{{
+						Name:         aws.String("Inferentia"),
 						Manufacturer: aws.String("AWS"),
 						Count:        aws.Int64(1),
 					}}},
@@ -441,6 +486,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				},
 				InferenceAcceleratorInfo: &ec2.InferenceAcceleratorInfo{
 					Accelerators: []*ec2.InferenceDeviceInfo{{
+						Name:         aws.String("Inferentia"),
 						Manufacturer: aws.String("AWS"),
 						Count:        aws.Int64(4),
 					}}},
@@ -475,6 +521,38 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 	return nil
 }
 
+func filterInstanceTypes(filters []*ec2.Filter, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool) func(*ec2.DescribeInstanceTypesOutput, bool) bool {
+	return func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+		var instanceTypes []*ec2.InstanceTypeInfo
+		for _, instanceType := range page.InstanceTypes {
+			if matchesFilters(instanceType, filters) {
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+		return fn(&ec2.DescribeInstanceTypesOutput{InstanceTypes: instanceTypes}, lastPage)
+	}
+}
+
+func matchesFilters(instanceType *ec2.InstanceTypeInfo, filters []*ec2.Filter) bool {
+	for _, filter := range filters {
+		var values []*string
+		switch aws.StringValue(filter.Name) {
+		case "processor-info.supported-architecture":
+			if instanceType.ProcessorInfo != nil {
+				values = instanceType.ProcessorInfo.SupportedArchitectures
+			}
+		case "supported-virtualization-type":
+			values = instanceType.SupportedVirtualizationTypes
+		default:
+			continue
+		}
+		if !lo.Some(aws.StringValueSlice(values), aws.StringValueSlice(filter.Values)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *EC2API) DescribeInstanceTypeOfferingsPagesWithContext(_ context.Context, _ *ec2.DescribeInstanceTypeOfferingsInput, fn func(*ec2.DescribeInstanceTypeOfferingsOutput, bool) bool, _ ...request.Option) error {
 	if e.DescribeInstanceTypeOfferingsOutput != nil {
 		fn(e.DescribeInstanceTypeOfferingsOutput, false)
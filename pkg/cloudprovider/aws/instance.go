@@ -123,8 +123,15 @@ func (p *InstanceProvider) Terminate(ctx context.Context, node *v1.Node) error {
 
 func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest) (*string, error) {
 	capacityType := p.getCapacityType(nodeRequest)
+	var capacityReservationID string
+	switch capacityType {
+	case v1alpha1.CapacityTypeOnDemand:
+		capacityReservationID = targetedCapacityReservation(nodeRequest)
+	case v1alpha1.CapacityTypeCapacityBlock:
+		capacityReservationID = capacityBlockReservation(nodeRequest)
+	}
 	// Get Launch Template Configs, which may differ due to GPU or Architecture requirements
-	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(ctx, provider, nodeRequest, capacityType)
+	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(ctx, provider, nodeRequest, capacityType, capacityReservationID)
 	if err != nil {
 		return nil, fmt.Errorf("getting launch template configs, %w", err)
 	}
@@ -146,6 +153,13 @@ func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha
 		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(ec2.SpotAllocationStrategyCapacityOptimizedPrioritized)}
 	} else {
 		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(ec2.FleetOnDemandAllocationStrategyLowestPrice)}
+		// Launch into the open capacity reservations that match the overrides before launching on-demand capacity. Unless
+		// the node is pinned to a reservation, EC2 chooses the reservation, which is labeled on the node once launched.
+		if capacityType == v1alpha1.CapacityTypeOnDemand && hasCapacityReservation(nodeRequest) {
+			createFleetInput.OnDemandOptions.CapacityReservationOptions = &ec2.CapacityReservationOptionsRequest{
+				UsageStrategy: aws.String(ec2.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst),
+			}
+		}
 	}
 	createFleetOutput, err := p.ec2api.CreateFleetWithContext(ctx, createFleetInput)
 	if err != nil {
@@ -162,20 +176,21 @@ func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha
 	return createFleetOutput.Instances[0].InstanceIds[0], nil
 }
 
-func (p *InstanceProvider) getLaunchTemplateConfigs(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, capacityType string) ([]*ec2.FleetLaunchTemplateConfigRequest, error) {
+func (p *InstanceProvider) getLaunchTemplateConfigs(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, capacityType string,
+	capacityReservationID string) ([]*ec2.FleetLaunchTemplateConfigRequest, error) {
 	// Get subnets given the constraints
 	subnets, err := p.subnetProvider.Get(ctx, provider)
 	if err != nil {
 		return nil, fmt.Errorf("getting subnets, %w", err)
 	}
 	var launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest
-	launchTemplates, err := p.launchTemplateProvider.Get(ctx, provider, nodeRequest, map[string]string{v1alpha5.LabelCapacityType: capacityType})
+	launchTemplates, err := p.launchTemplateProvider.Get(ctx, provider, nodeRequest, map[string]string{v1alpha5.LabelCapacityType: capacityType}, capacityReservationID)
 	if err != nil {
 		return nil, fmt.Errorf("getting launch templates, %w", err)
 	}
 	for launchTemplateName, instanceTypes := range launchTemplates {
 		launchTemplateConfig := &ec2.FleetLaunchTemplateConfigRequest{
-			Overrides: p.getOverrides(instanceTypes, subnets, nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone), capacityType),
+			Overrides: p.getOverrides(instanceTypes, subnets, nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone), nodeRequest.Template.Requirements.Get(v1alpha1.InstanceZoneTypeLabelKey), capacityType, capacityReservationID),
 			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
 				LaunchTemplateName: aws.String(launchTemplateName),
 				Version:            aws.String("$Latest"),
@@ -192,8 +207,9 @@ func (p *InstanceProvider) getLaunchTemplateConfigs(ctx context.Context, provide
 }
 
 // getOverrides creates and returns launch template overrides for the cross product of instanceTypeOptions and subnets (with subnets being constrained by
-// zones and the offerings in instanceTypeOptions)
-func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.InstanceType, subnets []*ec2.Subnet, zones sets.Set, capacityType string) []*ec2.FleetLaunchTemplateOverridesRequest {
+// zones, zone types, and the offerings in instanceTypeOptions). If a capacity reservation is targeted, only its offerings are overridden.
+func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.InstanceType, subnets []*ec2.Subnet, zones sets.Set, zoneTypes sets.Set, capacityType string,
+	capacityReservationID string) []*ec2.FleetLaunchTemplateOverridesRequest {
 	// sort subnets in ascending order of available IP addresses and populate map with most available subnet per AZ
 	zonalSubnets := map[string]*ec2.Subnet{}
 	sort.Slice(subnets, func(i, j int) bool {
@@ -204,13 +220,19 @@ func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.Inst
 	}
 	var overrides []*ec2.FleetLaunchTemplateOverridesRequest
 	for i, instanceType := range instanceTypeOptions {
+		// Offerings in a capacity reservation share the zone and capacity type of offerings without one
+		offeredZones := utilsets.NewString()
 		for _, offering := range instanceType.Offerings() {
 			if capacityType != offering.CapacityType {
 				continue
 			}
-			if !zones.Has(offering.Zone) {
+			if capacityReservationID != "" && capacityReservationID != offering.CapacityReservationID {
 				continue
 			}
+			if !zones.Has(offering.Zone) || !zoneTypes.Has(zoneType(offering.Zone)) || offeredZones.Has(offering.Zone) {
+				continue
+			}
+			offeredZones.Insert(offering.Zone)
 			subnet, ok := zonalSubnets[offering.Zone]
 			if !ok {
 				continue
@@ -271,6 +293,11 @@ func (p *InstanceProvider) instanceToNode(ctx context.Context, instance *ec2.Ins
 			}
 			labels[v1.LabelTopologyZone] = aws.StringValue(instance.Placement.AvailabilityZone)
 			labels[v1alpha5.LabelCapacityType] = getCapacityType(instance)
+			if capacityReservationID := aws.StringValue(instance.CapacityReservationId); capacityReservationID != "" {
+				labels[v1alpha1.LabelCapacityReservationID] = capacityReservationID
+			} else {
+				delete(labels, v1alpha1.LabelCapacityReservationID)
+			}
 
 			return &v1.Node{
 				ObjectMeta: metav1.ObjectMeta{
@@ -294,10 +321,14 @@ func (p *InstanceProvider) updateUnavailableOfferingsCache(ctx context.Context,
 	}
 }
 
-// getCapacityType selects spot if both constraints are flexible and there is an
-// available offering. The AWS Cloud Provider defaults to [ on-demand ], so spot
-// must be explicitly included in capacity type requirements.
+// getCapacityType selects capacity blocks, then spot, if both constraints are flexible
+// and there is an available offering. Capacity blocks are preferred since they're
+// already paid for. The AWS Cloud Provider defaults to [ on-demand ], so other
+// capacity types must be explicitly included in capacity type requirements.
 func (p *InstanceProvider) getCapacityType(nodeRequest *cloudprovider.NodeRequest) string {
+	if nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha1.CapacityTypeCapacityBlock) && capacityBlockReservation(nodeRequest) != "" {
+		return v1alpha1.CapacityTypeCapacityBlock
+	}
 	if nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha1.CapacityTypeSpot) {
 		for _, instanceType := range nodeRequest.InstanceTypeOptions {
 			for _, offering := range instanceType.Offerings() {
@@ -310,6 +341,55 @@ func (p *InstanceProvider) getCapacityType(nodeRequest *cloudprovider.NodeReques
 	return v1alpha1.CapacityTypeOnDemand
 }
 
+// capacityBlockReservation returns the first capacity block that any of the instance type options are offered in that
+// satisfies the node's zone and capacity reservation requirements. Capacity blocks are only launched into by targeting
+// their reservation, so a single block is launched into at a time.
+func capacityBlockReservation(nodeRequest *cloudprovider.NodeRequest) string {
+	for _, instanceType := range nodeRequest.InstanceTypeOptions {
+		for _, offering := range instanceType.Offerings() {
+			if offering.CapacityType == v1alpha1.CapacityTypeCapacityBlock &&
+				nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone).Has(offering.Zone) &&
+				nodeRequest.Template.Requirements.Get(v1alpha1.LabelCapacityReservationID).Has(offering.CapacityReservationID) {
+				return offering.CapacityReservationID
+			}
+		}
+	}
+	return ""
+}
+
+// hasCapacityReservation returns true if any of the instance type options are offered in a capacity reservation that
+// satisfies the node's zone and capacity reservation requirements
+func hasCapacityReservation(nodeRequest *cloudprovider.NodeRequest) bool {
+	for _, instanceType := range nodeRequest.InstanceTypeOptions {
+		for _, offering := range instanceType.Offerings() {
+			if offering.CapacityReservationID != "" &&
+				nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone).Has(offering.Zone) &&
+				nodeRequest.Template.Requirements.Get(v1alpha1.LabelCapacityReservationID).Has(offering.CapacityReservationID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// targetedCapacityReservation returns the capacity reservation that the node is pinned to, if its requirements allow
+// exactly one capacity reservation and any of the instance type options are offered in it
+func targetedCapacityReservation(nodeRequest *cloudprovider.NodeRequest) string {
+	capacityReservationIDs := nodeRequest.Template.Requirements.Get(v1alpha1.LabelCapacityReservationID)
+	if capacityReservationIDs.IsComplement() || capacityReservationIDs.Len() != 1 {
+		return ""
+	}
+	capacityReservationID := capacityReservationIDs.Any()
+	for _, instanceType := range nodeRequest.InstanceTypeOptions {
+		for _, offering := range instanceType.Offerings() {
+			if offering.CapacityReservationID == capacityReservationID && nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone).Has(offering.Zone) {
+				return capacityReservationID
+			}
+		}
+	}
+	return ""
+}
+
 // filterInstanceTypes is used to eliminate less desirable instance types (like GPUs) from the list of possible instance types when
 // a set of more appropriate instance types would work. If a set of more desirable instance types is not found, then the original slice
 // of instance types are returned.
@@ -332,7 +412,8 @@ func (p *InstanceProvider) filterInstanceTypes(instanceTypes []cloudprovider.Ins
 		itRes := it.Resources()
 		if !resources.IsZero(itRes[v1alpha1.ResourceAWSNeuron]) ||
 			!resources.IsZero(itRes[v1alpha1.ResourceAMDGPU]) ||
-			!resources.IsZero(itRes[v1alpha1.ResourceNVIDIAGPU]) {
+			!resources.IsZero(itRes[v1alpha1.ResourceHabanaGaudi]) ||
+			v1alpha1.HasNVIDIAGPUs(itRes) {
 			continue
 		}
 		genericInstanceTypes = append(genericInstanceTypes, it)
@@ -367,5 +448,8 @@ func getCapacityType(instance *ec2.Instance) string {
 	if instance.SpotInstanceRequestId != nil {
 		return v1alpha1.CapacityTypeSpot
 	}
+	if aws.StringValue(instance.InstanceLifecycle) == v1alpha1.CapacityTypeCapacityBlock {
+		return v1alpha1.CapacityTypeCapacityBlock
+	}
 	return v1alpha1.CapacityTypeOnDemand
 }
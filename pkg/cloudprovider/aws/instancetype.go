@@ -15,15 +15,22 @@ limitations under the License.
 package aws
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/aws/amazon-vpc-resource-controller-k8s/pkg/aws/vpc"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	stringsets "k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/ptr"
 
 	"github.com/aws/karpenter/pkg/apis/provisioning/v1alpha5"
@@ -35,8 +42,135 @@ import (
 	"github.com/aws/karpenter/pkg/utils/sets"
 )
 
-// EC2VMAvailableMemoryFactor assumes the EC2 VM will consume <7.25% of the memory of a given machine
-const EC2VMAvailableMemoryFactor = .925
+// RAID0DiskOverhead is the space mdadm reserves on each member disk of a RAID0 array
+var RAID0DiskOverhead = resource.MustParse("128Mi")
+
+// DefaultImageGCReservationPercentage of the ephemeral volume is reserved for the image cache of providers that don't
+// configure imageGCReservationPercentage. Kubelet only garbage collects images once disk usage exceeds its
+// imageGCHighThresholdPercent, which defaults to 85, so pods can't rely on the rest of the volume.
+var DefaultImageGCReservationPercentage int64 = 15
+
+// GPUReservedMemory is the host memory that the driver of each GPU reserves, e.g. for pinned DMA buffers, keyed by the
+// GPU's lower kabob cased name. Models that aren't listed reserve DefaultGPUReservedMemory.
+var (
+	GPUReservedMemory = map[string]resource.Quantity{
+		"a100": resource.MustParse("1Gi"),
+		"v100": resource.MustParse("512Mi"),
+		"a10g": resource.MustParse("512Mi"),
+		"t4":   resource.MustParse("256Mi"),
+		"t4g":  resource.MustParse("256Mi"),
+		"m60":  resource.MustParse("256Mi"),
+		"k80":  resource.MustParse("256Mi"),
+	}
+	DefaultGPUReservedMemory = resource.MustParse("256Mi")
+	// MIGComputeSlicesPerGPU is the number of compute slices that MIG capable GPUs (e.g. A100 and H100) are divided into
+	MIGComputeSlicesPerGPU int64 = 7
+)
+
+// GPUCostWeights and InferenceAcceleratorCostWeights price each accelerator by model, keyed by its lower kabob cased
+// name. Weights share units with the CPU cost weight, and are approximated from on-demand prices after deducting the
+// cost of the instance's vCPUs and memory. Models that aren't listed fall back to a flat weight.
+var (
+	GPUCostWeights = map[string]float64{
+		"a100":            270,
+		"v100":            250,
+		"a10g":            85,
+		"m60":             45,
+		"t4":              35,
+		"radeon-pro-v520": 35,
+		"k80":             30,
+		"t4g":             30,
+	}
+	InferenceAcceleratorCostWeights = map[string]float64{
+		"trainium":    100,
+		"inferentia2": 60,
+		"inferentia":  12,
+	}
+	// ENAExpressInstanceTypes support ENA Express, which uses the AWS Scalable Reliable Datagram (SRD) protocol to lower
+	// tail latency between instances. EC2 doesn't report support in DescribeInstanceTypes.
+	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ena-express.html
+	ENAExpressInstanceTypes = stringsets.NewString(
+		"c6gn.16xlarge", "c6in.32xlarge", "c7gn.16xlarge", "hpc7g.4xlarge", "hpc7g.8xlarge", "hpc7g.16xlarge",
+		"im4gn.16xlarge", "m6i.32xlarge", "m6id.32xlarge", "m6idn.32xlarge", "m6in.32xlarge", "r6i.32xlarge",
+		"r6id.32xlarge", "r6idn.32xlarge", "r6in.32xlarge", "x2idn.32xlarge", "x2iedn.32xlarge",
+	)
+	// FamilyGPUInterconnects are the instance families whose GPUs communicate directly with each other. GPUs in other
+	// families communicate over PCIe.
+	FamilyGPUInterconnects = map[string]string{
+		"p3":   GPUInterconnectNVLink,
+		"p3dn": GPUInterconnectNVLink,
+		"p4d":  GPUInterconnectNVSwitch,
+		"p4de": GPUInterconnectNVSwitch,
+		"p5":   GPUInterconnectNVSwitch,
+	}
+	// FamilyMemoryBandwidths approximate the peak memory bandwidth, in GB/s, of the largest size of each instance family
+	// from its processor's memory channels. EC2 doesn't report memory bandwidth, so families that aren't listed aren't
+	// labeled.
+	FamilyMemoryBandwidths = map[string]int64{
+		// Intel Xeon Scalable (Skylake, Cascade Lake), 2 sockets of 6 DDR4 channels
+		"c5": 256, "c5d": 256, "c5n": 256, "m5": 256, "m5d": 256, "m5n": 256, "r5": 256, "r5d": 256, "r5n": 256,
+		// Intel Xeon Scalable (Ice Lake), 2 sockets of 8 DDR4 channels
+		"c6i": 410, "c6id": 410, "c6in": 410, "m6i": 410, "m6id": 410, "m6in": 410, "r6i": 410, "r6id": 410, "r6in": 410,
+		"x2idn": 410, "x2iedn": 410,
+		// Intel Xeon Scalable (Sapphire Rapids), 2 sockets of 8 DDR5 channels
+		"c7i": 614, "m7i": 614, "r7i": 614, "r7iz": 614,
+		// AMD EPYC (Milan), 2 sockets of 8 DDR4 channels
+		"c6a": 410, "m6a": 410, "r6a": 410, "hpc6a": 410,
+		// AMD EPYC (Genoa), 2 sockets of 12 DDR5 channels
+		"c7a": 921, "m7a": 921, "r7a": 921, "hpc7a": 921,
+		// AWS Graviton2, 8 DDR4 channels
+		"c6g": 205, "c6gd": 205, "c6gn": 205, "m6g": 205, "m6gd": 205, "r6g": 205, "r6gd": 205, "x2gd": 205,
+		// AWS Graviton3, 8 DDR5 channels
+		"c7g": 307, "c7gd": 307, "c7gn": 307, "m7g": 307, "m7gd": 307, "r7g": 307, "r7gd": 307, "hpc7g": 307,
+	}
+	// FamilyNUMANodeVCPUs are the vCPUs of a single NUMA node, i.e. one processor socket, of each instance family. EC2
+	// doesn't report NUMA topology, so it's inferred from the vCPUs of the instance type, as sizes with more vCPUs than
+	// a socket span multiple sockets. Families that aren't listed aren't labeled.
+	FamilyNUMANodeVCPUs = map[string]int64{
+		// Intel Xeon Scalable (Skylake, Cascade Lake), 2 sockets
+		"c5": 36, "c5d": 36, "c5n": 36, "m5": 48, "m5d": 48, "m5n": 48, "r5": 48, "r5d": 48, "r5n": 48,
+		// Intel Xeon Scalable (Ice Lake), 2 sockets
+		"c6i": 64, "c6id": 64, "c6in": 64, "m6i": 64, "m6id": 64, "m6in": 64, "r6i": 64, "r6id": 64, "r6in": 64,
+		"x2idn": 64, "x2iedn": 64,
+		// Intel Xeon Scalable (Sapphire Rapids), 2 sockets
+		"c7i": 96, "m7i": 96, "r7i": 96,
+		// AMD EPYC (Milan), 2 sockets
+		"c6a": 96, "m6a": 96, "r6a": 96, "hpc6a": 96,
+		// AMD EPYC (Genoa), 2 sockets
+		"c7a": 96, "m7a": 96, "r7a": 96, "hpc7a": 96,
+		// AWS Graviton2 and Graviton3, 1 socket
+		"c6g": 64, "c6gd": 64, "c6gn": 64, "m6g": 64, "m6gd": 64, "r6g": 64, "r6gd": 64, "x2gd": 64,
+		"c7g": 64, "c7gd": 64, "c7gn": 64, "m7g": 64, "m7gd": 64, "r7g": 64, "r7gd": 64, "hpc7g": 64,
+	}
+)
+
+const (
+	GPUInterconnectNVLink   = "nvlink"
+	GPUInterconnectNVSwitch = "nvswitch"
+	GPUInterconnectPCIe     = "pcie"
+)
+
+// GPU memory tiers bucket the memory of each GPU, including the lower bound of each tier. Label values can't contain
+// "<" or ">", so the open ended tiers are spelled out.
+const (
+	GPUMemoryTierLessThan16GB    = "lt-16gb"
+	GPUMemoryTier16To24GB        = "16-24gb"
+	GPUMemoryTier24To48GB        = "24-48gb"
+	GPUMemoryTierGreaterThan48GB = "gt-48gb"
+)
+
+// EC2VMAvailableMemoryNumerator and EC2VMAvailableMemoryDenominator assume the EC2 VM will consume <7.25% of the memory
+// of a given machine. The factor is a ratio of integers so that memory is computed without float rounding.
+const (
+	EC2VMAvailableMemoryNumerator   = 925
+	EC2VMAvailableMemoryDenominator = 1000
+)
+
+// TopologyLayout is the processor layout of an instance type, as modeled by NodeResourceTopology aware schedulers
+type TopologyLayout struct {
+	Sockets        int64
+	CoresPerSocket int64
+}
 
 type InstanceType struct {
 	*ec2.InstanceTypeInfo
@@ -46,12 +180,81 @@ type InstanceType struct {
 	resources    v1.ResourceList
 	provider     *v1alpha1.AWS
 	maxPods      *int32
+	// memoryCorrectionFactor scales the estimated memory, and is refined by recording the allocatable memory of nodes
+	memoryCorrectionFactor float64
+	memoryCorrections      *MemoryCorrections
+	// scorer ranks the offerings of the instance type for selection
+	scorer Scorer
+	// spotInterruptionRating is the rating of the instance type's historical spot interruption rate, if known
+	spotInterruptionRating string
+	// spotPrices are the cached spot prices of the instance type by zone, for the zones they're known in
+	spotPrices map[string]float64
+	// spotPricesExpired is set if the cached spot prices are older than the maximum age, in which case spot offerings
+	// are conservatively priced as on-demand
+	spotPricesExpired bool
+	// unavailableOfferings are offerings that recently saw insufficient capacity errors, which may have happened since
+	// the instance type's offerings were computed
+	unavailableOfferings *cache.Cache
+	// Options the instance type was computed with, so that it can be recomputed for another provider
+	enablePodENI         bool
+	eniLimitedPodDensity bool
+}
+
+// WithProvider returns a copy of the instance type with its resources, overhead, and requirements recomputed for the
+// provider, reusing the EC2 instance type info instead of querying EC2 again. Offerings and the scorer are reused as
+// is, so they aren't constrained by the new provider or its scoring profile.
+func (i *InstanceType) WithProvider(provider *v1alpha1.AWS) *InstanceType {
+	instanceType := *i
+	instanceType.provider = provider
+	instanceType.maxPods = maxPods(i.InstanceTypeInfo, provider, i.eniLimitedPodDensity)
+	instanceType.resources = instanceType.computeResources(i.enablePodENI)
+	instanceType.overhead = instanceType.computeOverhead()
+	instanceType.requirements = instanceType.computeRequirements()
+	return &instanceType
 }
 
 func (i *InstanceType) Name() string {
 	return aws.StringValue(i.InstanceType)
 }
 
+// Hash identifies the instance type along with the provider configuration and options that its resources, overhead,
+// and requirements are computed from, so that data cached per instance type isn't shared across provisioners whose
+// providers compute it differently, e.g. with different AMI families. Offerings aren't included, as they change with
+// availability rather than configuration.
+func (i *InstanceType) Hash() (uint64, error) {
+	providerHash, err := hashProvider(i.provider, i.enablePodENI, i.eniLimitedPodDensity)
+	if err != nil {
+		return 0, fmt.Errorf("hashing instance type %s, %w", i.Name(), err)
+	}
+	hash, err := hashstructure.Hash(struct {
+		Name     string
+		Provider uint64
+	}{i.Name(), providerHash}, hashstructure.FormatV2, nil)
+	if err != nil {
+		return 0, fmt.Errorf("hashing instance type %s, %w", i.Name(), err)
+	}
+	return hash, nil
+}
+
+// hashProvider identifies the provider configuration and options that the resources, overhead, and requirements of
+// instance types are computed from. It's shared by every instance type of the provider, so it's computed once for them.
+func hashProvider(provider *v1alpha1.AWS, enablePodENI bool, eniLimitedPodDensity bool) (uint64, error) {
+	// Quantities only hash their exported fields, so the provider is hashed in its serialized form
+	serialized, err := json.Marshal(provider)
+	if err != nil {
+		return 0, fmt.Errorf("serializing provider, %w", err)
+	}
+	hash, err := hashstructure.Hash(struct {
+		Provider             string
+		EnablePodENI         bool
+		ENILimitedPodDensity bool
+	}{string(serialized), enablePodENI, eniLimitedPodDensity}, hashstructure.FormatV2, nil)
+	if err != nil {
+		return 0, fmt.Errorf("hashing provider, %w", err)
+	}
+	return hash, nil
+}
+
 func (i *InstanceType) Requirements() scheduling.Requirements {
 	return i.requirements
 }
@@ -81,20 +284,28 @@ func (i *InstanceType) Price() float64 {
 		LocalStorageWeight  = 1 / 100.0
 	)
 
-	gpuCount := 0.0
+	gpuCost := 0.0
 	if i.GpuInfo != nil {
 		for _, gpu := range i.GpuInfo.Gpus {
 			if gpu.Count != nil {
-				gpuCount += float64(*gpu.Count)
+				weight, ok := GPUCostWeights[lowerKabobCase(aws.StringValue(gpu.Name))]
+				if !ok {
+					weight = GPUCostWeight
+				}
+				gpuCost += float64(*gpu.Count) * weight
 			}
 		}
 	}
 
-	infCount := 0.0
+	infCost := 0.0
 	if i.InferenceAcceleratorInfo != nil {
 		for _, acc := range i.InferenceAcceleratorInfo.Accelerators {
 			if acc.Count != nil {
-				infCount += float64(*acc.Count)
+				weight, ok := InferenceAcceleratorCostWeights[lowerKabobCase(aws.StringValue(acc.Name))]
+				if !ok {
+					weight = InferenceCostWeight
+				}
+				infCost += float64(*acc.Count) * weight
 			}
 		}
 	}
@@ -104,101 +315,833 @@ func (i *InstanceType) Price() float64 {
 		localStorageGiBs += float64(*i.InstanceStorageInfo.TotalSizeInGB)
 	}
 
-	return CPUCostWeight*float64(*i.VCpuInfo.DefaultVCpus) +
+	price := CPUCostWeight*float64(*i.VCpuInfo.DefaultVCpus) +
 		MemoryMBCostWeight*float64(*i.MemoryInfo.SizeInMiB) +
-		GPUCostWeight*gpuCount + InferenceCostWeight*infCount +
+		gpuCost + infCost +
 		localStorageGiBs*LocalStorageWeight
+
+	// Families covered by reserved instances or savings plans are effectively cheaper
+	if family, _, ok := ParseInstanceType(i.Name()); ok && i.provider != nil {
+		if percentage, ok := i.provider.InstanceFamilyPricePercentages[family]; ok {
+			price *= float64(percentage) / 100
+		}
+	}
+	return price
+}
+
+// String summarizes the instance type as name (vcpu/memory/zones/capacityTypes) for logging
+func (i *InstanceType) String() string {
+	return fmt.Sprintf("%s (vcpu=%s/memory=%s/zones=%s/capacityTypes=%s)", i.Name(), i.resources.Cpu(), i.resources.Memory(),
+		strings.Join(i.requirements.Get(v1.LabelTopologyZone).Values().List(), ","),
+		strings.Join(i.requirements.Get(v1alpha5.LabelCapacityType).Values().List(), ","))
+}
+
+// LogValues returns the instance type's key attributes as alternating keys and values, for structured loggers
+func (i *InstanceType) LogValues() []interface{} {
+	return []interface{}{
+		"instance-type", i.Name(),
+		"vcpu", i.resources.Cpu().String(),
+		"memory", i.resources.Memory().String(),
+		"zones", i.requirements.Get(v1.LabelTopologyZone).Values().List(),
+		"capacity-types", i.requirements.Get(v1alpha5.LabelCapacityType).Values().List(),
+	}
+}
+
+// Compatible returns an error describing the first well known requirement that the instance type can't satisfy.
+// Custom labels are ignored, since they're defined by the provisioner rather than the instance type.
+func (i *InstanceType) Compatible(requirements scheduling.Requirements) error {
+	if err := i.compatibleCapacityTypes(requirements); err != nil {
+		return err
+	}
+	for _, key := range requirements.Keys().Intersection(v1alpha5.WellKnownLabels).List() {
+		if err := i.requirements.Intersects(scheduling.Requirements{key: requirements.Get(key)}, stringsets.NewString(key)); err != nil {
+			return fmt.Errorf("instance type %s not compatible with %s requirement %s", i.Name(), key, requirements.Get(key))
+		}
+	}
+	return nil
+}
+
+// Explain describes, for each well known label in the requirements, the values of the instance type against the
+// requested values and whether they intersect, one key per line. It's meant for debugging why an instance type was
+// rejected.
+func (i *InstanceType) Explain(requirements scheduling.Requirements) string {
+	var lines []string
+	for _, key := range requirements.Keys().Intersection(v1alpha5.WellKnownLabels).List() {
+		result := "compatible"
+		if err := i.requirements.Intersects(scheduling.Requirements{key: requirements.Get(key)}, stringsets.NewString(key)); err != nil {
+			result = "incompatible"
+		}
+		lines = append(lines, fmt.Sprintf("%s: instance type %s has %s, requested %s, %s", key, i.Name(), describeValues(i.requirements.Get(key)), describeValues(requirements.Get(key)), result))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeValues formats the values of a requirement in a stable order
+func describeValues(values sets.Set) string {
+	if values.IsComplement() {
+		if values.ComplementValues().Len() == 0 {
+			return "any value"
+		}
+		return fmt.Sprintf("any value except %v", values.ComplementValues().List())
+	}
+	if values.Len() == 0 {
+		return "no value"
+	}
+	return fmt.Sprintf("%v", values.Values().List())
+}
+
+// compatibleCapacityTypes checks the required capacity types against the capacity types of the instance type's offerings,
+// so that the error names the capacity types that aren't offered
+func (i *InstanceType) compatibleCapacityTypes(requirements scheduling.Requirements) error {
+	required, ok := requirements[v1alpha5.LabelCapacityType]
+	if !ok {
+		return nil
+	}
+	offered := lo.Uniq(lo.Map(i.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.CapacityType }))
+	if lo.ContainsBy(offered, required.Has) {
+		return nil
+	}
+	if required.IsComplement() {
+		return fmt.Errorf("instance type %s has no offerings for capacity types other than %v, offered capacity types are %v", i.Name(), required.ComplementValues().List(), offered)
+	}
+	return fmt.Errorf("instance type %s has no offerings for capacity types %v, offered capacity types are %v", i.Name(), required.Values().List(), offered)
+}
+
+// OfferingPrice is Price() for a specific offering. Spot offerings are priced at their cached spot price, or if it
+// isn't known, discounted by the provider's spot discount so that they rank below on-demand offerings of the same
+// instance type. If the cached spot prices have expired, spot offerings are priced as on-demand. Capacity reservations,
+// including capacity blocks, are paid for whether or not they're used, so launching into one costs nothing more.
+func (i *InstanceType) OfferingPrice(offering cloudprovider.Offering) float64 {
+	if offering.CapacityReservationID != "" {
+		return 0
+	}
+	price := i.Price()
+	if offering.CapacityType == v1alpha1.CapacityTypeSpot {
+		if spotPrice, ok := i.spotPrices[offering.Zone]; ok {
+			return spotPrice
+		}
+		if i.spotPricesExpired {
+			return price
+		}
+		price *= 1 - float64(aws.Int64Value(i.provider.SpotDiscountPercentage))/100
+	}
+	return price
+}
+
+// CheapestOffering is the lowest priced offering of the instance type that's still available, preferring the first of
+// equally priced offerings. It returns false if no offerings are available.
+func (i *InstanceType) CheapestOffering() (cloudprovider.Offering, bool) {
+	var cheapest cloudprovider.Offering
+	found := false
+	for _, offering := range i.offerings {
+		if !i.available(offering) {
+			continue
+		}
+		if !found || i.OfferingPrice(offering) < i.OfferingPrice(cheapest) {
+			cheapest, found = offering, true
+		}
+	}
+	return cheapest, found
+}
+
+// available is false for offerings that have seen an insufficient capacity error since they were computed. Capacity
+// reservations are never considered unavailable.
+func (i *InstanceType) available(offering cloudprovider.Offering) bool {
+	if i.unavailableOfferings == nil || offering.CapacityReservationID != "" {
+		return true
+	}
+	_, unavailable := i.unavailableOfferings.Get(UnavailableOfferingsCacheKey(i.Name(), offering.Zone, offering.CapacityType))
+	return !unavailable
+}
+
+// Scorer ranks an offering of an instance type for selection, where lower scores are preferred. It allows selection to
+// optimize for something other than price, e.g. carbon intensity or spot stability.
+type Scorer interface {
+	Score(instanceType *InstanceType, offering cloudprovider.Offering) float64
+}
+
+// PriceScorer is the default Scorer, which scores every offering by the synthetic price of its instance type
+type PriceScorer struct{}
+
+func (PriceScorer) Score(instanceType *InstanceType, _ cloudprovider.Offering) float64 {
+	return instanceType.Price()
+}
+
+// Score is the best score of any of the instance type's offerings, falling back to its price if it has none
+func (i *InstanceType) Score() float64 {
+	if i.scorer == nil || len(i.offerings) == 0 {
+		return i.Price()
+	}
+	return lo.Min(lo.Map(i.offerings, func(offering cloudprovider.Offering, _ int) float64 {
+		return i.scorer.Score(i, offering)
+	}))
+}
+
+// ResourceScore combines vCPU, memory, and pod capacity into a single value, weighting each by the proportions of a
+// general purpose instance (4GiB of memory and ~15 pods per vCPU) so that they contribute equally. It's used to break
+// ties between equally priced instance types, where a higher score offers more capacity.
+func (i *InstanceType) ResourceScore() float64 {
+	const (
+		MemoryGiBPerVCPU = 4.0
+		PodsPerVCPU      = 15.0
+	)
+	return float64(i.resources.Cpu().MilliValue())/1000 +
+		float64(i.resources.Memory().Value())/(1<<30)/MemoryGiBPerVCPU +
+		float64(i.resources.Pods().Value())/PodsPerVCPU
+}
+
+// PricePerVCPU normalizes Price() by the number of vCPUs, so that instance types can be ranked by cost efficiency
+// rather than absolute price. Instance types without vCPUs are infinitely expensive.
+func (i *InstanceType) PricePerVCPU() float64 {
+	vcpus := float64(i.resources.Cpu().MilliValue()) / 1000
+	if vcpus <= 0 {
+		return math.Inf(1)
+	}
+	return i.Price() / vcpus
+}
+
+// memoryPerVCPU is the GiB of memory estimated to be available for each vCPU
+func (i *InstanceType) memoryPerVCPU() float64 {
+	memory, cpu := i.memory(), i.cpu()
+	if cpu.IsZero() {
+		return 0
+	}
+	return float64(memory.Value()) / (1 << 30) / (float64(cpu.MilliValue()) / 1000)
+}
+
+// PricePerGiBMemory normalizes Price() by the GiB of memory available to pods. Instance types without memory are
+// infinitely expensive.
+func (i *InstanceType) PricePerGiBMemory() float64 {
+	gib := float64(i.resources.Memory().Value()) / (1 << 30)
+	if gib <= 0 {
+		return math.Inf(1)
+	}
+	return i.Price() / gib
+}
+
+// PricePerPod normalizes Price() by the number of pods that fit on the instance type, so that dense instance types can
+// be preferred. Instance types without pods are infinitely expensive.
+func (i *InstanceType) PricePerPod() float64 {
+	pods := i.resources.Pods().Value()
+	if pods <= 0 {
+		return math.Inf(1)
+	}
+	return i.Price() / float64(pods)
+}
+
+// dedicatedHostOnly returns true for instance types, such as mac instances, that can't be launched as on-demand or spot
+// instances and must be placed on a dedicated host
+func dedicatedHostOnly(info *ec2.InstanceTypeInfo) bool {
+	usageClasses := aws.StringValueSlice(info.SupportedUsageClasses)
+	return !lo.Contains(usageClasses, ec2.UsageClassTypeOnDemand) && !lo.Contains(usageClasses, ec2.UsageClassTypeSpot)
+}
+
+// numaNodes infers the number of NUMA nodes of the instance type from the vCPUs of a NUMA node in its family
+func (i *InstanceType) numaNodes(family string) (int64, bool) {
+	nodeVCPUs, ok := FamilyNUMANodeVCPUs[family]
+	if !ok {
+		return 0, false
+	}
+	vcpus := aws.Int64Value(i.VCpuInfo.DefaultVCpus)
+	return (vcpus + nodeVCPUs - 1) / nodeVCPUs, true
+}
+
+// Topology returns the sockets of the instance type and the physical cores of each socket. Each socket is a NUMA node,
+// so the layout is only known for families with a known NUMA node size and instance types that report their cores.
+func (i *InstanceType) Topology() (TopologyLayout, bool) {
+	family, _, ok := ParseInstanceType(i.Name())
+	if !ok || i.VCpuInfo == nil || aws.Int64Value(i.VCpuInfo.DefaultCores) <= 0 {
+		return TopologyLayout{}, false
+	}
+	sockets, ok := i.numaNodes(family)
+	if !ok || sockets <= 0 {
+		return TopologyLayout{}, false
+	}
+	return TopologyLayout{Sockets: sockets, CoresPerSocket: aws.Int64Value(i.VCpuInfo.DefaultCores) / sockets}, true
+}
+
+// operatingSystem returns macos for mac instance types, which only run macOS, and linux otherwise
+func operatingSystem(info *ec2.InstanceTypeInfo) string {
+	if family, _, ok := ParseInstanceType(aws.StringValue(info.InstanceType)); ok && strings.HasPrefix(family, "mac") {
+		return v1alpha5.OperatingSystemMacOS
+	}
+	return v1alpha5.OperatingSystemLinux
+}
+
+// InstanceTypesForResources returns the instance types whose resources cover the request, including extended resources
+// such as GPUs. Resources that an instance type doesn't advertise are treated as zero.
+func InstanceTypesForResources(instanceTypes []*InstanceType, request v1.ResourceList) []*InstanceType {
+	return lo.Filter(instanceTypes, func(instanceType *InstanceType, _ int) bool {
+		return resources.Fits(request, instanceType.Resources())
+	})
+}
+
+// SortByEfficiency orders the instance types by how tightly their resources fit the request, so that the instance
+// type that wastes the least capacity is first. Instance types that can't fit the request are sorted last, and ties
+// are broken by name.
+func SortByEfficiency(instanceTypes []*InstanceType, request v1.ResourceList) {
+	waste := map[string]float64{}
+	for _, instanceType := range instanceTypes {
+		waste[instanceType.Name()] = instanceType.waste(request)
+	}
+	sort.Slice(instanceTypes, func(a, b int) bool {
+		if wasteA, wasteB := waste[instanceTypes[a].Name()], waste[instanceTypes[b].Name()]; wasteA != wasteB {
+			return wasteA < wasteB
+		}
+		return instanceTypes[a].Name() < instanceTypes[b].Name()
+	})
+}
+
+// waste sums the fraction of each requested resource's capacity that's left unused by the request, e.g. 0.5 for an
+// instance type with twice the requested cpu. Instance types that can't fit the request waste infinitely.
+func (i *InstanceType) waste(request v1.ResourceList) float64 {
+	if !resources.Fits(request, i.Resources()) {
+		return math.Inf(1)
+	}
+	waste := 0.0
+	for name, quantity := range request {
+		capacity := i.Resources()[name]
+		if quantity.IsZero() || capacity.IsZero() {
+			continue
+		}
+		waste += 1 - float64(quantity.MilliValue())/float64(capacity.MilliValue())
+	}
+	return waste
 }
 
 func (i *InstanceType) computeRequirements() scheduling.Requirements {
+	zones, capacityTypes := i.offeringSets()
 	requirements := scheduling.Requirements{
 		// Well Known Upstream
 		v1.LabelInstanceTypeStable: sets.NewSet(i.Name()),
-		v1.LabelArchStable:         sets.NewSet(i.architecture()),
-		v1.LabelOSStable:           sets.NewSet(v1alpha5.OperatingSystemLinux),
-		v1.LabelTopologyZone:       sets.NewSet(lo.Map(i.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.Zone })...),
-		v1alpha5.LabelCapacityType: sets.NewSet(lo.Map(i.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.CapacityType })...),
+		v1.LabelOSStable:           sets.NewSet(operatingSystem(i.InstanceTypeInfo)),
+		v1.LabelTopologyZone:       zones,
+		v1alpha5.LabelCapacityType: capacityTypes,
 		// Resources
 		v1alpha1.InstanceCPULabelKey:    sets.NewSet(fmt.Sprint(aws.Int64Value(i.VCpuInfo.DefaultVCpus))),
 		v1alpha1.InstanceMemoryLabelKey: sets.NewSet(fmt.Sprint(aws.Int64Value(i.MemoryInfo.SizeInMiB))),
+		// Networking
+		v1alpha1.InstanceMaxENIsLabelKey:             sets.NewSet(fmt.Sprint(aws.Int64Value(i.NetworkInfo.MaximumNetworkInterfaces))),
+		v1alpha1.InstanceEncryptionInTransitLabelKey: sets.NewSet(fmt.Sprint(aws.BoolValue(i.NetworkInfo.EncryptionInTransitSupported))),
+		v1alpha1.InstanceENAExpressLabelKey:          sets.NewSet(fmt.Sprint(ENAExpressInstanceTypes.Has(i.Name()))),
+		// Availability
+		v1alpha1.InstanceZoneCountLabelKey:         sets.NewSet(fmt.Sprint(zones.Len())),
+		v1alpha1.InstanceZoneTypeLabelKey:          sets.NewSet(lo.Map(zones.Values().UnsortedList(), func(zone string, _ int) string { return zoneType(zone) })...),
+		v1alpha1.InstanceDedicatedHostOnlyLabelKey: sets.NewSet(fmt.Sprint(dedicatedHostOnly(i.InstanceTypeInfo))),
+		// Storage
+		v1alpha1.InstanceEBSDedicatedBandwidthLabelKey: sets.NewSet(fmt.Sprint(i.ebsDedicatedBandwidth())),
+	}
+	// Instance types with an unrecognized architecture don't satisfy any architecture requirement
+	requirements[v1.LabelArchStable] = sets.NewSet()
+	if architecture, err := i.architecture(); err == nil {
+		requirements[v1.LabelArchStable] = sets.NewSet(architecture)
+	}
+	// Spot Interruption Label, so that spot workloads can prefer instance types that are rarely interrupted. Unrated
+	// instance types don't satisfy any rating, rather than the well known label being implicitly supported.
+	requirements[v1alpha1.InstanceSpotInterruptionRateLabelKey] = sets.NewSet()
+	if i.spotInterruptionRating != "" {
+		requirements[v1alpha1.InstanceSpotInterruptionRateLabelKey] = sets.NewSet(i.spotInterruptionRating)
 	}
 	// Instance Type Labels
-	instanceTypeParts := strings.Split(aws.StringValue(i.InstanceType), ".")
-	if len(instanceTypeParts) == 2 {
+	if family, size, ok := ParseInstanceType(i.Name()); ok {
 		requirements.Add(scheduling.Requirements{
-			v1alpha1.InstanceFamilyLabelKey: sets.NewSet(instanceTypeParts[0]),
-			v1alpha1.InstanceSizeLabelKey:   sets.NewSet(instanceTypeParts[1]),
+			v1alpha1.InstanceFamilyLabelKey: sets.NewSet(family),
+			v1alpha1.InstanceSizeLabelKey:   sets.NewSet(size),
 		})
+		if ordinal, ok := instanceSizeOrdinal(size); ok {
+			requirements[v1alpha1.InstanceSizeOrdinalLabelKey] = sets.NewSet(fmt.Sprint(ordinal))
+		}
+		if bandwidth, ok := FamilyMemoryBandwidths[family]; ok {
+			requirements[v1alpha1.InstanceMemoryBandwidthLabelKey] = sets.NewSet(fmt.Sprint(bandwidth))
+		}
+		if numaNodes, ok := i.numaNodes(family); ok {
+			requirements[v1alpha1.InstanceNUMANodesLabelKey] = sets.NewSet(fmt.Sprint(numaNodes))
+		}
+	}
+	// Topology Labels, e.g. for the CPU manager to align exclusive cores to a socket
+	if topology, ok := i.Topology(); ok {
+		requirements[v1alpha1.InstanceSocketsLabelKey] = sets.NewSet(fmt.Sprint(topology.Sockets))
+		requirements[v1alpha1.InstanceCoresPerSocketLabelKey] = sets.NewSet(fmt.Sprint(topology.CoresPerSocket))
+	}
+	// Storage Labels
+	if rootDeviceTypes := aws.StringValueSlice(i.SupportedRootDeviceTypes); len(rootDeviceTypes) > 0 {
+		requirements[v1alpha1.InstanceRootDeviceTypeLabelKey] = sets.NewSet(rootDeviceTypes...)
+	}
+	if i.EbsInfo != nil && i.EbsInfo.EbsOptimizedInfo != nil && i.EbsInfo.EbsOptimizedInfo.BaselineIops != nil {
+		requirements[v1alpha1.InstanceEBSIOPSLabelKey] = sets.NewSet(fmt.Sprint(aws.Int64Value(i.EbsInfo.EbsOptimizedInfo.BaselineIops)))
+	}
+	// NVMe Support Label, since EBS volumes of NVMe instances aren't exposed as /dev/xvd* devices
+	if i.EbsInfo != nil && aws.StringValue(i.EbsInfo.NvmeSupport) != "" {
+		requirements[v1alpha1.InstanceNVMeSupportLabelKey] = sets.NewSet(aws.StringValue(i.EbsInfo.NvmeSupport))
+	}
+	// Local Disk Labels, e.g. for workloads that combine instance store disks into a RAID array
+	if i.InstanceStorageInfo != nil && len(i.InstanceStorageInfo.Disks) > 0 {
+		requirements[v1alpha1.InstanceLocalDiskCountLabelKey] = sets.NewSet(fmt.Sprint(lo.SumBy(i.InstanceStorageInfo.Disks, func(disk *ec2.DiskInfo) int64 {
+			return aws.Int64Value(disk.Count)
+		})))
+		requirements[v1alpha1.InstanceLocalDiskTypeLabelKey] = sets.NewSet(lo.Map(i.InstanceStorageInfo.Disks, func(disk *ec2.DiskInfo, _ int) string {
+			return aws.StringValue(disk.Type)
+		})...)
+	}
+	// Boot Mode Labels, so that instances match the boot mode of custom AMIs
+	if bootModes := aws.StringValueSlice(i.SupportedBootModes); len(bootModes) > 0 {
+		requirements[v1alpha1.InstanceSupportedBootModesLabelKey] = sets.NewSet(bootModes...)
+	}
+	// Virtualization Type Labels, so that instances match the virtualization type of custom AMIs
+	if virtualizationTypes := aws.StringValueSlice(i.SupportedVirtualizationTypes); len(virtualizationTypes) > 0 {
+		requirements[v1alpha1.InstanceVirtualizationTypeLabelKey] = sets.NewSet(virtualizationTypes...)
+	}
+	// Capacity Reservation Labels. The label is well known, so instance types without a reservation must explicitly not
+	// satisfy requirements for one, rather than being implicitly compatible with them.
+	requirements[v1alpha1.LabelCapacityReservationID] = sets.NewSet(i.capacityReservationIDs()...)
+	// Nested virtualization (e.g. KVM) requires direct access to the hardware
+	if i.nestedVirtualization() {
+		requirements[v1alpha1.InstanceNestedVirtualizationLabelKey] = sets.NewSet("true")
+	}
+	// Accelerator Labels
+	if accelerators := i.accelerators(); accelerators.Len() > 0 {
+		requirements[v1alpha1.InstanceAcceleratorLabelKey] = accelerators
+	}
+	// Inferentia and Trainium Labels, paralleling the GPU labels
+	if i.InferenceAcceleratorInfo != nil && len(i.InferenceAcceleratorInfo.Accelerators) == 1 {
+		accelerator := i.InferenceAcceleratorInfo.Accelerators[0]
+		if name := lowerKabobCase(aws.StringValue(accelerator.Name)); name != "" {
+			requirements[v1alpha1.InstanceAcceleratorNameLabelKey] = sets.NewSet(name)
+		}
+		if manufacturer := lowerKabobCase(aws.StringValue(accelerator.Manufacturer)); manufacturer != "" {
+			requirements[v1alpha1.InstanceAcceleratorManufacturerLabelKey] = sets.NewSet(manufacturer)
+		}
 	}
 	// GPU Labels
+	if i.GpuInfo != nil && len(i.GpuInfo.Gpus) > 0 {
+		// The count is the total across all GPUs, even if they're described separately
+		requirements[v1alpha1.InstanceGPUCountLabelKey] = sets.NewSet(fmt.Sprint(lo.SumBy(i.GpuInfo.Gpus, func(gpu *ec2.GpuDeviceInfo) int64 {
+			return aws.Int64Value(gpu.Count)
+		})))
+	}
+	if interconnect, ok := i.gpuInterconnect(); ok {
+		requirements[v1alpha1.InstanceGPUInterconnectLabelKey] = sets.NewSet(interconnect)
+	}
 	if i.GpuInfo != nil && len(i.GpuInfo.Gpus) == 1 {
 		gpu := i.GpuInfo.Gpus[0]
 		requirements.Add(scheduling.Requirements{
 			v1alpha1.InstanceGPUNameLabelKey:         sets.NewSet(lowerKabobCase(aws.StringValue(gpu.Name))),
 			v1alpha1.InstanceGPUManufacturerLabelKey: sets.NewSet(lowerKabobCase(aws.StringValue(gpu.Manufacturer))),
-			v1alpha1.InstanceGPUCountLabelKey:        sets.NewSet(fmt.Sprint(aws.Int64Value(gpu.Count))),
 			v1alpha1.InstanceGPUMemoryLabelKey:       sets.NewSet(fmt.Sprint(aws.Int64Value(gpu.MemoryInfo.SizeInMiB))),
+			v1alpha1.InstanceGPUMemoryTierLabelKey:   sets.NewSet(gpuMemoryTier(aws.Int64Value(gpu.MemoryInfo.SizeInMiB))),
 		})
-
 	}
 	return requirements
 }
 
+// offeringSets returns the zones and capacity types of the instance type's offerings, built in a single pass without
+// intermediate slices. Offerings in single zone clusters all share a zone, which is then only inserted once.
+func (i *InstanceType) offeringSets() (sets.Set, sets.Set) {
+	zones, capacityTypes := sets.NewSet(), sets.NewSet()
+	offerings := i.Offerings()
+	if len(offerings) == 0 {
+		return zones, capacityTypes
+	}
+	zones.Insert(offerings[0].Zone)
+	for _, offering := range offerings {
+		if offering.Zone != offerings[0].Zone {
+			zones.Insert(offering.Zone)
+		}
+		capacityTypes.Insert(offering.CapacityType)
+	}
+	return zones, capacityTypes
+}
+
+// capacityReservationIDs returns the capacity reservations that the instance type is offered in
+func (i *InstanceType) capacityReservationIDs() []string {
+	return lo.Uniq(lo.FilterMap(i.Offerings(), func(o cloudprovider.Offering, _ int) (string, bool) {
+		return o.CapacityReservationID, o.CapacityReservationID != ""
+	}))
+}
+
+// gpuInterconnect is how the instance's GPUs communicate with each other, if it has GPUs
+func (i *InstanceType) gpuInterconnect() (string, bool) {
+	if i.GpuInfo == nil || len(i.GpuInfo.Gpus) == 0 {
+		return "", false
+	}
+	if family, _, ok := ParseInstanceType(i.Name()); ok {
+		if interconnect, ok := FamilyGPUInterconnects[family]; ok {
+			return interconnect, true
+		}
+	}
+	return GPUInterconnectPCIe, true
+}
+
+// gpuMemoryTier buckets the memory of a single GPU so that workloads can select a minimum without listing every size
+func gpuMemoryTier(sizeInMiB int64) string {
+	switch gib := sizeInMiB / 1024; {
+	case gib < 16:
+		return GPUMemoryTierLessThan16GB
+	case gib < 24:
+		return GPUMemoryTier16To24GB
+	case gib < 48:
+		return GPUMemoryTier24To48GB
+	default:
+		return GPUMemoryTierGreaterThan48GB
+	}
+}
+
+// nestedVirtualization is true for bare metal instances, which are the only instances that expose hardware
+// virtualization extensions (e.g. VT-x) to the operating system
+func (i *InstanceType) nestedVirtualization() bool {
+	if aws.BoolValue(i.BareMetal) {
+		return true
+	}
+	_, size, ok := ParseInstanceType(i.Name())
+	return ok && strings.HasPrefix(size, "metal")
+}
+
+// accelerators returns the kinds of accelerators on the instance, if any
+func (i *InstanceType) accelerators() sets.Set {
+	accelerators := sets.NewSet()
+	if i.GpuInfo != nil && len(i.GpuInfo.Gpus) > 0 {
+		accelerators.Insert(v1alpha1.AcceleratorGPU)
+	}
+	if i.InferenceAcceleratorInfo != nil {
+		for _, accelerator := range i.InferenceAcceleratorInfo.Accelerators {
+			// EC2 describes both Inferentia and Trainium as inference accelerators
+			if strings.HasPrefix(strings.ToLower(aws.StringValue(accelerator.Name)), v1alpha1.AcceleratorTrainium) {
+				accelerators.Insert(v1alpha1.AcceleratorTrainium)
+			} else {
+				accelerators.Insert(v1alpha1.AcceleratorInferentia)
+			}
+		}
+	}
+	return accelerators
+}
+
+// kubeArchitectures merges the provider's architecture mappings into the built-in mappings, which take precedence
+func kubeArchitectures(provider *v1alpha1.AWS) map[string]string {
+	if provider == nil || len(provider.ArchitectureMappings) == 0 {
+		return v1alpha1.AWSToKubeArchitectures
+	}
+	architectures := lo.Assign(provider.ArchitectureMappings)
+	for architecture, kubeArchitecture := range v1alpha1.AWSToKubeArchitectures {
+		architectures[architecture] = kubeArchitecture
+	}
+	return architectures
+}
+
 // Setting ephemeral-storage to be either the default value or what is defined in blockDeviceMappings
-func (i *InstanceType) architecture() string {
+func (i *InstanceType) architecture() (string, error) {
+	architectures := kubeArchitectures(i.provider)
 	for _, architecture := range i.ProcessorInfo.SupportedArchitectures {
-		if value, ok := v1alpha1.AWSToKubeArchitectures[aws.StringValue(architecture)]; ok {
-			return value
+		if value, ok := architectures[aws.StringValue(architecture)]; ok {
+			return value, nil
 		}
 	}
-	return fmt.Sprint(aws.StringValueSlice(i.ProcessorInfo.SupportedArchitectures)) // Unrecognized, but used for error printing
+	return "", fmt.Errorf("unrecognized architectures %v", aws.StringValueSlice(i.ProcessorInfo.SupportedArchitectures))
 }
 
 func (i *InstanceType) computeResources(enablePodENI bool) v1.ResourceList {
-	return v1.ResourceList{
-		v1.ResourceCPU:              i.cpu(),
-		v1.ResourceMemory:           i.memory(),
-		v1.ResourceEphemeralStorage: i.ephemeralStorage(),
-		v1.ResourcePods:             i.pods(),
-		v1alpha1.ResourceAWSPodENI:  i.awsPodENI(enablePodENI),
-		v1alpha1.ResourceNVIDIAGPU:  i.nvidiaGPUs(),
-		v1alpha1.ResourceAMDGPU:     i.amdGPUs(),
-		v1alpha1.ResourceAWSNeuron:  i.awsNeurons(),
-		v1alpha1.ResourceSmarterDevicesFuse:  i.smarterDevicesFuse(),
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:               i.cpu(),
+		v1.ResourceMemory:            i.memory(),
+		v1.ResourceEphemeralStorage:  i.ephemeralStorage(),
+		v1.ResourcePods:              i.pods(),
+		v1alpha1.ResourceAWSPodENI:   i.awsPodENI(enablePodENI),
+		v1alpha1.ResourceNVIDIAGPU:   i.nvidiaGPUs(),
+		v1alpha1.ResourceAMDGPU:      i.amdGPUs(),
+		v1alpha1.ResourceAWSNeuron:   i.awsNeurons(),
+		v1alpha1.ResourceHabanaGaudi: i.habanaGaudis(),
+	}
+	for name, quantity := range i.extraResources() {
+		if !quantity.IsZero() {
+			capacity[name] = quantity
+		}
+	}
+	for name, quantity := range i.provider.HugePages {
+		capacity[name] = quantity
+	}
+	if aws.BoolValue(i.provider.EnableGPUMemoryResource) {
+		capacity[v1alpha1.ResourceGPUMemory] = i.gpuMemory()
 	}
+	if aws.BoolValue(i.provider.EnableLocalStorageResource) {
+		capacity[v1alpha1.ResourceLocalStorage] = i.localStorage()
+	}
+	if profile, ok := i.migProfile(); ok {
+		if devices := i.nvidiaMIGDevices(profile); !devices.IsZero() {
+			capacity[v1.ResourceName(v1alpha1.ResourceNVIDIAMIGPrefix+profile)] = devices
+		}
+	}
+	return capacity
 }
 
+// ebsDedicatedBandwidth is true if the instance type is EBS optimized by default, so EBS has bandwidth dedicated to it
+// rather than sharing the network bandwidth of the instance
+func (i *InstanceType) ebsDedicatedBandwidth() bool {
+	if i.EbsInfo == nil || i.EbsInfo.EbsOptimizedInfo == nil {
+		return false
+	}
+	return aws.StringValue(i.EbsInfo.EbsOptimizedSupport) == ec2.EbsOptimizedSupportDefault && i.EbsInfo.EbsOptimizedInfo.BaselineBandwidthInMbps != nil
+}
+
+// cpu is the vCPU capacity available to pods. Burstable instance types are limited to the provider's baseline
+// fraction of each vCPU, if configured.
 func (i *InstanceType) cpu() resource.Quantity {
+	if baseline := i.provider.BurstableVCPUBaseline; baseline != nil && aws.BoolValue(i.BurstablePerformanceSupported) {
+		return *resource.NewMilliQuantity(aws.Int64Value(i.VCpuInfo.DefaultVCpus)*baseline.MilliValue(), resource.DecimalSI)
+	}
+	return i.vcpus()
+}
+
+// vcpus is the number of vCPUs of the instance type, regardless of any burstable baseline
+func (i *InstanceType) vcpus() resource.Quantity {
 	return *resources.Quantity(fmt.Sprint(*i.VCpuInfo.DefaultVCpus))
 }
 
 func (i *InstanceType) memory() resource.Quantity {
-	return *resources.Quantity(
-		fmt.Sprintf("%dMi", int32(
-			float64(*i.MemoryInfo.SizeInMiB)*EC2VMAvailableMemoryFactor,
-		)),
-	)
+	// int64 MiB can't overflow for any instance type, even after scaling by the numerator
+	mib := aws.Int64Value(i.MemoryInfo.SizeInMiB) * EC2VMAvailableMemoryNumerator / EC2VMAvailableMemoryDenominator
+	if i.memoryCorrectionFactor != 1 {
+		mib = int64(float64(mib) * i.memoryCorrectionFactor)
+	}
+	memory := *resource.NewQuantity(mib*1024*1024, resource.BinarySI)
+	// Huge pages are preallocated by the kernel and can't be used as regular memory
+	memory.Sub(i.hugePages())
+	memory.Sub(i.gpuReservedMemory())
+	return memory
+}
+
+// gpuReservedMemory is the host memory reserved by the drivers of the instance type's GPUs, preferring the provider's
+// reservation for each GPU model over the defaults
+func (i *InstanceType) gpuReservedMemory() resource.Quantity {
+	total := resource.Quantity{Format: resource.BinarySI}
+	if i.GpuInfo == nil {
+		return total
+	}
+	for _, gpu := range i.GpuInfo.Gpus {
+		name := lowerKabobCase(aws.StringValue(gpu.Name))
+		reserved, ok := i.provider.GPUReservedMemory[name]
+		if !ok {
+			if reserved, ok = GPUReservedMemory[name]; !ok {
+				reserved = DefaultGPUReservedMemory
+			}
+		}
+		total.Add(*resource.NewQuantity(reserved.Value()*aws.Int64Value(gpu.Count), resource.BinarySI))
+	}
+	return total
+}
+
+// RecordAllocatable refines the memory correction for the instance type from the allocatable memory reported by kubelet.
+// The overhead is added back, since the correction applies to memory before overhead is reserved.
+func (i *InstanceType) RecordAllocatable(allocatable v1.ResourceList) {
+	if i.memoryCorrections == nil {
+		return
+	}
+	observed := allocatable.Memory().DeepCopy()
+	observed.Add(*i.overhead.Memory())
+	i.memoryCorrections.Record(i.Name(), i.memoryCorrectionFactor, *i.resources.Memory(), observed)
+}
+
+// hugePages is the total memory reserved for huge pages across all page sizes
+func (i *InstanceType) hugePages() resource.Quantity {
+	total := resource.Quantity{Format: resource.BinarySI}
+	for _, quantity := range i.provider.HugePages {
+		total.Add(quantity)
+	}
+	return total
+}
+
+// fitsHugePages is false if the memory left after reserving huge pages can't cover the system overhead
+func (i *InstanceType) fitsHugePages() bool {
+	return i.resources.Memory().Cmp(*i.overhead.Memory()) > 0
 }
 
 // Setting ephemeral-storage to be either the default value or what is defined in blockDeviceMappings
 func (i *InstanceType) ephemeralStorage() resource.Quantity {
-	ephemeralBlockDevice := amifamily.GetAMIFamily(i.provider.AMIFamily, &amifamily.Options{}).EphemeralBlockDevice()
-	if i.provider.BlockDeviceMappings != nil {
-		for _, blockDevice := range i.provider.BlockDeviceMappings {
-			// If a block device mapping exists in the provider for the root volume, set the volume size specified in the provider
-			if *blockDevice.DeviceName == *ephemeralBlockDevice {
-				return *blockDevice.EBS.VolumeSize
+	if aws.StringValue(i.provider.InstanceStorePolicy) == v1alpha1.InstanceStorePolicyRAID0 {
+		if size, ok := i.instanceStoreRAID0Size(); ok {
+			// Swap is carved out of the same instance store
+			if i.provider.Swap != nil {
+				size.Sub(*i.provider.Swap)
+			}
+			return size
+		}
+	}
+	return ephemeralVolumeSize(i.provider)
+}
+
+// fitsSwap is false if the provider configures swap and the instance's NVMe instance store is too small to hold it
+func (i *InstanceType) fitsSwap() bool {
+	if i.provider.Swap == nil {
+		return true
+	}
+	size, ok := i.instanceStoreRAID0Size()
+	return ok && size.Cmp(*i.provider.Swap) > 0
+}
+
+// instanceStoreRAID0Size is the size of a RAID0 array across all of the instance's NVMe instance store disks. Arrays
+// of multiple disks lose the space mdadm reserves on each disk for its superblock and data offset.
+func (i *InstanceType) instanceStoreRAID0Size() (resource.Quantity, bool) {
+	if i.InstanceStorageInfo == nil || aws.StringValue(i.InstanceStorageInfo.NvmeSupport) == ec2.EphemeralNvmeSupportUnsupported {
+		return resource.Quantity{}, false
+	}
+	disks, gigabytes := int64(0), int64(0)
+	for _, disk := range i.InstanceStorageInfo.Disks {
+		disks += aws.Int64Value(disk.Count)
+		gigabytes += aws.Int64Value(disk.Count) * aws.Int64Value(disk.SizeInGB)
+	}
+	if disks == 0 {
+		return resource.Quantity{}, false
+	}
+	size := *resource.NewScaledQuantity(gigabytes, resource.Giga)
+	if disks > 1 {
+		size.Sub(*resource.NewQuantity(disks*RAID0DiskOverhead.Value(), resource.BinarySI))
+	}
+	return size, true
+}
+
+// RootDevice is the name of the device that backs ephemeral storage for the provider's AMI family, e.g. /dev/xvda, or
+// /dev/xvdb for Bottlerocket's data volume
+func (i *InstanceType) RootDevice() string {
+	return rootDevice(i.provider)
+}
+
+// EphemeralDevice is the name of the device that ephemeral storage is resolved against, which is the containerd data
+// device if the provider has one, or the AMI family's ephemeral block device
+func (i *InstanceType) EphemeralDevice() string {
+	return ephemeralDevice(i.provider)
+}
+
+func rootDevice(provider *v1alpha1.AWS) string {
+	return aws.StringValue(amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{}).EphemeralBlockDevice())
+}
+
+// ephemeralDevice is the name of the device that backs ephemeral storage, which is the containerd data device if the
+// provider has one, or the AMI family's root device
+func ephemeralDevice(provider *v1alpha1.AWS) string {
+	if provider.ContainerdDataDevice != nil {
+		return *provider.ContainerdDataDevice
+	}
+	return rootDevice(provider)
+}
+
+// ephemeralVolumeSize is the size of the EBS volume that backs ephemeral storage. Without a block device mapping for it,
+// it's the provider's default volume size, or the size in the AMI family's default block device mappings.
+func ephemeralVolumeSize(provider *v1alpha1.AWS) resource.Quantity {
+	if provider.BlockDeviceMappings != nil {
+		for _, blockDevice := range provider.BlockDeviceMappings {
+			// If a block device mapping exists in the provider for the ephemeral volume, set the volume size specified in the provider.
+			// The size may be omitted when restoring from a snapshot, so fall back to the default.
+			if aws.StringValue(blockDevice.DeviceName) == ephemeralDevice(provider) {
+				if blockDevice.EBS != nil && blockDevice.EBS.VolumeSize != nil {
+					return *blockDevice.EBS.VolumeSize
+				}
+				break
 			}
 		}
+	} else if provider.DefaultVolumeSize != nil {
+		return *provider.DefaultVolumeSize
 	}
-	return *amifamily.DefaultEBS.VolumeSize
+	return amifamily.DefaultVolumeSize(amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{}))
+}
+
+// ephemeralStorageOverhead reduces the AMI family's ephemeral storage overhead when needed to leave the provider's
+// minimum allocatable ephemeral storage available to pods
+func (i *InstanceType) ephemeralStorageOverhead(amiFamily amifamily.AMIFamily) resource.Quantity {
+	overhead := amiFamily.EphemeralBlockDeviceOverhead()
+	if reservation := i.imageGCReservation(); !reservation.IsZero() {
+		overhead.Add(reservation)
+	}
+	if i.provider.MinAllocatableEphemeralStorage == nil {
+		return overhead
+	}
+	limit := i.ephemeralStorage()
+	limit.Sub(*i.provider.MinAllocatableEphemeralStorage)
+	if limit.Sign() < 0 {
+		return *resource.NewQuantity(0, resource.BinarySI)
+	}
+	if overhead.Cmp(limit) > 0 {
+		return limit
+	}
+	return overhead
+}
+
+// imageGCReservation is the share of the ephemeral storage that kubelet leaves to the image cache before garbage
+// collecting images
+func (i *InstanceType) imageGCReservation() resource.Quantity {
+	percentage := DefaultImageGCReservationPercentage
+	if i.provider.ImageGCReservationPercentage != nil {
+		percentage = *i.provider.ImageGCReservationPercentage
+	}
+	storage := i.ephemeralStorage()
+	return *resource.NewQuantity(storage.Value()*percentage/100, resource.BinarySI)
+}
+
+// ephemeralStorageWarning describes an ephemeral volume that is too small to hold the AMI family's ephemeral storage
+// overhead, which leaves no ephemeral storage for pods unless a minimum allocatable amount is configured
+func ephemeralStorageWarning(provider *v1alpha1.AWS) error {
+	// Incomplete block device mappings are rejected by validation
+	for _, blockDevice := range provider.BlockDeviceMappings {
+		if blockDevice.DeviceName == nil || blockDevice.EBS == nil || blockDevice.EBS.VolumeSize == nil {
+			return nil
+		}
+	}
+	overhead := amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{}).EphemeralBlockDeviceOverhead()
+	if size := ephemeralVolumeSize(provider); size.Cmp(overhead) <= 0 {
+		return fmt.Errorf("ephemeral volume size %s is not larger than the ephemeral storage overhead %s, pods requesting ephemeral-storage may not schedule", size.String(), overhead.String())
+	}
+	return nil
 }
 
 func (i *InstanceType) pods() resource.Quantity {
+	return *resources.Quantity(fmt.Sprint(i.effectivePods()))
+}
+
+// KubeletMaxPods is the max pods that kubelet is configured with, so that it admits as many pods as the instance type's
+// pods capacity. It's nil if the AMI's bootstrap computes the same pod density on its own.
+func (i *InstanceType) KubeletMaxPods() *int32 {
+	if i.eniLimitedPodDensity && i.effectivePods() == i.bootstrapPods() {
+		return nil
+	}
+	return ptr.Int32(int32(i.effectivePods()))
+}
+
+// bootstrapPods is the pod density that the AMI's bootstrap looks up in eni-max-pods.txt, which assumes IPv4 pods
+// that may use every ENI
+func (i *InstanceType) bootstrapPods() int64 {
+	return clampPods(eniPods(aws.Int64Value(i.NetworkInfo.MaximumNetworkInterfaces), aws.Int64Value(i.NetworkInfo.Ipv4AddressesPerInterface), 1))
+}
+
+// effectivePods is the number of pods the instance type runs, which is the overridden max pods if there is one, or
+// its ENI limited pod density otherwise, further limited by the pods whose PID reservations fit. It's shared by the
+// pod capacity and the kube-reserved memory that scales with it, so that the two always agree, and never exceeds
+// MaxPodsLimit.
+func (i *InstanceType) effectivePods() int64 {
+	pods := i.eniLimitedPods() - i.trunkENIReservedPods()
 	if i.maxPods != nil {
-		return *resources.Quantity(fmt.Sprint(ptr.Int32Value(i.maxPods)))
+		pods = int64(ptr.Int32Value(i.maxPods))
+	}
+	if i.provider.PodPIDsLimit != nil {
+		if pidLimitedPods := i.pidLimitedPods(); pidLimitedPods < pods {
+			pods = pidLimitedPods
+		}
+	}
+	return clampPods(pods)
+}
+
+// pidLimitedPods is the number of pods whose PID reservations fit within the kernel's default pid_max, which scales
+// with the number of CPUs. https://github.com/torvalds/linux/blob/master/kernel/pid.c
+func (i *InstanceType) pidLimitedPods() int64 {
+	const (
+		DefaultPIDMax = 32768
+		PIDsPerCPU    = 1024
+		PIDMaxLimit   = 4 * 1024 * 1024
+	)
+	pidMax := PIDsPerCPU * aws.Int64Value(i.VCpuInfo.DefaultVCpus)
+	if pidMax < DefaultPIDMax {
+		pidMax = DefaultPIDMax
+	}
+	if pidMax > PIDMaxLimit {
+		pidMax = PIDMaxLimit
 	}
-	return *resources.Quantity(fmt.Sprint(i.eniLimitedPods()))
+	return pidMax / *i.provider.PodPIDsLimit
 }
 
 func (i *InstanceType) awsPodENI(enablePodENI bool) resource.Quantity {
@@ -210,7 +1153,34 @@ func (i *InstanceType) awsPodENI(enablePodENI bool) resource.Quantity {
 	return *resources.Quantity("0")
 }
 
+// trunkENIReservedPods is the number of pods that lose their addresses to the trunk ENI, which takes one of the
+// instance's ENI slots when security groups for pods are enabled. Branch ENIs are attached to the trunk ENI, so they
+// don't take further slots. IPv6 pod density is capped well below the addresses of the remaining ENIs.
+func (i *InstanceType) trunkENIReservedPods() int64 {
+	if aws.StringValue(i.provider.IPFamily) == v1alpha1.IPFamilyIPv6 {
+		return 0
+	}
+	if branchInterfaces := i.awsPodENI(i.enablePodENI); branchInterfaces.IsZero() {
+		return 0
+	}
+	return aws.Int64Value(i.NetworkInfo.Ipv4AddressesPerInterface) - 1
+}
+
 func (i *InstanceType) nvidiaGPUs() resource.Quantity {
+	// GPUs partitioned into MIG devices aren't advertised as whole GPUs
+	if _, ok := i.migProfile(); ok {
+		return *resources.Quantity("0")
+	}
+	count := i.nvidiaGPUCount()
+	// The NVIDIA device plugin advertises each time-sliced GPU as multiple replicas
+	if i.provider.GPUReplicaFactor != nil {
+		count *= *i.provider.GPUReplicaFactor
+	}
+	return *resources.Quantity(fmt.Sprint(count))
+}
+
+// nvidiaGPUCount is the number of physical NVIDIA GPUs
+func (i *InstanceType) nvidiaGPUCount() int64 {
 	count := int64(0)
 	if i.GpuInfo != nil {
 		for _, gpu := range i.GpuInfo.Gpus {
@@ -219,12 +1189,75 @@ func (i *InstanceType) nvidiaGPUs() resource.Quantity {
 			}
 		}
 	}
+	return count
+}
+
+// migProfile is the MIG profile that the provider partitions the instance type's NVIDIA GPUs into, if any
+func (i *InstanceType) migProfile() (string, bool) {
+	family, _, ok := ParseInstanceType(i.Name())
+	if !ok {
+		return "", false
+	}
+	profile, ok := i.provider.MIGProfiles[family]
+	return profile, ok
+}
+
+// nvidiaMIGDevices is the number of MIG devices that the NVIDIA GPUs are partitioned into. A profile's leading number
+// is the compute slices of each device, so e.g. each GPU holds seven 1g.5gb devices but only two 3g.20gb devices.
+func (i *InstanceType) nvidiaMIGDevices(profile string) resource.Quantity {
+	slices, err := strconv.ParseInt(strings.SplitN(profile, "g.", 2)[0], 10, 64)
+	if err != nil || slices <= 0 {
+		return *resources.Quantity("0")
+	}
+	return *resources.Quantity(fmt.Sprint(i.nvidiaGPUCount() * (MIGComputeSlicesPerGPU / slices)))
+}
+
+// gpuMemory is the total memory across all GPUs in MiB
+func (i *InstanceType) gpuMemory() resource.Quantity {
+	mib := int64(0)
+	if i.GpuInfo != nil {
+		for _, gpu := range i.GpuInfo.Gpus {
+			if gpu.MemoryInfo != nil {
+				mib += aws.Int64Value(gpu.Count) * aws.Int64Value(gpu.MemoryInfo.SizeInMiB)
+			}
+		}
+	}
+	return *resources.Quantity(fmt.Sprint(mib))
+}
+
+// localStorage is the total size of the instance store volumes
+func (i *InstanceType) localStorage() resource.Quantity {
+	if i.InstanceStorageInfo == nil {
+		return *resource.NewScaledQuantity(0, resource.Giga)
+	}
+	return *resource.NewScaledQuantity(aws.Int64Value(i.InstanceStorageInfo.TotalSizeInGB), resource.Giga)
+}
+
+// extraResources are advertised by every node. They default to a smarter-devices/fuse device for each pod, so that fuse
+// requests never limit how many pods are packed onto a node, as they didn't before fuse devices were modeled.
+func (i *InstanceType) extraResources() v1.ResourceList {
+	if i.provider.ExtraResources == nil {
+		return v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: i.pods()}
+	}
+	return i.provider.ExtraResources
+}
+
+func (i *InstanceType) habanaGaudis() resource.Quantity {
+	count := int64(0)
+	if i.GpuInfo != nil {
+		for _, gpu := range i.GpuInfo.Gpus {
+			if *gpu.Manufacturer == "Habana" {
+				count += *gpu.Count
+			}
+		}
+	}
 	return *resources.Quantity(fmt.Sprint(count))
 }
 
-func (i *InstanceType) smarterDevicesFuse() resource.Quantity {
-	count := int64(1)
-	return *resources.Quantity(fmt.Sprint(count)) 
+// gpuCount is the number of physical NVIDIA and AMD GPUs, regardless of how they're shared or partitioned
+func (i *InstanceType) gpuCount() int64 {
+	amd := i.amdGPUs()
+	return i.nvidiaGPUCount() + amd.Value()
 }
 
 func (i *InstanceType) amdGPUs() resource.Quantity {
@@ -250,51 +1283,114 @@ func (i *InstanceType) awsNeurons() resource.Quantity {
 }
 
 func (i *InstanceType) computeOverhead() v1.ResourceList {
-	overhead := v1.ResourceList{
-		v1.ResourceCPU: *resource.NewMilliQuantity(
-			100, // system-reserved
-			resource.DecimalSI),
-		v1.ResourceMemory: resource.MustParse(fmt.Sprintf("%dMi",
-			// kube-reserved
-			((11*i.eniLimitedPods())+255)+
-				// system-reserved
-				100+
-				// eviction threshold https://github.com/kubernetes/kubernetes/blob/ea0764452222146c47ec826977f49d7001b0ea8c/pkg/kubelet/apis/config/v1beta1/defaults_linux.go#L23
-				100,
-		)),
-		v1.ResourceEphemeralStorage: amifamily.GetAMIFamily(i.provider.AMIFamily, &amifamily.Options{}).EphemeralBlockDeviceOverhead(),
-	}
-	// kube-reserved Computed from
-	// https://github.com/bottlerocket-os/bottlerocket/pull/1388/files#diff-bba9e4e3e46203be2b12f22e0d654ebd270f0b478dd34f40c31d7aa695620f2fR611
-	for _, cpuRange := range []struct {
-		start      int64
-		end        int64
-		percentage float64
-	}{
-		{start: 0, end: 1000, percentage: 0.06},
-		{start: 1000, end: 2000, percentage: 0.01},
-		{start: 2000, end: 4000, percentage: 0.005},
-		{start: 4000, end: 1 << 31, percentage: 0.0025},
-	} {
-		cpuSt := i.cpu()
-		if cpu := cpuSt.MilliValue(); cpu >= cpuRange.start {
-			r := float64(cpuRange.end - cpuRange.start)
-			if cpu < cpuRange.end {
-				r = float64(cpu - cpuRange.start)
+	overhead := overheadCalculator(i.provider.AMIFamily).Overhead(i, amifamily.GetAMIFamily(i.provider.AMIFamily, &amifamily.Options{}))
+	// Calculators may return shared lists, so filter and override a copy
+	result := v1.ResourceList{}
+	for name, quantity := range overhead {
+		if OverheadResources.Has(string(name)) {
+			result[name] = quantity
+		}
+	}
+	for name, quantity := range daemonSetOverhead(i.provider) {
+		if OverheadResources.Has(string(name)) && !quantity.IsZero() {
+			reserved := result[name]
+			reserved.Add(quantity)
+			result[name] = reserved
+		}
+	}
+	if override, ok := i.provider.InstanceTypeOverrides[i.Name()]; ok {
+		for name, quantity := range map[v1.ResourceName]*resource.Quantity{
+			v1.ResourceCPU:              override.CPU,
+			v1.ResourceMemory:           override.Memory,
+			v1.ResourceEphemeralStorage: override.EphemeralStorage,
+		} {
+			if quantity != nil {
+				result[name] = *quantity
 			}
-			cpuOverhead := overhead[v1.ResourceCPU]
-			cpuOverhead.Add(*resource.NewMilliQuantity(int64(r*cpuRange.percentage), resource.DecimalSI))
-			overhead[v1.ResourceCPU] = cpuOverhead
 		}
 	}
-	return overhead
+	// Reserved CPUs are isolated from pods regardless of how the rest of the overhead was measured
+	if reservedCPUs := aws.Int64Value(i.provider.ReservedCPUs); reservedCPUs > 0 {
+		cpu := result[v1.ResourceCPU]
+		cpu.Add(*resource.NewQuantity(reservedCPUs, resource.DecimalSI))
+		result[v1.ResourceCPU] = cpu
+	}
+	return result
 }
 
-// The number of pods per node is calculated using the formula:
-// max number of ENIs * (IPv4 Addresses per ENI -1) + 2
-// https://github.com/awslabs/amazon-eks-ami/blob/master/files/eni-max-pods.txt#L20
+// eniLimitedPods is the pod density of the instance type according to the ENILimitedMaxPodsSource, falling back to the
+// formula for instance types the source doesn't know. Sources describe IPv4 clusters, so IPv6 clusters use the formula.
 func (i *InstanceType) eniLimitedPods() int64 {
-	return *i.NetworkInfo.MaximumNetworkInterfaces*(*i.NetworkInfo.Ipv4AddressesPerInterface-1) + 2
+	if aws.StringValue(i.provider.IPFamily) != v1alpha1.IPFamilyIPv6 {
+		if pods, ok := ENILimitedMaxPodsSource.MaxPods(i); ok {
+			return clampPods(pods)
+		}
+	}
+	pods, _ := FormulaMaxPodsSource{}.MaxPods(i)
+	return clampPods(pods)
+}
+
+// normalizeOfferings removes duplicate offerings and sorts them by zone, capacity type, and capacity reservation, so that
+// offerings are stable regardless of the order they were discovered in
+func normalizeOfferings(offerings []cloudprovider.Offering) []cloudprovider.Offering {
+	result := lo.Uniq(offerings)
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Zone != result[b].Zone {
+			return result[a].Zone < result[b].Zone
+		}
+		if result[a].CapacityType != result[b].CapacityType {
+			return result[a].CapacityType < result[b].CapacityType
+		}
+		return result[a].CapacityReservationID < result[b].CapacityReservationID
+	})
+	return result
+}
+
+// ParseInstanceType splits an instance type name into its family and size, e.g. m5.large into m5 and large, and
+// c7i.metal-24xl into c7i and metal-24xl. It's not ok for names that don't have exactly one family and one size.
+func ParseInstanceType(name string) (family string, size string, ok bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// instanceSizeOrdinal ranks an instance size so that larger sizes have larger ordinals. Sizes below xlarge are ranked
+// in order, multiplied sizes scale linearly relative to xlarge (e.g. 2xlarge is twice xlarge), and metal ranks above
+// every virtualized size. Metal sizes of part of a host, e.g. metal-24xl, rank as the multiplied size they match.
+func instanceSizeOrdinal(size string) (int64, bool) {
+	const xlargeOrdinal = 10
+	switch size {
+	case "nano":
+		return 1, true
+	case "micro":
+		return 2, true
+	case "small":
+		return 3, true
+	case "medium":
+		return 4, true
+	case "large":
+		return 5, true
+	case "xlarge":
+		return xlargeOrdinal, true
+	case "metal":
+		return math.MaxInt32, true
+	}
+	var multiplied string
+	switch {
+	case strings.HasPrefix(size, "metal-") && strings.HasSuffix(size, "xl"):
+		multiplied = strings.TrimSuffix(strings.TrimPrefix(size, "metal-"), "xl")
+	case strings.HasSuffix(size, "xlarge"):
+		multiplied = strings.TrimSuffix(size, "xlarge")
+	default:
+		return 0, false
+	}
+	multiplier, err := strconv.ParseInt(multiplied, 10, 64)
+	if err != nil || multiplier <= 0 {
+		return 0, false
+	}
+	return multiplier * xlargeOrdinal, true
 }
 
 func lowerKabobCase(s string) string {
@@ -0,0 +1,120 @@
+//go:build test_performance
+
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/fake"
+)
+
+// go test -tags=test_performance -run=^$ -bench=ComputeRequirements -benchmem ./pkg/cloudprovider/aws/
+func BenchmarkComputeRequirementsSingleZone(b *testing.B) {
+	benchmarkComputeRequirements(b, "test-zone-1a")
+}
+
+func BenchmarkComputeRequirementsMultiZone(b *testing.B) {
+	benchmarkComputeRequirements(b, "test-zone-1a", "test-zone-1b", "test-zone-1c")
+}
+
+func benchmarkComputeRequirements(b *testing.B, zones ...string) {
+	var offerings []cloudprovider.Offering
+	for _, zone := range zones {
+		for _, capacityType := range []string{v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeCapacityBlock} {
+			offerings = append(offerings, cloudprovider.Offering{Zone: zone, CapacityType: capacityType})
+		}
+	}
+	instanceType := &InstanceType{
+		InstanceTypeInfo: &ec2.InstanceTypeInfo{
+			InstanceType:             aws.String("m5.large"),
+			SupportedUsageClasses:    aws.StringSlice([]string{"on-demand", "spot"}),
+			SupportedRootDeviceTypes: aws.StringSlice([]string{"ebs"}),
+			ProcessorInfo:            &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"x86_64"})},
+			VCpuInfo:                 &ec2.VCpuInfo{DefaultVCpus: aws.Int64(2)},
+			MemoryInfo:               &ec2.MemoryInfo{SizeInMiB: aws.Int64(8192)},
+			NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces:  aws.Int64(3),
+				Ipv4AddressesPerInterface: aws.Int64(10),
+			},
+		},
+		offerings: offerings,
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		instanceType.computeRequirements()
+	}
+}
+
+// go test -tags=test_performance -run=^$ -bench=NewInstanceTypes -benchmem ./pkg/cloudprovider/aws/
+func BenchmarkNewInstanceTypesSerial(b *testing.B) {
+	ctx, instanceTypeProvider, infos, zones := newInstanceTypesBenchmark(b)
+	providerHash, err := hashProvider(&v1alpha1.AWS{}, false, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, info := range infos {
+			instanceTypeProvider.newInstanceType(ctx, info, &v1alpha1.AWS{}, providerHash, zones[aws.StringValue(info.InstanceType)], zones[aws.StringValue(info.InstanceType)], nil)
+		}
+	}
+}
+
+func BenchmarkNewInstanceTypesParallel(b *testing.B) {
+	ctx, instanceTypeProvider, infos, zones := newInstanceTypesBenchmark(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := instanceTypeProvider.NewInstanceTypes(ctx, infos, &v1alpha1.AWS{}, zones, zones["m5.large"], nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newInstanceTypesBenchmark replicates the fake instance types to about as many instance types as EC2 offers
+func newInstanceTypesBenchmark(b *testing.B) (context.Context, *InstanceTypeProvider, []*ec2.InstanceTypeInfo, map[string]sets.String) {
+	ctx := context.Background()
+	ec2api := &fake.EC2API{}
+	var fakeInfos []*ec2.InstanceTypeInfo
+	if err := ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{}, func(output *ec2.DescribeInstanceTypesOutput, _ bool) bool {
+		fakeInfos = append(fakeInfos, output.InstanceTypes...)
+		return true
+	}); err != nil {
+		b.Fatalf("describing instance types, %s", err)
+	}
+	var infos []*ec2.InstanceTypeInfo
+	zones := map[string]sets.String{}
+	for i := 0; len(infos) < 600; i++ {
+		for _, fakeInfo := range fakeInfos {
+			info := *fakeInfo
+			info.InstanceType = aws.String(fmt.Sprintf("%s-%d", aws.StringValue(fakeInfo.InstanceType), i))
+			infos = append(infos, &info)
+			zones[aws.StringValue(info.InstanceType)] = sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+		}
+	}
+	zones["m5.large"] = sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+	return ctx, NewInstanceTypeProvider(ec2api, "", nil, nil, NewPriceProvider(ec2api, "", time.Hour, 6*time.Hour)), infos, zones
+}
@@ -17,6 +17,11 @@ package aws
 import (
 	"context"
 	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,13 +29,21 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	"github.com/aws/karpenter/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/metrics"
+	"github.com/aws/karpenter/pkg/scheduling"
 	"github.com/aws/karpenter/pkg/utils/functional"
 	"github.com/aws/karpenter/pkg/utils/injection"
 )
@@ -42,24 +55,123 @@ const (
 	UnfulfillableCapacityErrorCacheTTL = 3 * time.Minute
 )
 
+const (
+	instanceTypeLabel = "instance_type"
+	zoneLabel         = "zone"
+	capacityTypeLabel = "capacity_type"
+)
+
+var (
+	offeringAvailableGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_offerings",
+		Name:      "available",
+		Help:      "Whether an offering was available as of the last time it was considered, 1 if available and 0 if it recently saw an insufficient capacity error.",
+	}, []string{instanceTypeLabel, zoneLabel, capacityTypeLabel})
+	offeringPriceGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_offerings",
+		Name:      "price_estimate",
+		Help:      "Price of an offering as of the last time it was considered, excluding capacity reservations.",
+	}, []string{instanceTypeLabel, zoneLabel, capacityTypeLabel})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(offeringAvailableGauge, offeringPriceGauge)
+}
+
 type InstanceTypeProvider struct {
 	sync.Mutex
-	ec2api         ec2iface.EC2API
-	subnetProvider *SubnetProvider
+	ec2api                      ec2iface.EC2API
+	subnetProvider              *SubnetProvider
+	capacityReservationProvider *CapacityReservationProvider
 	// Has two entries: one for all the instance types and one for all zones; values cached *before* considering insufficient capacity errors
 	// from the unavailableOfferings cache
 	cache *cache.Cache
 	// key: <capacityType>:<instanceType>:<zone>, value: struct{}{}
 	unavailableOfferings *cache.Cache
+	memoryCorrections    *MemoryCorrections
+	priceProvider        *PriceProvider
+	scorer               Scorer
+	// profileScorers replace the scorer for providers that select a scoring profile, keyed by the profile's name
+	profileScorers map[string]Scorer
+	// carbonIntensitySource annotates offerings with the carbon intensity of their zone, if set
+	carbonIntensitySource CarbonIntensitySource
+	// spotInterruptionRateSource rates the spot interruptions of instance types, if set
+	spotInterruptionRateSource SpotInterruptionRateSource
+	// computed caches the resources, overhead, and requirements of instance types by the inputs they're computed from,
+	// so that listing instance types doesn't recompute them. They're always computed if it's nil.
+	computed *cache.Cache
+	// preloaded is closed once Preload has computed the instance types of its providers
+	preloaded chan struct{}
+	// region scopes offerings to the zones of the region, so that controllers managing multiple regions can construct
+	// a provider per region. Offerings aren't scoped if the region is empty.
+	region string
 }
 
-func NewInstanceTypeProvider(ec2api ec2iface.EC2API, subnetProvider *SubnetProvider) *InstanceTypeProvider {
+func NewInstanceTypeProvider(ec2api ec2iface.EC2API, region string, subnetProvider *SubnetProvider, capacityReservationProvider *CapacityReservationProvider, priceProvider *PriceProvider) *InstanceTypeProvider {
 	return &InstanceTypeProvider{
-		ec2api:               ec2api,
-		subnetProvider:       subnetProvider,
-		cache:                cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval),
-		unavailableOfferings: cache.New(UnfulfillableCapacityErrorCacheTTL, CacheCleanupInterval),
+		ec2api:                      ec2api,
+		region:                      region,
+		subnetProvider:              subnetProvider,
+		capacityReservationProvider: capacityReservationProvider,
+		cache:                       cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval),
+		unavailableOfferings:        cache.New(UnfulfillableCapacityErrorCacheTTL, CacheCleanupInterval),
+		memoryCorrections:           NewMemoryCorrections(),
+		computed:                    cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval),
+		priceProvider:               priceProvider,
+		scorer:                      PriceScorer{},
+	}
+}
+
+// SetScorer replaces the Scorer used to rank instance types for selection
+func (p *InstanceTypeProvider) SetScorer(scorer Scorer) {
+	p.Lock()
+	defer p.Unlock()
+	p.scorer = scorer
+}
+
+// SetProfileScorer registers the Scorer used to rank instance types for providers that select the scoring profile. The
+// profile is removed if the scorer is nil.
+func (p *InstanceTypeProvider) SetProfileScorer(profile string, scorer Scorer) {
+	p.Lock()
+	defer p.Unlock()
+	if scorer == nil {
+		delete(p.profileScorers, profile)
+		return
+	}
+	if p.profileScorers == nil {
+		p.profileScorers = map[string]Scorer{}
+	}
+	p.profileScorers[profile] = scorer
+}
+
+// scorerFor resolves the Scorer of the provider's scoring profile, falling back to the default scorer if the provider
+// doesn't select a profile or selects one that isn't registered
+func (p *InstanceTypeProvider) scorerFor(provider *v1alpha1.AWS) Scorer {
+	if provider.ScoringProfile == nil {
+		return p.scorer
+	}
+	if scorer, ok := p.profileScorers[*provider.ScoringProfile]; ok {
+		return scorer
 	}
+	return p.scorer
+}
+
+// SetCarbonIntensitySource sets the source that offerings are annotated with the carbon intensity of their zone from.
+// Offerings aren't annotated if the source is nil.
+func (p *InstanceTypeProvider) SetCarbonIntensitySource(source CarbonIntensitySource) {
+	p.Lock()
+	defer p.Unlock()
+	p.carbonIntensitySource = source
+}
+
+// SetSpotInterruptionRateSource sets the source that instance types are labeled with the rating of their historical
+// spot interruption rate from. Instance types aren't labeled if the source is nil.
+func (p *InstanceTypeProvider) SetSpotInterruptionRateSource(source SpotInterruptionRateSource) {
+	p.Lock()
+	defer p.Unlock()
+	p.spotInterruptionRateSource = source
 }
 
 // Get all instance type options
@@ -72,72 +184,288 @@ func (p *InstanceTypeProvider) Get(ctx context.Context, provider *v1alpha1.AWS)
 		return nil, err
 	}
 	// Get Viable EC2 Purchase offerings
-	instanceTypeZones, err := p.getInstanceTypeZones(ctx, provider)
+	instanceTypeZones, err := p.getInstanceTypeZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Constrain AZs from subnets
+	subnetZones, err := p.getSubnetZones(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	capacityReservations, err := p.capacityReservationProvider.Get(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	newInstanceTypes, err := p.NewInstanceTypes(ctx, lo.Values(instanceTypes), provider, instanceTypeZones, subnetZones, capacityReservations)
 	if err != nil {
 		return nil, err
 	}
 	var result []cloudprovider.InstanceType
-	for _, i := range instanceTypes {
-		result = append(result, p.newInstanceType(ctx, i, provider, instanceTypeZones[*i.InstanceType]))
+	for _, instanceType := range newInstanceTypes {
+		if !p.filterByProvider(instanceType, provider) {
+			continue
+		}
+		result = append(result, instanceType)
 	}
+	// Instance types are discovered in map order, so sort them deterministically to avoid flapping between equally
+	// scored instance types. Prefer the most capacity for the score, then fall back to the name.
+	sort.Slice(result, func(a, b int) bool {
+		x, y := result[a].(*InstanceType), result[b].(*InstanceType)
+		if x.Score() != y.Score() {
+			return x.Score() < y.Score()
+		}
+		if x.ResourceScore() != y.ResourceScore() {
+			return x.ResourceScore() > y.ResourceScore()
+		}
+		return x.Name() < y.Name()
+	})
 	return result, nil
 }
 
-func (p *InstanceTypeProvider) newInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, zones sets.String) *InstanceType {
+// NewInstanceTypes constructs an instance type for each of the infos. Instance types are independent of each other, so
+// they're constructed concurrently by up to GOMAXPROCS workers, and returned in the order of the infos. Instance types
+// that aren't constructed before the context is cancelled are omitted.
+func (p *InstanceTypeProvider) NewInstanceTypes(ctx context.Context, infos []*ec2.InstanceTypeInfo, provider *v1alpha1.AWS, instanceTypeZones map[string]sets.String,
+	subnetZones sets.String, capacityReservations []*ec2.CapacityReservation) ([]*InstanceType, error) {
+	providerHash, err := hashProvider(provider, injection.GetOptions(ctx).AWSEnablePodENI, injection.GetOptions(ctx).AWSENILimitedPodDensity)
+	if err != nil {
+		return nil, err
+	}
+	instanceTypes := make([]*InstanceType, len(infos))
+	workqueue.ParallelizeUntil(ctx, runtime.GOMAXPROCS(0), len(infos), func(i int) {
+		instanceTypes[i] = p.newInstanceType(ctx, infos[i], provider, providerHash, instanceTypeZones[aws.StringValue(infos[i].InstanceType)], subnetZones, capacityReservations)
+	})
+	return lo.Filter(instanceTypes, func(instanceType *InstanceType, _ int) bool { return instanceType != nil }), nil
+}
+
+// newInstanceType only offers the instance type in zones that both EC2 offers it in and the provider has subnets in,
+// unless the provider excludes them. providerHash identifies the provider and options, as hashed by hashProvider.
+func (p *InstanceTypeProvider) newInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, providerHash uint64, offeredZones sets.String,
+	subnetZones sets.String, capacityReservations []*ec2.CapacityReservation) *InstanceType {
+	zones := offeredZones.Intersection(subnetZones).Difference(sets.NewString(provider.ExcludedZones...))
+	if len(provider.ZoneTypes) > 0 {
+		zones = sets.NewString(lo.Filter(zones.UnsortedList(), func(zone string, _ int) bool {
+			return lo.Contains(provider.ZoneTypes, zoneType(zone))
+		})...)
+	}
 	instanceType := &InstanceType{
 		InstanceTypeInfo: info,
 		provider:         provider,
-		offerings:        p.createOfferings(info, zones),
+		offerings:        normalizeOfferings(p.createOfferings(info, provider, zones, capacityReservations)),
+		spotPrices:       map[string]float64{},
+	}
+	for _, offering := range instanceType.offerings {
+		if offering.CapacityType != v1alpha1.CapacityTypeSpot {
+			continue
+		}
+		if price, ok := p.priceProvider.SpotPrice(ctx, instanceType.Name(), offering.Zone); ok {
+			instanceType.spotPrices[offering.Zone] = price
+		}
+	}
+	instanceType.spotPricesExpired = p.priceProvider.Expired()
+	for _, offering := range instanceType.offerings {
+		if offering.CapacityReservationID == "" {
+			offeringPriceGauge.WithLabelValues(instanceType.Name(), offering.Zone, offering.CapacityType).Set(instanceType.OfferingPrice(offering))
+		}
+	}
+	// Offerings priced above the provider's ceiling are never launched
+	if provider.MaxPrice != nil {
+		instanceType.offerings = lo.Filter(instanceType.offerings, func(offering cloudprovider.Offering, _ int) bool {
+			return instanceType.OfferingPrice(offering) <= *provider.MaxPrice
+		})
+	}
+	instanceType.enablePodENI = injection.GetOptions(ctx).AWSEnablePodENI
+	instanceType.eniLimitedPodDensity = injection.GetOptions(ctx).AWSENILimitedPodDensity
+	instanceType.maxPods = maxPods(info, provider, instanceType.eniLimitedPodDensity)
+	instanceType.scorer = p.scorerFor(provider)
+	instanceType.unavailableOfferings = p.unavailableOfferings
+	instanceType.memoryCorrections = p.memoryCorrections
+	instanceType.memoryCorrectionFactor = 1
+	if p.spotInterruptionRateSource != nil {
+		if rate, ok := p.spotInterruptionRateSource.SpotInterruptionRate(instanceType.Name()); ok {
+			instanceType.spotInterruptionRating = spotInterruptionRating(rate)
+		}
+	}
+	if injection.GetOptions(ctx).AWSEnableMemoryCorrection {
+		instanceType.memoryCorrectionFactor = p.memoryCorrections.Factor(instanceType.Name())
 	}
 	// Precompute to minimize memory/compute overhead
-	instanceType.resources = instanceType.computeResources(injection.GetOptions(ctx).AWSEnablePodENI)
+	p.compute(instanceType, providerHash)
+	return instanceType
+}
+
+// computedInstanceType holds what's computed for an instance type. The info is kept so that its address, which is part
+// of the cache key, can't be reused by another info while the entry is cached.
+type computedInstanceType struct {
+	info         *ec2.InstanceTypeInfo
+	resources    v1.ResourceList
+	overhead     v1.ResourceList
+	requirements scheduling.Requirements
+}
+
+// compute the resources, overhead, and requirements of the instance type, reusing them if they were already computed
+// from the same inputs. EC2 instance type infos are cached, so the info is identified by its address rather than by
+// hashing its contents, and the provider by its hash.
+func (p *InstanceTypeProvider) compute(instanceType *InstanceType, providerHash uint64) {
+	if p.computed == nil {
+		instanceType.resources = instanceType.computeResources(instanceType.enablePodENI)
+		instanceType.overhead = instanceType.computeOverhead()
+		instanceType.requirements = instanceType.computeRequirements()
+		return
+	}
+	offerings := lo.Map(instanceType.offerings, func(offering cloudprovider.Offering, _ int) string {
+		return fmt.Sprintf("%s/%s/%s", offering.Zone, offering.CapacityType, offering.CapacityReservationID)
+	})
+	sort.Strings(offerings)
+	key := fmt.Sprintf("%p/%d/%s/%s/%g", instanceType.InstanceTypeInfo, providerHash, strings.Join(offerings, ","),
+		instanceType.spotInterruptionRating, instanceType.memoryCorrectionFactor)
+	if cached, ok := p.computed.Get(key); ok {
+		if computed := cached.(computedInstanceType); computed.info == instanceType.InstanceTypeInfo {
+			instanceType.resources, instanceType.overhead, instanceType.requirements = computed.resources, computed.overhead, computed.requirements
+			return
+		}
+	}
+	instanceType.resources = instanceType.computeResources(instanceType.enablePodENI)
 	instanceType.overhead = instanceType.computeOverhead()
 	instanceType.requirements = instanceType.computeRequirements()
-	if !injection.GetOptions(ctx).AWSENILimitedPodDensity {
-		instanceType.maxPods = ptr.Int32(110)
+	p.computed.SetDefault(key, computedInstanceType{
+		info:         instanceType.InstanceTypeInfo,
+		resources:    instanceType.resources,
+		overhead:     instanceType.overhead,
+		requirements: instanceType.requirements,
+	})
+}
+
+// Preload lists the instance types of each provider so that they're computed and cached before the first provisioning
+// decision. Preloaded is closed once it returns, even if some providers failed to list their instance types.
+func (p *InstanceTypeProvider) Preload(ctx context.Context, providers []*v1alpha1.AWS) (err error) {
+	defer p.markPreloaded()
+	for _, provider := range providers {
+		instanceTypes, e := p.Get(ctx, provider)
+		if e != nil {
+			err = multierr.Append(err, fmt.Errorf("preloading instance types, %w", e))
+			continue
+		}
+		logging.FromContext(ctx).Debugf("Preloaded %d instance types", len(instanceTypes))
 	}
-	return instanceType
+	return err
+}
+
+// Preloaded returns a channel that's closed once instance types are preloaded, or once preloading is skipped.
+func (p *InstanceTypeProvider) Preloaded() <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+	if p.preloaded == nil {
+		p.preloaded = make(chan struct{})
+	}
+	return p.preloaded
+}
+
+func (p *InstanceTypeProvider) markPreloaded() {
+	preloaded := p.Preloaded()
+	p.Lock()
+	defer p.Unlock()
+	select {
+	case <-preloaded:
+	default:
+		close(p.preloaded)
+	}
+}
+
+// maxPods returns the pod density for the instance type, preferring instance type overrides, then the per instance type
+// pod density, then the cluster wide pod density. Returns nil if the pod density is limited by the instance type's ENIs.
+func maxPods(info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, eniLimitedPodDensity bool) *int32 {
+	if override, ok := provider.InstanceTypeOverrides[aws.StringValue(info.InstanceType)]; ok && override.Pods != nil {
+		return ptr.Int32(*override.Pods)
+	}
+	if maxPods, ok := provider.MaxPodsPerInstanceType[aws.StringValue(info.InstanceType)]; ok {
+		return ptr.Int32(maxPods)
+	}
+	// macOS doesn't run the VPC CNI, so pods aren't limited by ENIs
+	if !eniLimitedPodDensity || operatingSystem(info) == v1alpha5.OperatingSystemMacOS {
+		return ptr.Int32(110)
+	}
+	return nil
 }
 
-func (p *InstanceTypeProvider) createOfferings(instanceType *ec2.InstanceTypeInfo, zones sets.String) []cloudprovider.Offering {
+func (p *InstanceTypeProvider) createOfferings(instanceType *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, zones sets.String, capacityReservations []*ec2.CapacityReservation) []cloudprovider.Offering {
 	offerings := []cloudprovider.Offering{}
+	// while usage classes should be a distinct set, there's no guarantee of that
+	capacityTypes := sets.NewString(aws.StringValueSlice(instanceType.SupportedUsageClasses)...)
+	// Capacity blocks can only be launched into by targeting their reservation, so they're only offered by reservations
+	capacityTypes.Delete(v1alpha1.CapacityTypeCapacityBlock)
+	// Instances on dedicated hosts are billed through the host, so host only instance types launch as on-demand
+	if dedicatedHostOnly(instanceType) && aws.BoolValue(provider.DedicatedHosts) {
+		capacityTypes = sets.NewString(v1alpha1.CapacityTypeOnDemand)
+	}
 	for zone := range zones {
-		// while usage classes should be a distinct set, there's no guarantee of that
-		for capacityType := range sets.NewString(aws.StringValueSlice(instanceType.SupportedUsageClasses)...) {
+		for capacityType := range capacityTypes {
 			// exclude any offerings that have recently seen an insufficient capacity error from EC2
 			if _, isUnavailable := p.unavailableOfferings.Get(UnavailableOfferingsCacheKey(*instanceType.InstanceType, zone, capacityType)); !isUnavailable {
 				offerings = append(offerings, cloudprovider.Offering{Zone: zone, CapacityType: capacityType})
+				offeringAvailableGauge.WithLabelValues(*instanceType.InstanceType, zone, capacityType).Set(1)
+			} else {
+				offeringAvailableGauge.WithLabelValues(*instanceType.InstanceType, zone, capacityType).Set(0)
+			}
+		}
+	}
+	// Capacity reservations are reserved on-demand capacity, so they're offered even if on-demand capacity is unavailable.
+	// Capacity blocks are offered as their own capacity type, as they're launched into through the capacity block market.
+	for _, capacityReservation := range capacityReservations {
+		if aws.StringValue(capacityReservation.InstanceType) != aws.StringValue(instanceType.InstanceType) || !zones.Has(aws.StringValue(capacityReservation.AvailabilityZone)) {
+			continue
+		}
+		capacityType := v1alpha1.CapacityTypeOnDemand
+		if aws.StringValue(capacityReservation.ReservationType) == ec2.CapacityReservationTypeCapacityBlock {
+			capacityType = v1alpha1.CapacityTypeCapacityBlock
+		}
+		offerings = append(offerings, cloudprovider.Offering{
+			Zone:                  aws.StringValue(capacityReservation.AvailabilityZone),
+			CapacityType:          capacityType,
+			CapacityReservationID: aws.StringValue(capacityReservation.CapacityReservationId),
+		})
+	}
+	if p.carbonIntensitySource != nil {
+		for i := range offerings {
+			if carbonIntensity, ok := p.carbonIntensitySource.CarbonIntensity(offerings[i].Zone); ok {
+				offerings[i].CarbonIntensity = carbonIntensity
 			}
 		}
 	}
 	return offerings
 }
 
-func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context, provider *v1alpha1.AWS) (map[string]sets.String, error) {
-	if cached, ok := p.cache.Get(InstanceTypeZonesCacheKey); ok {
-		return cached.(map[string]sets.String), nil
-	}
-
-	// Constrain AZs from subnets
+// getSubnetZones returns the zones that the provider's subnets are in, which are the only zones instances can launch in
+func (p *InstanceTypeProvider) getSubnetZones(ctx context.Context, provider *v1alpha1.AWS) (sets.String, error) {
 	subnets, err := p.subnetProvider.Get(ctx, provider)
 	if err != nil {
 		return nil, err
 	}
-	zones := sets.NewString(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
+	return sets.NewString(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
 		return aws.StringValue(subnet.AvailabilityZone)
-	})...)
+	})...), nil
+}
+
+// getInstanceTypeZones returns every zone that EC2 offers each instance type in. This is independent of the provider,
+// so offerings are constrained to the provider's subnets when constructing instance types.
+func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context) (map[string]sets.String, error) {
+	if cached, ok := p.cache.Get(InstanceTypeZonesCacheKey); ok {
+		return cached.(map[string]sets.String), nil
+	}
 
 	// Get offerings from EC2
 	instanceTypeZones := map[string]sets.String{}
 	if err := p.ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{LocationType: aws.String("availability-zone")},
 		func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
 			for _, offering := range output.InstanceTypeOfferings {
-				if zones.Has(aws.StringValue(offering.Location)) {
-					if _, ok := instanceTypeZones[aws.StringValue(offering.InstanceType)]; !ok {
-						instanceTypeZones[aws.StringValue(offering.InstanceType)] = sets.NewString()
-					}
-					instanceTypeZones[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
+				if !zoneInRegion(aws.StringValue(offering.Location), p.region) {
+					continue
+				}
+				if _, ok := instanceTypeZones[aws.StringValue(offering.InstanceType)]; !ok {
+					instanceTypeZones[aws.StringValue(offering.InstanceType)] = sets.NewString()
 				}
+				instanceTypeZones[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
 			}
 			return true
 		}); err != nil {
@@ -148,24 +476,40 @@ func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context, provide
 	return instanceTypeZones, nil
 }
 
+// zoneInRegion is true if the zone, including local and wavelength zones, belongs to the region. Zone names are
+// prefixed by their region, e.g. us-west-2a and us-west-2-lax-1a. Every zone belongs to an empty region.
+func zoneInRegion(zone string, region string) bool {
+	return region == "" || strings.HasPrefix(zone, region)
+}
+
+var (
+	localZoneRegex      = regexp.MustCompile(`-\d+-[a-z]+-\d+[a-z]$`)
+	wavelengthZoneRegex = regexp.MustCompile(`-wlz-\d+$`)
+)
+
+// zoneType classifies a zone by its name, e.g. us-west-2a is an availability zone, us-west-2-lax-1a is a local zone,
+// and us-east-1-wl1-bos-wlz-1 is a wavelength zone
+func zoneType(zone string) string {
+	switch {
+	case wavelengthZoneRegex.MatchString(zone):
+		return v1alpha1.ZoneTypeWavelengthZone
+	case localZoneRegex.MatchString(zone):
+		return v1alpha1.ZoneTypeLocalZone
+	default:
+		return v1alpha1.ZoneTypeAvailabilityZone
+	}
+}
+
 // getInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
 func (p *InstanceTypeProvider) getInstanceTypes(ctx context.Context, provider *v1alpha1.AWS) (map[string]*ec2.InstanceTypeInfo, error) {
 	if cached, ok := p.cache.Get(InstanceTypesCacheKey); ok {
 		return cached.(map[string]*ec2.InstanceTypeInfo), nil
 	}
 	instanceTypes := map[string]*ec2.InstanceTypeInfo{}
-	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("supported-virtualization-type"),
-				Values: []*string{aws.String("hvm")},
-			},
-			{
-				Name:   aws.String("processor-info.supported-architecture"),
-				Values: aws.StringSlice([]string{"x86_64", "arm64"}),
-			},
-		},
-	}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+	// Architectures and virtualization types aren't filtered, as instance types are cached for every provider and
+	// providers can map architectures that aren't built-in or select paravirtual instance types. Instead, they're
+	// filtered by provider.
+	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
 		for _, instanceType := range page.InstanceTypes {
 			if p.filter(instanceType) {
 				instanceTypes[aws.StringValue(instanceType.InstanceType)] = instanceType
@@ -195,6 +539,80 @@ func (p *InstanceTypeProvider) filter(instanceType *ec2.InstanceTypeInfo) bool {
 	return true
 }
 
+// filterByProvider excludes instance types that the provider can't or won't launch
+func (p *InstanceTypeProvider) filterByProvider(instanceType *InstanceType, provider *v1alpha1.AWS) bool {
+	// Exclude instance types that are too small to hold the huge pages reserved by the provider
+	if !instanceType.fitsHugePages() {
+		return false
+	}
+	// Instance types with an unrecognized architecture can't be matched to an AMI
+	if _, err := instanceType.architecture(); err != nil {
+		return false
+	}
+	// Swap is backed by NVMe instance store, so instance types without enough of it can't run it
+	if !instanceType.fitsSwap() {
+		return false
+	}
+	// Mac instance types only run macOS AMIs, and macOS AMIs only run on mac instance types
+	if (operatingSystem(instanceType.InstanceTypeInfo) == v1alpha5.OperatingSystemMacOS) != (aws.StringValue(provider.AMIFamily) == v1alpha1.AMIFamilyMacOS) {
+		return false
+	}
+	// Exclude instance types that are priced above the provider's ceiling at every offering
+	if provider.MaxPrice != nil && len(instanceType.Offerings()) == 0 {
+		return false
+	}
+	if provider.MaxPricePerPod != nil && instanceType.PricePerPod() > *provider.MaxPricePerPod {
+		return false
+	}
+	if provider.MinMemoryPerVCPU != nil && instanceType.memoryPerVCPU() < *provider.MinMemoryPerVCPU {
+		return false
+	}
+	if cpu := instanceType.cpu(); provider.MinVCPU != nil && cpu.MilliValue() < *provider.MinVCPU*1000 {
+		return false
+	}
+	if provider.MinGPUs != nil && instanceType.gpuCount() < *provider.MinGPUs {
+		return false
+	}
+	// Instance types need vCPUs left over for pods after the reserved CPUs are isolated
+	if cpu := instanceType.cpu(); provider.ReservedCPUs != nil && cpu.MilliValue() <= *provider.ReservedCPUs*1000 {
+		return false
+	}
+	// Host only instance types fail to launch unless instances are placed on dedicated hosts
+	if dedicatedHostOnly(instanceType.InstanceTypeInfo) && !aws.BoolValue(provider.DedicatedHosts) {
+		return false
+	}
+	// Only hvm instance types are launched unless the provider selects other virtualization types
+	virtualizationTypes := provider.VirtualizationTypes
+	if len(virtualizationTypes) == 0 {
+		virtualizationTypes = []string{v1alpha1.VirtualizationTypeHVM}
+	}
+	if !lo.Some(aws.StringValueSlice(instanceType.SupportedVirtualizationTypes), virtualizationTypes) {
+		return false
+	}
+	if len(provider.InstanceFamilyPatterns) > 0 && !matchesInstanceFamilyPattern(instanceType.Name(), provider.InstanceFamilyPatterns) {
+		return false
+	}
+	if family, size, ok := ParseInstanceType(instanceType.Name()); ok {
+		if lo.Contains(provider.ExcludedInstanceFamilies, family) {
+			return false
+		}
+		// Some metal sizes are suffixed with their virtualized equivalent, e.g. c7i.metal-24xl
+		if aws.BoolValue(provider.ExcludeMetal) && strings.HasPrefix(size, "metal") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesInstanceFamilyPattern is true if the family of the instance type matches one of the glob patterns
+func matchesInstanceFamilyPattern(name string, patterns []string) bool {
+	family, _, ok := ParseInstanceType(name)
+	return ok && lo.ContainsBy(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, family)
+		return matched
+	})
+}
+
 // CacheUnavailable allows the InstanceProvider to communicate recently observed temporary capacity shortages in
 // the provided offerings
 func (p *InstanceTypeProvider) CacheUnavailable(ctx context.Context, fleetErr *ec2.CreateFleetError, capacityType string) {
@@ -208,6 +626,7 @@ func (p *InstanceTypeProvider) CacheUnavailable(ctx context.Context, fleetErr *e
 		UnfulfillableCapacityErrorCacheTTL)
 	// even if the key is already in the cache, we still need to call Set to extend the cached entry's TTL
 	p.unavailableOfferings.SetDefault(UnavailableOfferingsCacheKey(instanceType, zone, capacityType), struct{}{})
+	offeringAvailableGauge.WithLabelValues(instanceType, zone, capacityType).Set(0)
 }
 
 func UnavailableOfferingsCacheKey(instanceType string, zone string, capacityType string) string {
@@ -33,6 +33,7 @@ import (
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
 
+	"github.com/aws/karpenter/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/amifamily"
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
@@ -79,7 +80,7 @@ func launchTemplateName(options *amifamily.LaunchTemplate) string {
 	return fmt.Sprintf(launchTemplateNameFormat, options.ClusterName, fmt.Sprint(hash))
 }
 
-func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, additionalLabels map[string]string) (map[string][]cloudprovider.InstanceType, error) {
+func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, additionalLabels map[string]string, capacityReservationID string) (map[string][]cloudprovider.InstanceType, error) {
 	p.Lock()
 	defer p.Unlock()
 	// If Launch Template is directly specified then just use it
@@ -100,15 +101,15 @@ func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS
 		return nil, err
 	}
 	resolvedLaunchTemplates, err := p.amiFamily.Resolve(ctx, provider, nodeRequest, &amifamily.Options{
-		ClusterName:             injection.GetOptions(ctx).ClusterName,
-		ClusterEndpoint:         injection.GetOptions(ctx).ClusterEndpoint,
-		AWSENILimitedPodDensity: injection.GetOptions(ctx).AWSENILimitedPodDensity,
-		InstanceProfile:         instanceProfile,
-		SecurityGroupsIDs:       securityGroupsIDs,
-		Tags:                    provider.Tags,
-		Labels:                  functional.UnionStringMaps(nodeRequest.Template.Labels, additionalLabels),
-		CABundle:                p.caBundle,
-		KubernetesVersion:       kubeServerVersion,
+		ClusterName:           injection.GetOptions(ctx).ClusterName,
+		ClusterEndpoint:       injection.GetOptions(ctx).ClusterEndpoint,
+		InstanceProfile:       instanceProfile,
+		CapacityReservationID: capacityReservationID,
+		SecurityGroupsIDs:     securityGroupsIDs,
+		Tags:                  provider.Tags,
+		Labels:                functional.UnionStringMaps(nodeRequest.Template.Labels, additionalLabels),
+		CABundle:              p.caBundle,
+		KubernetesVersion:     kubeServerVersion,
 	})
 	if err != nil {
 		return nil, err
@@ -160,7 +161,7 @@ func (p *LaunchTemplateProvider) createLaunchTemplate(ctx context.Context, optio
 	if err != nil {
 		return nil, err
 	}
-	output, err := p.ec2api.CreateLaunchTemplateWithContext(ctx, &ec2.CreateLaunchTemplateInput{
+	input := &ec2.CreateLaunchTemplateInput{
 		LaunchTemplateName: aws.String(launchTemplateName(options)),
 		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
 			BlockDeviceMappings: p.blockDeviceMappings(options.BlockDeviceMappings),
@@ -181,7 +182,19 @@ func (p *LaunchTemplateProvider) createLaunchTemplate(ctx context.Context, optio
 			ResourceType: aws.String(ec2.ResourceTypeLaunchTemplate),
 			Tags:         v1alpha1.MergeTags(ctx, options.Tags),
 		}},
-	})
+	}
+	if options.CapacityReservationID != "" {
+		input.LaunchTemplateData.CapacityReservationSpecification = &ec2.LaunchTemplateCapacityReservationSpecificationRequest{
+			CapacityReservationTarget: &ec2.CapacityReservationTarget{CapacityReservationId: aws.String(options.CapacityReservationID)},
+		}
+		// Capacity blocks are launched through the capacity block market rather than as on-demand capacity
+		if options.Labels[v1alpha5.LabelCapacityType] == v1alpha1.CapacityTypeCapacityBlock {
+			input.LaunchTemplateData.InstanceMarketOptions = &ec2.LaunchTemplateInstanceMarketOptionsRequest{
+				MarketType: aws.String(ec2.MarketTypeCapacityBlock),
+			}
+		}
+	}
+	output, err := p.ec2api.CreateLaunchTemplateWithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
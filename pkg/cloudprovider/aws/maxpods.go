@@ -0,0 +1,141 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+)
+
+// MaxPodsLimit caps the pod density of every instance type, as kubelet's maxPods is an int32
+const MaxPodsLimit int64 = math.MaxInt32
+
+// MaxPodsSource provides the number of pods that fit in the IP addresses of an instance type's ENIs. It returns false
+// if it doesn't know the instance type, in which case the pod density is computed from the instance type's ENI limits.
+type MaxPodsSource interface {
+	MaxPods(instanceType *InstanceType) (int64, bool)
+}
+
+// ENILimitedMaxPodsSource is consulted for the ENI limited pod density of every instance type. Operators may replace it,
+// e.g. with a MaxPodsTable parsed from the eni-max-pods.txt maintained by the Amazon VPC CNI.
+var ENILimitedMaxPodsSource MaxPodsSource = FormulaMaxPodsSource{}
+
+// FormulaMaxPodsSource calculates the number of pods per node using the formula:
+// max number of ENIs * (IPv4 Addresses per ENI -1) + 2
+// https://github.com/awslabs/amazon-eks-ami/blob/master/files/eni-max-pods.txt#L20
+// With custom networking, the primary ENI isn't used for pods, so the number of ENIs is reduced by one.
+// https://docs.aws.amazon.com/eks/latest/userguide/cni-custom-network.html
+// In IPv6 clusters, each address slot is assigned a /80 prefix instead, which holds far more addresses than pods that
+// kubelet recommends running, so pod density is capped at 110 pods, or 250 pods on instance types with at least 30 vCPUs.
+// https://github.com/awslabs/amazon-eks-ami/blob/master/files/max-pods-calculator.sh
+type FormulaMaxPodsSource struct{}
+
+func (FormulaMaxPodsSource) MaxPods(instanceType *InstanceType) (int64, bool) {
+	enis := aws.Int64Value(instanceType.NetworkInfo.MaximumNetworkInterfaces)
+	if aws.BoolValue(instanceType.provider.CustomNetworking) {
+		enis--
+	}
+	if aws.StringValue(instanceType.provider.IPFamily) == v1alpha1.IPFamilyIPv6 {
+		return ipv6MaxPods(instanceType, enis), true
+	}
+	return eniPods(enis, aws.Int64Value(instanceType.NetworkInfo.Ipv4AddressesPerInterface), 1), true
+}
+
+// eniPods is the number of pods that fit in the secondary addresses of the given ENIs, where each address holds
+// podsPerAddress pods, plus the 2 host network pods. It saturates at MaxPodsLimit instead of overflowing.
+func eniPods(enis int64, addressesPerENI int64, podsPerAddress int64) int64 {
+	if podsPerENI := (addressesPerENI - 1) * podsPerAddress; enis > 0 && podsPerENI > 0 && enis > (MaxPodsLimit-2)/podsPerENI {
+		return MaxPodsLimit
+	}
+	return enis*(addressesPerENI-1)*podsPerAddress + 2
+}
+
+// clampPods limits a pod density to between 0 and MaxPodsLimit
+func clampPods(pods int64) int64 {
+	if pods < 0 {
+		return 0
+	}
+	if pods > MaxPodsLimit {
+		return MaxPodsLimit
+	}
+	return pods
+}
+
+func ipv6MaxPods(instanceType *InstanceType, enis int64) int64 {
+	const (
+		AddressesPerPrefix = 16
+		SmallInstanceLimit = 110
+		LargeInstanceLimit = 250
+		LargeInstanceVCPUs = 30
+	)
+	limit := int64(SmallInstanceLimit)
+	if aws.Int64Value(instanceType.VCpuInfo.DefaultVCpus) >= LargeInstanceVCPUs {
+		limit = LargeInstanceLimit
+	}
+	if pods := eniPods(enis, aws.Int64Value(instanceType.NetworkInfo.Ipv4AddressesPerInterface), AddressesPerPrefix); pods < limit {
+		return pods
+	}
+	return limit
+}
+
+// MaxPodsTable is the pod density of each instance type, keyed by instance type name. The table assumes every ENI
+// is available to pods, so the addresses of the primary ENI are deducted when custom networking is enabled. It only
+// covers IPv4 clusters.
+type MaxPodsTable map[string]int64
+
+func (t MaxPodsTable) MaxPods(instanceType *InstanceType) (int64, bool) {
+	pods, ok := t[instanceType.Name()]
+	if !ok {
+		return 0, false
+	}
+	if aws.BoolValue(instanceType.provider.CustomNetworking) {
+		pods -= aws.Int64Value(instanceType.NetworkInfo.Ipv4AddressesPerInterface) - 1
+	}
+	return pods, true
+}
+
+// ParseMaxPodsTable reads a table in the format of eni-max-pods.txt, with an instance type and its pod density on each
+// line. Blank lines and comments starting with # are ignored.
+func ParseMaxPodsTable(r io.Reader) (MaxPodsTable, error) {
+	table := MaxPodsTable{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d, expected an instance type and max pods, got %q", line, text)
+		}
+		pods, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || pods <= 0 {
+			return nil, fmt.Errorf("line %d, invalid max pods %q for instance type %s", line, fields[1], fields[0])
+		}
+		table[fields[0]] = pods
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading max pods table, %w", err)
+	}
+	return table, nil
+}
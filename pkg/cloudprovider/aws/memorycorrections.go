@@ -0,0 +1,159 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// MinMemoryCorrectionFactor and MaxMemoryCorrectionFactor bound the correction so that a handful of unusual nodes
+	// can't skew the memory of an instance type too far from the static estimate
+	MinMemoryCorrectionFactor = 0.9
+	MaxMemoryCorrectionFactor = 1.1
+	// MemoryCorrectionSmoothing is the weight given to each new observation
+	MemoryCorrectionSmoothing = 0.25
+	// MemoryCorrectionsConfigMapName is the config map in Karpenter's namespace that corrections are persisted to
+	MemoryCorrectionsConfigMapName = "karpenter-memory-corrections"
+	// MemoryCorrectionsPersistInterval is how often corrections that changed are persisted
+	MemoryCorrectionsPersistInterval = time.Minute
+)
+
+// MemoryCorrections tracks a per instance type correction to the estimated memory, refined from the allocatable memory
+// that kubelet reports on launched nodes. Corrections outlive the instance type cache, and are persisted to a config map
+// so that they survive restarts.
+type MemoryCorrections struct {
+	mu      sync.RWMutex
+	factors map[string]float64
+	// dirty is set when corrections changed since they were last persisted
+	dirty bool
+}
+
+func NewMemoryCorrections() *MemoryCorrections {
+	return &MemoryCorrections{factors: map[string]float64{}}
+}
+
+// Factor returns the correction for the instance type, or 1 if nothing has been observed
+func (m *MemoryCorrections) Factor(instanceType string) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if factor, ok := m.factors[instanceType]; ok {
+		return factor
+	}
+	return 1
+}
+
+// Record refines the correction for the instance type from the predicted and observed memory of a node. applied is the
+// correction that was in effect when the prediction was made.
+func (m *MemoryCorrections) Record(instanceType string, applied float64, predicted resource.Quantity, observed resource.Quantity) {
+	if predicted.Value() <= 0 || observed.Value() <= 0 {
+		return
+	}
+	target := applied * float64(observed.Value()) / float64(predicted.Value())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	factor, ok := m.factors[instanceType]
+	if !ok {
+		factor = 1
+	}
+	factor += MemoryCorrectionSmoothing * (target - factor)
+	m.factors[instanceType] = boundMemoryCorrection(factor)
+	m.dirty = true
+}
+
+// Start loads the persisted corrections and then persists corrections that changed until the context is done
+func (m *MemoryCorrections) Start(ctx context.Context, clientSet kubernetes.Interface, namespace string) {
+	if err := m.Load(ctx, clientSet, namespace); err != nil {
+		logging.FromContext(ctx).Errorf("Loading memory corrections, %s", err)
+	}
+	go wait.Until(func() {
+		if err := m.Persist(ctx, clientSet, namespace); err != nil {
+			logging.FromContext(ctx).Errorf("Persisting memory corrections, %s", err)
+		}
+	}, MemoryCorrectionsPersistInterval, ctx.Done())
+}
+
+// Load the persisted corrections of instance types that haven't been observed by this process. Nothing is loaded if
+// corrections haven't been persisted yet.
+func (m *MemoryCorrections) Load(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	configMap, err := clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, MemoryCorrectionsConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting config map %s, %w", MemoryCorrectionsConfigMapName, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for instanceType, value := range configMap.Data {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			logging.FromContext(ctx).Debugf("Ignoring memory correction for instance type %s, %s", instanceType, err)
+			continue
+		}
+		if _, ok := m.factors[instanceType]; !ok {
+			m.factors[instanceType] = boundMemoryCorrection(factor)
+		}
+	}
+	return nil
+}
+
+// Persist the corrections to the config map, if they changed since they were last persisted
+func (m *MemoryCorrections) Persist(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: MemoryCorrectionsConfigMapName, Namespace: namespace},
+		Data:       map[string]string{},
+	}
+	for instanceType, factor := range m.factors {
+		configMap.Data[instanceType] = strconv.FormatFloat(factor, 'f', -1, 64)
+	}
+	m.dirty = false
+	m.mu.Unlock()
+	configMaps := clientSet.CoreV1().ConfigMaps(namespace)
+	_, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	}
+	if err != nil {
+		// Retry with the next persist
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+		return fmt.Errorf("updating config map %s, %w", MemoryCorrectionsConfigMapName, err)
+	}
+	return nil
+}
+
+func boundMemoryCorrection(factor float64) float64 {
+	return math.Max(MinMemoryCorrectionFactor, math.Min(MaxMemoryCorrectionFactor, factor))
+}
@@ -0,0 +1,127 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/amifamily"
+	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
+)
+
+// OverheadCalculator computes the resources reserved for kubelet, system daemons, and eviction on an instance type
+type OverheadCalculator interface {
+	Overhead(instanceType *InstanceType, amiFamily amifamily.AMIFamily) v1.ResourceList
+}
+
+// OverheadCalculators are keyed by AMI family. AMI families without a calculator use the DefaultOverheadCalculator.
+var OverheadCalculators = map[string]OverheadCalculator{}
+
+// OverheadResources are the only resources that overhead is reserved from. Any other resource that a calculator
+// returns is ignored, so that device resources such as GPUs, which are allocated in whole units, are never reduced.
+var OverheadResources = sets.NewString(string(v1.ResourceCPU), string(v1.ResourceMemory), string(v1.ResourceEphemeralStorage))
+
+// AMIFamilyDaemonSetOverhead is the baseline reserved on every node of an AMI family for the system DaemonSets and host
+// containers that aren't visible to the scheduler, e.g. Bottlerocket's control and admin containers. It's replaced by
+// the provider's DaemonSetOverhead when set.
+var AMIFamilyDaemonSetOverhead = map[string]v1.ResourceList{
+	v1alpha1.AMIFamilyBottlerocket: {
+		v1.ResourceCPU:    resource.MustParse("10m"),
+		v1.ResourceMemory: resource.MustParse("100Mi"),
+	},
+}
+
+// DefaultOverheadCalculator computes overhead for https://kubernetes.io/docs/tasks/administer-cluster/reserve-compute-resources/#node-allocatable
+// using calculations copied from https://github.com/bottlerocket-os/bottlerocket#kubernetes-settings.
+type DefaultOverheadCalculator struct{}
+
+func overheadCalculator(amiFamily *string) OverheadCalculator {
+	name := aws.StringValue(amiFamily)
+	if name == "" {
+		name = v1alpha1.AMIFamilyAL2
+	}
+	if calculator, ok := OverheadCalculators[name]; ok {
+		return calculator
+	}
+	return DefaultOverheadCalculator{}
+}
+
+func daemonSetOverhead(provider *v1alpha1.AWS) v1.ResourceList {
+	if provider.DaemonSetOverhead != nil {
+		return provider.DaemonSetOverhead
+	}
+	name := aws.StringValue(provider.AMIFamily)
+	if name == "" {
+		name = v1alpha1.AMIFamilyAL2
+	}
+	return AMIFamilyDaemonSetOverhead[name]
+}
+
+// Overhead reserves kube-reserved and system-reserved resources and the eviction threshold, using the AMI family's
+// ephemeral storage overhead
+func (DefaultOverheadCalculator) Overhead(i *InstanceType, amiFamily amifamily.AMIFamily) v1.ResourceList {
+	// kube-reserved
+	memory := kubeReservedMemory(i.effectivePods())
+	memory.Add(resource.MustParse(fmt.Sprintf("%dMi",
+		// system-reserved
+		100+
+			// eviction threshold https://github.com/kubernetes/kubernetes/blob/ea0764452222146c47ec826977f49d7001b0ea8c/pkg/kubelet/apis/config/v1beta1/defaults_linux.go#L23
+			100,
+	)))
+	overhead := v1.ResourceList{
+		v1.ResourceCPU: *resource.NewMilliQuantity(
+			100, // system-reserved
+			resource.DecimalSI),
+		v1.ResourceMemory:           memory,
+		v1.ResourceEphemeralStorage: i.ephemeralStorageOverhead(amiFamily),
+	}
+	// kube-reserved Computed from
+	// https://github.com/bottlerocket-os/bottlerocket/pull/1388/files#diff-bba9e4e3e46203be2b12f22e0d654ebd270f0b478dd34f40c31d7aa695620f2fR611
+	for _, cpuRange := range []struct {
+		start      int64
+		end        int64
+		percentage float64
+	}{
+		{start: 0, end: 1000, percentage: 0.06},
+		{start: 1000, end: 2000, percentage: 0.01},
+		{start: 2000, end: 4000, percentage: 0.005},
+		{start: 4000, end: 1 << 31, percentage: 0.0025},
+	} {
+		cpuSt := i.vcpus()
+		if cpu := cpuSt.MilliValue(); cpu >= cpuRange.start {
+			r := float64(cpuRange.end - cpuRange.start)
+			if cpu < cpuRange.end {
+				r = float64(cpu - cpuRange.start)
+			}
+			cpuOverhead := overhead[v1.ResourceCPU]
+			cpuOverhead.Add(*resource.NewMilliQuantity(int64(r*cpuRange.percentage), resource.DecimalSI))
+			overhead[v1.ResourceCPU] = cpuOverhead
+		}
+	}
+	return overhead
+}
+
+// kubeReservedMemory is the kube-reserved memory for the max pods, which the EKS optimized AMI's bootstrap script and
+// Bottlerocket's settings generator both compute as 11Mi per pod plus 255Mi
+// https://github.com/awslabs/amazon-eks-ami/blob/master/files/bootstrap.sh
+// https://github.com/bottlerocket-os/bottlerocket/blob/develop/sources/api/pluto/src/main.rs
+func kubeReservedMemory(maxPods int64) resource.Quantity {
+	return resource.MustParse(fmt.Sprintf("%dMi", 11*maxPods+255))
+}
@@ -0,0 +1,190 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"knative.dev/pkg/logging"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter/pkg/metrics"
+)
+
+// PriceRefreshRetryInterval limits how often a failed refresh is retried, so that an unavailable pricing API isn't
+// called on every lookup
+const PriceRefreshRetryInterval = time.Minute
+
+var (
+	priceCacheHitsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_pricing",
+		Name:      "cache_hits_total",
+		Help:      "Number of price lookups served from the price cache.",
+	})
+	priceCacheMissesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_pricing",
+		Name:      "cache_misses_total",
+		Help:      "Number of price lookups that found no cached price.",
+	})
+	stalePricesServedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_pricing",
+		Name:      "stale_prices_served_total",
+		Help:      "Number of price lookups served from the price cache after a refresh failed.",
+	})
+	expiredPricesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_pricing",
+		Name:      "expired_prices_total",
+		Help:      "Number of price lookups that ignored a cached price older than the maximum age.",
+	})
+	priceLastRefreshAgeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "aws_pricing",
+		Name:      "last_refresh_age_seconds",
+		Help:      "Seconds since prices were last refreshed successfully, as of the last price lookup.",
+	})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(priceCacheHitsCounter, priceCacheMissesCounter, stalePricesServedCounter, expiredPricesCounter, priceLastRefreshAgeGauge)
+}
+
+// PriceProvider caches EC2 spot prices and refreshes them at an interval. If a refresh fails, the last known prices
+// continue to be served rather than dropping them. Prices older than the maximum age are never served.
+type PriceProvider struct {
+	sync.Mutex
+	ec2api          ec2iface.EC2API
+	clock           clock.Clock
+	refreshInterval time.Duration
+	// maxAge is the age after which cached spot prices are no longer served, and is zero to always serve them
+	maxAge time.Duration
+	// region scopes prices to the zones of the region, and is empty to keep the prices of all zones
+	region string
+	// key: <instanceType>, value: map of <zone> to price
+	spotPrices  map[string]map[string]float64
+	lastRefresh time.Time
+	lastAttempt time.Time
+}
+
+func NewPriceProvider(ec2api ec2iface.EC2API, region string, refreshInterval time.Duration, maxAge time.Duration) *PriceProvider {
+	return &PriceProvider{
+		ec2api:          ec2api,
+		region:          region,
+		clock:           clock.RealClock{},
+		refreshInterval: refreshInterval,
+		maxAge:          maxAge,
+	}
+}
+
+// SpotPrice returns the most recent spot price for the instance type in the zone, refreshing prices if they're due.
+// Prices older than the maximum age aren't returned, even if they aren't due for a refresh yet, so that callers
+// conservatively fall back to on-demand pricing rather than deciding on outdated spot prices.
+func (p *PriceProvider) SpotPrice(ctx context.Context, instanceType string, zone string) (float64, bool) {
+	p.Lock()
+	defer p.Unlock()
+	stale := false
+	if p.clock.Since(p.lastRefresh) >= p.refreshInterval && p.clock.Since(p.lastAttempt) >= PriceRefreshRetryInterval {
+		if err := p.refresh(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("Refreshing spot prices, %s", err)
+			stale = true
+		}
+	} else if p.lastAttempt.After(p.lastRefresh) {
+		// the last refresh failed and hasn't been retried yet
+		stale = true
+	}
+	priceLastRefreshAgeGauge.Set(p.clock.Since(p.lastRefresh).Seconds())
+	price, ok := p.spotPrices[instanceType][zone]
+	if !ok {
+		priceCacheMissesCounter.Inc()
+		return 0, false
+	}
+	if p.expired() {
+		expiredPricesCounter.Inc()
+		return 0, false
+	}
+	priceCacheHitsCounter.Inc()
+	if stale {
+		stalePricesServedCounter.Inc()
+	}
+	return price, true
+}
+
+// Expired returns true if the cached spot prices are older than the maximum age, in which case SpotPrice doesn't return
+// them whether or not a refresh failed
+func (p *PriceProvider) Expired() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.expired()
+}
+
+func (p *PriceProvider) expired() bool {
+	return p.maxAge > 0 && p.clock.Since(p.lastRefresh) > p.maxAge
+}
+
+func (p *PriceProvider) refresh(ctx context.Context) error {
+	p.lastAttempt = p.clock.Now()
+	spotPrices := map[string]map[string]float64{}
+	// Both product descriptions may price an instance type in a zone, so only the most recent price is kept
+	timestamps := map[string]time.Time{}
+	var parseErr error
+	if err := p.ec2api.DescribeSpotPriceHistoryPagesWithContext(ctx, &ec2.DescribeSpotPriceHistoryInput{
+		ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX", "Linux/UNIX (Amazon VPC)"}),
+		// Only the most recent price is returned for each instance type and zone when the start time is now
+		StartTime: aws.Time(p.clock.Now()),
+	}, func(output *ec2.DescribeSpotPriceHistoryOutput, _ bool) bool {
+		for _, spotPrice := range output.SpotPriceHistory {
+			price, err := strconv.ParseFloat(aws.StringValue(spotPrice.SpotPrice), 64)
+			if err != nil {
+				parseErr = fmt.Errorf("parsing spot price %s for %s, %w", aws.StringValue(spotPrice.SpotPrice), aws.StringValue(spotPrice.InstanceType), err)
+				return false
+			}
+			if !zoneInRegion(aws.StringValue(spotPrice.AvailabilityZone), p.region) {
+				continue
+			}
+			instanceType := aws.StringValue(spotPrice.InstanceType)
+			key := fmt.Sprintf("%s/%s", instanceType, aws.StringValue(spotPrice.AvailabilityZone))
+			if timestamp, ok := timestamps[key]; ok && !aws.TimeValue(spotPrice.Timestamp).After(timestamp) {
+				continue
+			}
+			timestamps[key] = aws.TimeValue(spotPrice.Timestamp)
+			if _, ok := spotPrices[instanceType]; !ok {
+				spotPrices[instanceType] = map[string]float64{}
+			}
+			spotPrices[instanceType][aws.StringValue(spotPrice.AvailabilityZone)] = price
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("describing spot price history, %w", err)
+	}
+	if parseErr != nil {
+		return parseErr
+	}
+	p.spotPrices = spotPrices
+	p.lastRefresh = p.clock.Now()
+	logging.FromContext(ctx).Debugf("Refreshed spot prices for %d instance types", len(spotPrices))
+	return nil
+}
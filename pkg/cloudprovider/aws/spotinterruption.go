@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+// Spot interruption ratings bucket the historical spot interruption rate of an instance type
+const (
+	SpotInterruptionRateLow    = "low"
+	SpotInterruptionRateMedium = "medium"
+	SpotInterruptionRateHigh   = "high"
+)
+
+// SpotInterruptionRateSource provides the historical frequency of spot interruptions of an instance type, as the
+// percentage of spot instances interrupted per month, e.g. from the Spot Instance Advisor. It returns false if the
+// interruption rate of the instance type is unknown.
+type SpotInterruptionRateSource interface {
+	SpotInterruptionRate(instanceType string) (float64, bool)
+}
+
+// StaticSpotInterruptionRateSource is a SpotInterruptionRateSource of fixed interruption rates, keyed by instance type
+type StaticSpotInterruptionRateSource map[string]float64
+
+func (s StaticSpotInterruptionRateSource) SpotInterruptionRate(instanceType string) (float64, bool) {
+	rate, ok := s[instanceType]
+	return rate, ok
+}
+
+// spotInterruptionRating buckets an interruption rate using the ranges of the Spot Instance Advisor, where less than 5%
+// is low, less than 15% is medium, and anything more is high
+func spotInterruptionRating(rate float64) string {
+	switch {
+	case rate < 5:
+		return SpotInterruptionRateLow
+	case rate < 15:
+		return SpotInterruptionRateMedium
+	default:
+		return SpotInterruptionRateHigh
+	}
+}
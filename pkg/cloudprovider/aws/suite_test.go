@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	"github.com/aws/amazon-vpc-resource-controller-k8s/pkg/aws/vpc"
@@ -31,23 +33,31 @@ import (
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/cloudprovider/aws/fake"
 	"github.com/aws/karpenter/pkg/cloudprovider/registry"
+	"github.com/aws/karpenter/pkg/controllers"
 	"github.com/aws/karpenter/pkg/controllers/provisioning"
 	"github.com/aws/karpenter/pkg/controllers/state"
+	"github.com/aws/karpenter/pkg/scheduling"
 	"github.com/aws/karpenter/pkg/test"
 	"github.com/aws/karpenter/pkg/utils/injection"
 	"github.com/aws/karpenter/pkg/utils/options"
+	"github.com/aws/karpenter/pkg/utils/resources"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"knative.dev/pkg/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	. "github.com/aws/karpenter/pkg/test/expectations"
 	. "knative.dev/pkg/logging/testing"
@@ -60,6 +70,7 @@ var env *test.Environment
 var launchTemplateCache *cache.Cache
 var securityGroupCache *cache.Cache
 var subnetCache *cache.Cache
+var capacityReservationCache *cache.Cache
 var amiCache *cache.Cache
 var unavailableOfferingsCache *cache.Cache
 var instanceTypeCache *cache.Cache
@@ -95,6 +106,7 @@ var _ = BeforeSuite(func() {
 		unavailableOfferingsCache = cache.New(UnfulfillableCapacityErrorCacheTTL, CacheCleanupInterval)
 		securityGroupCache = cache.New(CacheTTL, CacheCleanupInterval)
 		subnetCache = cache.New(CacheTTL, CacheCleanupInterval)
+		capacityReservationCache = cache.New(CacheTTL, CacheCleanupInterval)
 		amiCache = cache.New(CacheTTL, CacheCleanupInterval)
 		instanceTypeCache = cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval)
 		fakeEC2API = &fake.EC2API{}
@@ -103,10 +115,17 @@ var _ = BeforeSuite(func() {
 			cache:  subnetCache,
 		}
 		instanceTypeProvider := &InstanceTypeProvider{
-			ec2api:               fakeEC2API,
-			subnetProvider:       subnetProvider,
+			ec2api:         fakeEC2API,
+			subnetProvider: subnetProvider,
+			capacityReservationProvider: &CapacityReservationProvider{
+				ec2api: fakeEC2API,
+				cache:  capacityReservationCache,
+			},
 			cache:                instanceTypeCache,
 			unavailableOfferings: unavailableOfferingsCache,
+			memoryCorrections:    NewMemoryCorrections(),
+			priceProvider:        NewPriceProvider(fakeEC2API, "", time.Hour, 6*time.Hour),
+			scorer:               PriceScorer{},
 		}
 		securityGroupProvider := &SecurityGroupProvider{
 			ec2api: fakeEC2API,
@@ -157,8 +176,14 @@ var _ = Describe("Allocation", func() {
 		launchTemplateCache.Flush()
 		securityGroupCache.Flush()
 		subnetCache.Flush()
+		capacityReservationCache.Flush()
 		unavailableOfferingsCache.Flush()
 		amiCache.Flush()
+		instanceTypeCache.Flush()
+		cloudProvider.(*CloudProvider).instanceTypeProvider.SetScorer(PriceScorer{})
+		cloudProvider.(*CloudProvider).instanceTypeProvider.SetCarbonIntensitySource(nil)
+		cloudProvider.(*CloudProvider).instanceTypeProvider.SetSpotInterruptionRateSource(nil)
+		cloudProvider.(*CloudProvider).instanceTypeProvider.priceProvider = NewPriceProvider(fakeEC2API, "", time.Hour, 6*time.Hour)
 	})
 
 	AfterEach(func() {
@@ -193,8 +218,10 @@ var _ = Describe("Allocation", func() {
 				for key, value := range map[string]string{
 					v1alpha1.InstanceFamilyLabelKey:          "p3",
 					v1alpha1.InstanceSizeLabelKey:            "xlarge",
+					v1alpha1.InstanceSizeOrdinalLabelKey:     "80",
 					v1alpha1.InstanceCPULabelKey:             "32",
 					v1alpha1.InstanceMemoryLabelKey:          "249856",
+					v1alpha1.InstanceMaxENIsLabelKey:         "4",
 					v1alpha1.InstanceGPUNameLabelKey:         "nvidia-v100",
 					v1alpha1.InstanceGPUManufacturerLabelKey: "nvidia",
 					v1alpha1.InstanceGPUCountLabelKey:        "4",
@@ -206,1341 +233,5201 @@ var _ = Describe("Allocation", func() {
 					ExpectScheduled(ctx, env.Client, pod)
 				}
 			})
-			It("should not launch AWS Pod ENI on a t3", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						NodeSelector: map[string]string{
-							v1.LabelInstanceTypeStable: "t3.large",
-						},
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-						},
-					})) {
-					ExpectNotScheduled(ctx, env.Client, pod)
-				}
+			It("should label the total number of GPUs across all GPU entries", func() {
+				info := *ExpectInstanceType(provider, "p3.8xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("p4d.24xlarge")
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{
+					{Name: aws.String("A100"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(4), MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(40960)}},
+					{Name: aws.String("A100"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(4), MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(40960)}},
+				}}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceGPUCountLabelKey).Values().List()).To(ConsistOf("8"))
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{
+					{Name: aws.String("A100"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(8), MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(40960)}},
+				}}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceGPUCountLabelKey).Values().List()).To(ConsistOf("8"))
 			})
-			It("should launch on metal", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						NodeSelector: map[string]string{
-							v1.LabelInstanceTypeStable: "m5.metal",
-						},
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
-						},
-					})) {
-					ExpectScheduled(ctx, env.Client, pod)
+			It("should label the GPU memory tier", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Requirements().Get(v1alpha1.InstanceGPUMemoryTierLabelKey).Values().List()).To(ConsistOf(GPUMemoryTier16To24GB))
+				info := *ExpectInstanceType(provider, "p3.8xlarge").InstanceTypeInfo
+				for sizeInMiB, tier := range map[int64]string{
+					8192:  GPUMemoryTierLessThan16GB,    // M60
+					15360: GPUMemoryTierLessThan16GB,    // T4
+					16384: GPUMemoryTier16To24GB,        // V100
+					24576: GPUMemoryTier24To48GB,        // A10G
+					40960: GPUMemoryTier24To48GB,        // A100
+					49152: GPUMemoryTierGreaterThan48GB, // L40S
+					81920: GPUMemoryTierGreaterThan48GB, // H100
+				} {
+					info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{
+						{Name: aws.String("GPU"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(1), MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(sizeInMiB)}},
+					}}
+					Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceGPUMemoryTierLabelKey).Values().List()).To(ConsistOf(tier), fmt.Sprint(sizeInMiB))
 				}
 			})
-			It("should fail to launch AWS Pod ENI if the command line option enabling it isn't set", func() {
-				instanceTypeCache.Flush()
-				// ensure the pod ENI option is off
-				optsCopy := opts
-				optsCopy.AWSEnablePodENI = false
-				cancelCtx, cancelFunc := context.WithCancel(injection.WithOptions(ctx, optsCopy))
-				// ensure the provisioner is shut down at the end of this test
-				defer cancelFunc()
-				// clear any cached instance types
-				cloudProvider.(*CloudProvider).instanceTypeProvider.cache = cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval)
-				provisionContoller := provisioning.NewController(cancelCtx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(cancelCtx, env.Client, provisionContoller,
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-						},
-					})) {
-					ExpectNotScheduled(cancelCtx, env.Client, pod)
-				}
-				// and ensure no one gets our no-ENI instance types
-				cloudProvider.(*CloudProvider).instanceTypeProvider.cache = cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval)
+			It("should not label the GPU memory tier without GPUs", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements()).ToNot(HaveKey(v1alpha1.InstanceGPUMemoryTierLabelKey))
 			})
-			It("should launch AWS Pod ENI on a compatible instance type", func() {
+			It("should label the GPU interconnect", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Requirements().Get(v1alpha1.InstanceGPUInterconnectLabelKey).Values().List()).To(ConsistOf(GPUInterconnectNVLink))
+				info := *ExpectInstanceType(provider, "p3.8xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("p4d.24xlarge")
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceGPUInterconnectLabelKey).Values().List()).To(ConsistOf(GPUInterconnectNVSwitch))
+				info.InstanceType = aws.String("g4dn.12xlarge")
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceGPUInterconnectLabelKey).Values().List()).To(ConsistOf(GPUInterconnectPCIe))
+			})
+			It("should not label the GPU interconnect without GPUs", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements()).ToNot(HaveKey(v1alpha1.InstanceGPUInterconnectLabelKey))
+			})
+			It("should label the supported root device types", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.SupportedRootDeviceTypes = aws.StringSlice([]string{ec2.RootDeviceTypeEbs})
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceRootDeviceTypeLabelKey).Values().List()).To(ConsistOf(ec2.RootDeviceTypeEbs))
+				info.InstanceType = aws.String("m3.medium")
+				info.SupportedRootDeviceTypes = aws.StringSlice([]string{ec2.RootDeviceTypeEbs, ec2.RootDeviceTypeInstanceStore})
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceRootDeviceTypeLabelKey).Values().List()).To(ConsistOf(ec2.RootDeviceTypeEbs, ec2.RootDeviceTypeInstanceStore))
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceRootDeviceTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{ec2.RootDeviceTypeEbs}},
+				))).To(Succeed())
+				info.SupportedRootDeviceTypes = aws.StringSlice([]string{ec2.RootDeviceTypeInstanceStore})
+				Expect(NewTestInstanceType(provider, &info).Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceRootDeviceTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{ec2.RootDeviceTypeEbs}},
+				))).ToNot(Succeed())
+			})
+			It("should label the supported boot modes", func() {
+				info := *ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+				info.SupportedBootModes = aws.StringSlice([]string{ec2.BootModeTypeUefi})
+				uefi := NewTestInstanceType(provider, &info)
+				Expect(uefi.Requirements().Get(v1alpha1.InstanceSupportedBootModesLabelKey).Values().List()).To(ConsistOf(ec2.BootModeTypeUefi))
+				info = *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.SupportedBootModes = aws.StringSlice([]string{ec2.BootModeTypeLegacyBios})
+				legacyBIOS := NewTestInstanceType(provider, &info)
+				Expect(legacyBIOS.Requirements().Get(v1alpha1.InstanceSupportedBootModesLabelKey).Values().List()).To(ConsistOf(ec2.BootModeTypeLegacyBios))
+				info = *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.SupportedBootModes = aws.StringSlice([]string{ec2.BootModeTypeLegacyBios, ec2.BootModeTypeUefi})
+				dualMode := NewTestInstanceType(provider, &info)
+				Expect(dualMode.Requirements().Get(v1alpha1.InstanceSupportedBootModesLabelKey).Values().List()).To(ConsistOf(ec2.BootModeTypeLegacyBios, ec2.BootModeTypeUefi))
+				requireUEFI := scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceSupportedBootModesLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{ec2.BootModeTypeUefi}},
+				)
+				Expect(uefi.Compatible(requireUEFI)).To(Succeed())
+				Expect(dualMode.Compatible(requireUEFI)).To(Succeed())
+				Expect(legacyBIOS.Compatible(requireUEFI)).ToNot(Succeed())
+			})
+			It("should not label boot modes that EC2 doesn't report", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements()).ToNot(HaveKey(v1alpha1.InstanceSupportedBootModesLabelKey))
+			})
+			It("should label the supported virtualization types", func() {
+				hvm := ExpectInstanceType(provider, "m5.large")
+				Expect(hvm.Requirements().Get(v1alpha1.InstanceVirtualizationTypeLabelKey).Values().List()).To(ConsistOf(ec2.VirtualizationTypeHvm))
+				info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.InstanceType = aws.String("m3.medium")
+				info.SupportedVirtualizationTypes = aws.StringSlice([]string{ec2.VirtualizationTypeHvm, ec2.VirtualizationTypeParavirtual})
+				paravirtual := NewTestInstanceType(provider, &info)
+				Expect(paravirtual.Requirements().Get(v1alpha1.InstanceVirtualizationTypeLabelKey).Values().List()).To(ConsistOf(ec2.VirtualizationTypeHvm, ec2.VirtualizationTypeParavirtual))
+				requireParavirtual := scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceVirtualizationTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{ec2.VirtualizationTypeParavirtual}},
+				)
+				Expect(paravirtual.Compatible(requireParavirtual)).To(Succeed())
+				Expect(hvm.Compatible(requireParavirtual)).ToNot(Succeed())
+			})
+			It("should filter instance types by virtualization type", func() {
+				info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.InstanceType = aws.String("m3.medium")
+				info.SupportedVirtualizationTypes = aws.StringSlice([]string{ec2.VirtualizationTypeHvm, ec2.VirtualizationTypeParavirtual})
+				paravirtual := NewTestInstanceType(provider, &info)
+				hvm := ExpectInstanceType(provider, "m5.large")
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				provider.VirtualizationTypes = []string{v1alpha1.VirtualizationTypeParavirtual}
+				Expect(instanceTypeProvider.filterByProvider(paravirtual, provider)).To(BeTrue())
+				Expect(instanceTypeProvider.filterByProvider(hvm, provider)).To(BeFalse())
+				provider.VirtualizationTypes = []string{v1alpha1.VirtualizationTypeHVM}
+				Expect(instanceTypeProvider.filterByProvider(paravirtual, provider)).To(BeTrue())
+				Expect(instanceTypeProvider.filterByProvider(hvm, provider)).To(BeTrue())
+				info.SupportedVirtualizationTypes = aws.StringSlice([]string{ec2.VirtualizationTypeParavirtual})
+				Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(BeFalse())
+			})
+			It("should discover paravirtual instance types when the provider selects them", func() {
+				info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.InstanceType = aws.String("m1.small")
+				info.SupportedVirtualizationTypes = aws.StringSlice([]string{ec2.VirtualizationTypeParavirtual})
+				fakeEC2API.DescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{InstanceTypes: []*ec2.InstanceTypeInfo{&info}}
+				fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					{InstanceType: aws.String("m1.small"), Location: aws.String("test-zone-1a")},
+				}}
 				instanceTypeCache.Flush()
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
-						},
-					})) {
-					node := ExpectScheduled(ctx, env.Client, pod)
-					Expect(node.Labels).To(HaveKey(v1.LabelInstanceTypeStable))
-					supportsPodENI := func() bool {
-						limits, ok := vpc.Limits[node.Labels[v1.LabelInstanceTypeStable]]
-						return ok && limits.IsTrunkingCompatible
-					}
-					Expect(supportsPodENI()).To(Equal(true))
+				Expect(ExpectInstanceTypeNames(provider)).To(BeEmpty())
+				provider.VirtualizationTypes = []string{v1alpha1.VirtualizationTypeParavirtual}
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("m1.small"))
+			})
+			It("should label whether EBS has dedicated bandwidth", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.EbsInfo = &ec2.EbsInfo{
+					EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportDefault),
+					EbsOptimizedInfo:    &ec2.EbsOptimizedInfo{BaselineBandwidthInMbps: aws.Int64(650), MaximumBandwidthInMbps: aws.Int64(4750)},
 				}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceEBSDedicatedBandwidthLabelKey).Values().List()).To(ConsistOf("true"))
 			})
-			It("should launch instances for Nvidia GPU resource requests", func() {
-				nodeNames := sets.NewString()
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
-						},
-					}),
-					// Should pack onto same instance
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("2")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("2")},
-						},
-					}),
-					// Should pack onto a separate instance
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
-						},
-					})) {
-					node := ExpectScheduled(ctx, env.Client, pod)
-					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "p3.8xlarge"))
-					nodeNames.Insert(node.Name)
+			It("should label instance types that share network bandwidth with EBS", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("t2.large")
+				info.EbsInfo = &ec2.EbsInfo{EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportUnsupported)}
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceEBSDedicatedBandwidthLabelKey).Values().List()).To(ConsistOf("false"))
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceEBSDedicatedBandwidthLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{"true"}},
+				))).ToNot(Succeed())
+			})
+			It("should label the baseline EBS IOPS", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.EbsInfo = &ec2.EbsInfo{
+					EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportDefault),
+					EbsOptimizedInfo:    &ec2.EbsOptimizedInfo{BaselineIops: aws.Int64(3600), MaximumIops: aws.Int64(18750)},
 				}
-				Expect(nodeNames.Len()).To(Equal(2))
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceEBSIOPSLabelKey).Values().List()).To(ConsistOf("3600"))
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceEBSIOPSLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{"3600"}},
+				))).To(Succeed())
 			})
-			It("should launch instances for AWS Neuron resource requests", func() {
-				nodeNames := sets.NewString()
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-						},
-					}),
-					// Should pack onto same instance
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
-						},
-					}),
-					// Should pack onto a separate instance
-					test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("4")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("4")},
-						},
-					}),
-				) {
-					node := ExpectScheduled(ctx, env.Client, pod)
-					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.6xlarge"))
-					nodeNames.Insert(node.Name)
+			It("should not label the baseline EBS IOPS of instance types that don't report it", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.EbsInfo = &ec2.EbsInfo{EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportUnsupported)}
+				Expect(NewTestInstanceType(provider, &info).Requirements()).ToNot(HaveKey(v1alpha1.InstanceEBSIOPSLabelKey))
+			})
+			It("should label the count and type of local SSDs", func() {
+				info := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("m5d.24xlarge")
+				info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+					NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+					TotalSizeInGB: aws.Int64(3600),
+					Disks:         []*ec2.DiskInfo{{Count: aws.Int64(4), SizeInGB: aws.Int64(900), Type: aws.String(ec2.DiskTypeSsd)}},
 				}
-				Expect(nodeNames.Len()).To(Equal(2))
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceLocalDiskCountLabelKey).Values().List()).To(ConsistOf("4"))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceLocalDiskTypeLabelKey).Values().List()).To(ConsistOf("ssd"))
 			})
-		})
-		Context("Insufficient Capacity Error Cache", func() {
-			It("should launch instances of different type on second reconciliation attempt with Insufficient Capacity Error Cache fallback", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "inf1.6xlarge", Zone: "test-zone-1a"}})
-				ExpectApplied(ctx, env.Client, provisioner)
-				pods := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"},
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-						},
-					}),
-					test.UnschedulablePod(test.PodOptions{
-						NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"},
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
-						},
-					}),
-				)
-				// it should've tried to pack them on a single inf1.6xlarge then hit an insufficient capacity error
-				for _, pod := range pods {
-					ExpectNotScheduled(ctx, env.Client, pod)
+			It("should label the count and type of local HDDs", func() {
+				info := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("d3.8xlarge")
+				info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+					NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportUnsupported),
+					TotalSizeInGB: aws.Int64(47520),
+					Disks:         []*ec2.DiskInfo{{Count: aws.Int64(24), SizeInGB: aws.Int64(1980), Type: aws.String(ec2.DiskTypeHdd)}},
 				}
-				nodeNames := sets.NewString()
-				for _, pod := range ExpectProvisioned(ctx, env.Client, controller, pods...) {
-					node := ExpectScheduled(ctx, env.Client, pod)
-					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.2xlarge"))
-					nodeNames.Insert(node.Name)
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceLocalDiskCountLabelKey).Values().List()).To(ConsistOf("24"))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceLocalDiskTypeLabelKey).Values().List()).To(ConsistOf("hdd"))
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceLocalDiskTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{"ssd"}},
+				))).ToNot(Succeed())
+			})
+			It("should not label local disks of instance types without instance store", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Requirements()).ToNot(HaveKey(v1alpha1.InstanceLocalDiskCountLabelKey))
+				Expect(instanceType.Requirements()).ToNot(HaveKey(v1alpha1.InstanceLocalDiskTypeLabelKey))
+			})
+			It("should label the EBS NVMe support of the instance type", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				for _, nvmeSupport := range []string{ec2.EbsNvmeSupportRequired, ec2.EbsNvmeSupportSupported, ec2.EbsNvmeSupportUnsupported} {
+					info.EbsInfo = &ec2.EbsInfo{NvmeSupport: aws.String(nvmeSupport)}
+					Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceNVMeSupportLabelKey).Values().List()).To(ConsistOf(nvmeSupport))
 				}
-				Expect(nodeNames.Len()).To(Equal(2))
+				info.EbsInfo = &ec2.EbsInfo{NvmeSupport: aws.String(ec2.EbsNvmeSupportUnsupported)}
+				Expect(NewTestInstanceType(provider, &info).Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1alpha1.InstanceNVMeSupportLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{ec2.EbsNvmeSupportRequired}},
+				))).ToNot(Succeed())
 			})
-			It("should launch instances in a different zone on second reconciliation attempt with Insufficient Capacity Error Cache fallback", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "p3.8xlarge", Zone: "test-zone-1a"}})
-				pod := test.UnschedulablePod(test.PodOptions{
-					ResourceRequirements: v1.ResourceRequirements{
-						Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
-						Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
-					},
+			It("should not label the EBS NVMe support of instance types without EBS info", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.EbsInfo = nil
+				Expect(NewTestInstanceType(provider, &info).Requirements()).ToNot(HaveKey(v1alpha1.InstanceNVMeSupportLabelKey))
+			})
+			It("should label the spot interruption rating from the source", func() {
+				cloudProvider.(*CloudProvider).instanceTypeProvider.SetSpotInterruptionRateSource(StaticSpotInterruptionRateSource{
+					"m5.large":  2.5,
+					"m5.xlarge": 18,
 				})
-				pod.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
-					{
-						Weight: 1, Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{
-							{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
-						}},
-					},
-				}}}
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceSpotInterruptionRateLabelKey).Values().List()).To(ConsistOf(SpotInterruptionRateLow))
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Requirements().Get(v1alpha1.InstanceSpotInterruptionRateLabelKey).Values().List()).To(ConsistOf(SpotInterruptionRateHigh))
+				Expect(ExpectInstanceType(provider, "t3.large").Requirements().Get(v1alpha1.InstanceSpotInterruptionRateLabelKey).Type()).To(Equal(v1.NodeSelectorOpDoesNotExist))
 				ExpectApplied(ctx, env.Client, provisioner)
-				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
-				// it should've tried to pack them in test-zone-1a on a p3.8xlarge then hit insufficient capacity, the next attempt will try test-zone-1b
-				ExpectNotScheduled(ctx, env.Client, pod)
-
-				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
-				node := ExpectScheduled(ctx, env.Client, pod)
-				Expect(node.Labels).To(SatisfyAll(
-					HaveKeyWithValue(v1.LabelInstanceTypeStable, "p3.8xlarge"),
-					HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b")))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+					NodeSelector: map[string]string{v1alpha1.InstanceSpotInterruptionRateLabelKey: SpotInterruptionRateLow},
+				}))[0]
+				Expect(ExpectScheduled(ctx, env.Client, pod).Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.large"))
 			})
-			It("should launch smaller instances than optimal if larger instance launch results in Insufficient Capacity Error", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{
-					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+			It("should bucket spot interruption rates", func() {
+				for rate, rating := range map[float64]string{
+					0:    SpotInterruptionRateLow,
+					4.9:  SpotInterruptionRateLow,
+					5:    SpotInterruptionRateMedium,
+					14.9: SpotInterruptionRateMedium,
+					15:   SpotInterruptionRateHigh,
+					50:   SpotInterruptionRateHigh,
+				} {
+					Expect(spotInterruptionRating(rate)).To(Equal(rating), fmt.Sprint(rate))
+				}
+			})
+			It("should not label the spot interruption rating without a source", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceSpotInterruptionRateLabelKey).Type()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+			})
+			It("should not satisfy spot interruption rating requirements with unrated instance types", func() {
+				cloudProvider.(*CloudProvider).instanceTypeProvider.SetSpotInterruptionRateSource(StaticSpotInterruptionRateSource{"m5.large": 2.5})
+				low := scheduling.NewNodeSelectorRequirements(v1.NodeSelectorRequirement{
+					Key: v1alpha1.InstanceSpotInterruptionRateLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{SpotInterruptionRateLow},
 				})
-				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
-					Key:      v1.LabelInstanceType,
-					Operator: v1.NodeSelectorOpIn,
-					Values:   []string{"m5.large", "m5.xlarge"},
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Compatible(low)).To(Succeed())
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Requirements().Compatible(low)).ToNot(Succeed())
+				// unrated instance types are still compatible with pods that avoid a rating
+				notHigh := scheduling.NewNodeSelectorRequirements(v1.NodeSelectorRequirement{
+					Key: v1alpha1.InstanceSpotInterruptionRateLabelKey, Operator: v1.NodeSelectorOpNotIn, Values: []string{SpotInterruptionRateHigh},
 				})
-				pods := []*v1.Pod{}
-				for i := 0; i < 2; i++ {
-					pods = append(pods, test.UnschedulablePod(test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
-						},
-						NodeSelector: map[string]string{
-							v1.LabelTopologyZone: "test-zone-1a",
-						},
-					}))
-				}
-				// Provisions 2 m5.large instances since m5.xlarge was ICE'd
-				ExpectApplied(ctx, env.Client, provisioner)
-				pods = ExpectProvisioned(ctx, env.Client, controller, pods...)
-				for _, pod := range pods {
-					ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Requirements().Compatible(notHigh)).To(Succeed())
+			})
+			It("should label whether encryption in transit is supported", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceEncryptionInTransitLabelKey).Values().List()).To(ConsistOf("false"))
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("m5n.large")
+				networkInfo := *info.NetworkInfo
+				networkInfo.EncryptionInTransitSupported = aws.Bool(true)
+				info.NetworkInfo = &networkInfo
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceEncryptionInTransitLabelKey).Values().List()).To(ConsistOf("true"))
+			})
+			It("should label the memory bandwidth of known families", func() {
+				info := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("x2idn.16xlarge")
+				highBandwidth := NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceMemoryBandwidthLabelKey)
+				generalPurpose := ExpectInstanceType(provider, "m5.xlarge").Requirements().Get(v1alpha1.InstanceMemoryBandwidthLabelKey)
+				Expect(highBandwidth.Values().List()).To(ConsistOf("410"))
+				Expect(generalPurpose.Values().List()).To(ConsistOf("256"))
+			})
+			It("should not label the memory bandwidth of unknown families", func() {
+				Expect(ExpectInstanceType(provider, "t3.large").Requirements()).ToNot(HaveKey(v1alpha1.InstanceMemoryBandwidthLabelKey))
+			})
+			It("should label dual socket instance types with two NUMA nodes", func() {
+				Expect(ExpectInstanceType(provider, "m5.metal").Requirements().Get(v1alpha1.InstanceNUMANodesLabelKey).Values().List()).To(ConsistOf("2"))
+				info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("m5.16xlarge")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(64)}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceNUMANodesLabelKey).Values().List()).To(ConsistOf("2"))
+			})
+			It("should label single socket instance types with one NUMA node", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceNUMANodesLabelKey).Values().List()).To(ConsistOf("1"))
+				info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("m5.12xlarge")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(48)}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceNUMANodesLabelKey).Values().List()).To(ConsistOf("1"))
+				Expect(ExpectInstanceType(provider, "c6g.large").Requirements().Get(v1alpha1.InstanceNUMANodesLabelKey).Values().List()).To(ConsistOf("1"))
+			})
+			It("should not label the NUMA nodes of unknown families", func() {
+				Expect(ExpectInstanceType(provider, "t3.large").Requirements()).ToNot(HaveKey(v1alpha1.InstanceNUMANodesLabelKey))
+			})
+			It("should model the topology of dual socket instance types", func() {
+				info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("m5.24xlarge")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(96), DefaultCores: aws.Int64(48), DefaultThreadsPerCore: aws.Int64(2)}
+				instanceType := NewTestInstanceType(provider, &info)
+				topology, ok := instanceType.Topology()
+				Expect(ok).To(BeTrue())
+				Expect(topology).To(Equal(TopologyLayout{Sockets: 2, CoresPerSocket: 24}))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceSocketsLabelKey).Values().List()).To(ConsistOf("2"))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceCoresPerSocketLabelKey).Values().List()).To(ConsistOf("24"))
+			})
+			It("should model the topology of single socket instance types", func() {
+				info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("m5.12xlarge")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(48), DefaultCores: aws.Int64(24), DefaultThreadsPerCore: aws.Int64(2)}
+				topology, ok := NewTestInstanceType(provider, &info).Topology()
+				Expect(ok).To(BeTrue())
+				Expect(topology).To(Equal(TopologyLayout{Sockets: 1, CoresPerSocket: 24}))
+			})
+			It("should not model the topology of unknown families or instance types without cores", func() {
+				info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(2), DefaultCores: aws.Int64(1), DefaultThreadsPerCore: aws.Int64(2)}
+				instanceType := NewTestInstanceType(provider, &info)
+				_, ok := instanceType.Topology()
+				Expect(ok).To(BeFalse())
+				Expect(instanceType.Requirements()).ToNot(HaveKey(v1alpha1.InstanceSocketsLabelKey))
+				info = *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(96)}
+				instanceType = NewTestInstanceType(provider, &info)
+				_, ok = instanceType.Topology()
+				Expect(ok).To(BeFalse())
+				Expect(instanceType.Requirements()).ToNot(HaveKey(v1alpha1.InstanceCoresPerSocketLabelKey))
+			})
+			It("should label whether ENA Express is supported", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceENAExpressLabelKey).Values().List()).To(ConsistOf("false"))
+				info := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("c6in.32xlarge")
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceENAExpressLabelKey).Values().List()).To(ConsistOf("true"))
+			})
+			Context("Dedicated Host Only", func() {
+				var hostOnly *ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+					info.InstanceType = aws.String("u-6tb1.metal")
+					info.SupportedUsageClasses = aws.StringSlice([]string{"host"})
+					hostOnly = &info
+				})
+				It("should label whether the instance type is dedicated host only", func() {
+					Expect(ExpectInstanceType(provider, "m5.metal").Requirements().Get(v1alpha1.InstanceDedicatedHostOnlyLabelKey).Values().List()).To(ConsistOf("false"))
+					Expect(NewTestInstanceType(provider, hostOnly).Requirements().Get(v1alpha1.InstanceDedicatedHostOnlyLabelKey).Values().List()).To(ConsistOf("true"))
+				})
+				It("should treat instance types without on-demand or spot usage classes as dedicated host only", func() {
+					hostOnly.SupportedUsageClasses = nil
+					Expect(NewTestInstanceType(provider, hostOnly).Requirements().Get(v1alpha1.InstanceDedicatedHostOnlyLabelKey).Values().List()).To(ConsistOf("true"))
+				})
+				It("should exclude dedicated host only instance types by default", func() {
+					instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+					Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, hostOnly), provider)).To(BeFalse())
+					Expect(instanceTypeProvider.filterByProvider(ExpectInstanceType(provider, "m5.metal"), provider)).To(BeTrue())
+				})
+				It("should include dedicated host only instance types as on-demand when targeting dedicated hosts", func() {
+					provider.DedicatedHosts = aws.Bool(true)
+					instanceType := NewTestInstanceType(provider, hostOnly)
+					Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeTrue())
+					Expect(instanceType.Offerings()).ToNot(BeEmpty())
+					for _, offering := range instanceType.Offerings() {
+						Expect(offering.CapacityType).To(Equal(v1alpha1.CapacityTypeOnDemand))
+					}
+				})
+				It("should not change the offerings of other instance types when targeting dedicated hosts", func() {
+					provider.DedicatedHosts = aws.Bool(true)
+					capacityTypes := sets.NewString(lo.Map(ExpectInstanceType(provider, "m5.metal").Offerings(), func(o cloudprovider.Offering, _ int) string { return o.CapacityType })...)
+					Expect(capacityTypes.List()).To(ConsistOf(v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeSpot))
+				})
+			})
+			It("should label accelerators", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Requirements().Get(v1alpha1.InstanceAcceleratorLabelKey).Values().List()).To(ConsistOf(v1alpha1.AcceleratorGPU))
+				Expect(ExpectInstanceType(provider, "inf1.2xlarge").Requirements().Get(v1alpha1.InstanceAcceleratorLabelKey).Values().List()).To(ConsistOf(v1alpha1.AcceleratorInferentia))
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("trn1.2xlarge")
+				info.InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{Accelerators: []*ec2.InferenceDeviceInfo{{
+					Name: aws.String("Trainium"), Manufacturer: aws.String("AWS"), Count: aws.Int64(1),
+				}}}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceAcceleratorLabelKey).Values().List()).To(ConsistOf(v1alpha1.AcceleratorTrainium))
+			})
+			It("should label the name and manufacturer of Inferentia and Trainium accelerators", func() {
+				instanceType := ExpectInstanceType(provider, "inf1.2xlarge")
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceAcceleratorNameLabelKey).Values().List()).To(ConsistOf("inferentia"))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceAcceleratorManufacturerLabelKey).Values().List()).To(ConsistOf("aws"))
+				for name, accelerator := range map[string]string{"inf2.xlarge": "Inferentia2", "trn1.2xlarge": "Trainium"} {
+					info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					info.InstanceType = aws.String(name)
+					info.InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{Accelerators: []*ec2.InferenceDeviceInfo{{
+						Name: aws.String(accelerator), Manufacturer: aws.String("AWS"), Count: aws.Int64(1),
+					}}}
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Requirements().Get(v1alpha1.InstanceAcceleratorNameLabelKey).Values().List()).To(ConsistOf(strings.ToLower(accelerator)), name)
+					Expect(instanceType.Requirements().Get(v1alpha1.InstanceAcceleratorManufacturerLabelKey).Values().List()).To(ConsistOf("aws"), name)
 				}
-				pods = ExpectProvisioned(ctx, env.Client, controller, pods...)
-				for _, pod := range pods {
-					node := ExpectScheduled(ctx, env.Client, pod)
-					Expect(node.Labels[v1.LabelInstanceTypeStable]).To(Equal("m5.large"))
+			})
+			It("should not label the accelerator name or manufacturer of other instance types", func() {
+				for _, name := range []string{"m5.large", "p3.8xlarge"} {
+					Expect(ExpectInstanceType(provider, name).Requirements().Has(v1alpha1.InstanceAcceleratorNameLabelKey)).To(BeFalse(), name)
+					Expect(ExpectInstanceType(provider, name).Requirements().Has(v1alpha1.InstanceAcceleratorManufacturerLabelKey)).To(BeFalse(), name)
 				}
 			})
-			It("should launch instances on later reconciliation attempt with Insufficient Capacity Error Cache expiry", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "inf1.6xlarge", Zone: "test-zone-1a"}})
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "inf1.6xlarge"},
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
-							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
-						},
-					}),
-				)[0]
-				ExpectNotScheduled(ctx, env.Client, pod)
-				// capacity shortage is over - expire the item from the cache and try again
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{})
-				unavailableOfferingsCache.Delete(UnavailableOfferingsCacheKey("inf1.6xlarge", "test-zone-1a", v1alpha1.CapacityTypeOnDemand))
-				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
-				node := ExpectScheduled(ctx, env.Client, pod)
-				Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.6xlarge"))
+			It("should label all accelerators when there are multiple kinds", func() {
+				info := *ExpectInstanceType(provider, "p3.8xlarge").InstanceTypeInfo
+				info.InstanceType = aws.String("p3inf.8xlarge")
+				info.InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{Accelerators: []*ec2.InferenceDeviceInfo{{
+					Name: aws.String("Inferentia"), Manufacturer: aws.String("AWS"), Count: aws.Int64(1),
+				}}}
+				Expect(NewTestInstanceType(provider, &info).Requirements().Get(v1alpha1.InstanceAcceleratorLabelKey).Values().List()).To(ConsistOf(v1alpha1.AcceleratorGPU, v1alpha1.AcceleratorInferentia))
 			})
-			It("should launch on-demand capacity if flexible to both spot and on demand, but spot if unavailable", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.large", Zone: "test-zone-1a"}})
-				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
-					{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeOnDemand}},
-					{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
-					{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
-				}
-				// Spot Unavailable
+			It("should not label accelerators on CPU only instance types", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Has(v1alpha1.InstanceAcceleratorLabelKey)).To(BeFalse())
+			})
+			It("should launch instances that require any accelerator", func() {
 				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectNotScheduled(ctx, env.Client, pod)
-				// Fallback to OD
-				pod = ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+					NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.InstanceAcceleratorLabelKey, Operator: v1.NodeSelectorOpExists}},
+				}))[0]
 				node := ExpectScheduled(ctx, env.Client, pod)
-				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeOnDemand))
+				Expect(node.Labels).To(HaveKey(v1alpha1.InstanceAcceleratorLabelKey))
 			})
-			It("should return all instance types, even though with no offerings due to Insufficient Capacity Error", func() {
-				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{
-					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
-					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
-					{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
-					{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
-				})
-				provisioner.Spec.Requirements = nil
-				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
-					Key:      v1.LabelInstanceType,
-					Operator: v1.NodeSelectorOpIn,
-					Values:   []string{"m5.xlarge"},
-				})
-				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
-					Key:      v1alpha5.LabelCapacityType,
-					Operator: v1.NodeSelectorOpIn,
-					Values:   []string{"spot", "on-demand"},
-				})
-
-				ExpectApplied(ctx, env.Client, provisioner)
-				for _, ct := range []string{v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeSpot} {
-					for _, zone := range []string{"test-zone-1a", "test-zone-1b"} {
-						ExpectProvisioned(ctx, env.Client, controller,
-							test.UnschedulablePod(test.PodOptions{
-								ResourceRequirements: v1.ResourceRequirements{
-									Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
-								},
-								NodeSelector: map[string]string{
-									v1alpha5.LabelCapacityType: ct,
-									v1.LabelTopologyZone:       zone,
-								},
-							}))
+			It("should label nested virtualization support on metal instance types", func() {
+				Expect(ExpectInstanceType(provider, "m5.metal").Requirements().Get(v1alpha1.InstanceNestedVirtualizationLabelKey).Values().List()).To(ConsistOf("true"))
+			})
+			It("should not label nested virtualization support on virtualized instance types", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Has(v1alpha1.InstanceNestedVirtualizationLabelKey)).To(BeFalse())
+			})
+			It("should label the maximum number of network interfaces", func() {
+				large := ExpectInstanceType(provider, "m5.large")
+				xlarge := ExpectInstanceType(provider, "m5.xlarge")
+				Expect(large.Requirements().Get(v1alpha1.InstanceMaxENIsLabelKey).Values().List()).To(ConsistOf(fmt.Sprint(*large.NetworkInfo.MaximumNetworkInterfaces)))
+				Expect(xlarge.Requirements().Get(v1alpha1.InstanceMaxENIsLabelKey).Values().List()).To(ConsistOf(fmt.Sprint(*xlarge.NetworkInfo.MaximumNetworkInterfaces)))
+				Expect(large.Requirements().Get(v1alpha1.InstanceMaxENIsLabelKey).Values()).ToNot(Equal(xlarge.Requirements().Get(v1alpha1.InstanceMaxENIsLabelKey).Values()))
+			})
+			It("should reserve kube-reserved memory for the AMI family", func() {
+				for _, family := range v1alpha1.SupportedAMIFamilies {
+					// macOS only runs on mac instance types
+					if family == v1alpha1.AMIFamilyMacOS {
+						continue
 					}
-				}
-
-				instanceTypeCache.Flush()
-				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner.Spec.Provider)
-				Expect(err).To(BeNil())
-				instanceTypeNames := sets.NewString()
-				for _, it := range instanceTypes {
-					instanceTypeNames.Insert(it.Name())
-					if it.Name() == "m5.xlarge" {
-						// should have no valid offerings
-						Expect(it.Offerings()).To(HaveLen(0))
+					provider.AMIFamily = aws.String(family)
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					expected := kubeReservedMemory(instanceType.effectivePods())
+					expected.Add(resource.MustParse("200Mi"))
+					if baseline, ok := AMIFamilyDaemonSetOverhead[family][v1.ResourceMemory]; ok {
+						expected.Add(baseline)
 					}
+					Expect(expected.Cmp(instanceType.Overhead()[v1.ResourceMemory])).To(Equal(0), family)
 				}
-				Expect(instanceTypeNames.Has("m5.xlarge"))
 			})
-		})
-		Context("CapacityType", func() {
-			It("should default to on-demand", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				node := ExpectScheduled(ctx, env.Client, pod)
-				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeOnDemand))
+			It("should scale kube-reserved memory with overridden max pods", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				pods := instanceType.Resources()[v1.ResourcePods]
+				memory := instanceType.Overhead()[v1.ResourceMemory]
+				provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 110}
+				overridden := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+				// kube-reserved memory grows by 11Mi per pod
+				memory.Add(resource.MustParse(fmt.Sprintf("%dMi", 11*(110-pods.Value()))))
+				Expect(overridden.Cmp(memory)).To(Equal(0))
 			})
-			It("should launch spot capacity if flexible to both spot and on demand", func() {
-				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
-					{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeOnDemand}}}
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				node := ExpectScheduled(ctx, env.Client, pod)
-				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeSpot))
+			DescribeTable("should reserve kube-reserved memory for the pod capacity",
+				func(configure func()) {
+					configure()
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					pods := instanceType.Resources()[v1.ResourcePods]
+					expected := kubeReservedMemory(pods.Value())
+					expected.Add(resource.MustParse("200Mi"))
+					Expect(expected.Cmp(instanceType.Overhead()[v1.ResourceMemory])).To(Equal(0))
+				},
+				Entry("limited by ENIs", func() {}),
+				Entry("with max pods per instance type", func() { provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 110} }),
+				Entry("with instance type overrides", func() {
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {Pods: aws.Int32(8)}}
+				}),
+				Entry("with a pod PIDs limit", func() { provider.PodPIDsLimit = aws.Int64(2048) }),
+			)
+			It("should reserve the same kube-reserved memory for Bottlerocket and AL2", func() {
+				provider.DaemonSetOverhead = v1.ResourceList{}
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyAL2)
+				al2 := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				bottlerocket := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+				Expect(al2.Cmp(bottlerocket)).To(Equal(0))
+				for _, maxPods := range []int64{8, 29, 110, 737} {
+					reserved := kubeReservedMemory(maxPods)
+					Expect(reserved.Cmp(resource.MustParse(fmt.Sprintf("%dMi", 11*maxPods+255)))).To(Equal(0))
+				}
 			})
-		})
-		Context("LaunchTemplates", func() {
-			It("should use same launch template for equivalent constraints", func() {
-				t1 := v1.Toleration{
-					Key:      "Abacus",
-					Operator: "Equal",
-					Value:    "Zebra",
-					Effect:   "NoSchedule",
+			It("should parse the family and size of instance type names", func() {
+				for name, expected := range map[string][]string{
+					"m5.large":         {"m5", "large"},
+					"p4de.24xlarge":    {"p4de", "24xlarge"},
+					"m5.metal":         {"m5", "metal"},
+					"c7i.metal-24xl":   {"c7i", "metal-24xl"},
+					"u-6tb1.112xlarge": {"u-6tb1", "112xlarge"},
+				} {
+					family, size, ok := ParseInstanceType(name)
+					Expect(ok).To(BeTrue(), name)
+					Expect([]string{family, size}).To(Equal(expected), name)
 				}
-				t2 := v1.Toleration{
-					Key:      "Zebra",
-					Operator: "Equal",
-					Value:    "Abacus",
-					Effect:   "NoSchedule",
+			})
+			It("should not parse malformed instance type names", func() {
+				for _, name := range []string{"", "m5", "m5.", ".large", "m5.large.extra", "."} {
+					family, size, ok := ParseInstanceType(name)
+					Expect(ok).To(BeFalse(), name)
+					Expect(family).To(BeEmpty(), name)
+					Expect(size).To(BeEmpty(), name)
 				}
-				t3 := v1.Toleration{
-					Key:      "Boar",
-					Operator: "Equal",
-					Value:    "Abacus",
-					Effect:   "NoSchedule",
+			})
+			It("should rank instance sizes monotonically", func() {
+				ladder := []string{"nano", "micro", "small", "medium", "large", "xlarge", "2xlarge", "3xlarge", "4xlarge",
+					"6xlarge", "8xlarge", "9xlarge", "12xlarge", "16xlarge", "18xlarge", "24xlarge", "32xlarge", "metal"}
+				previous := int64(0)
+				for _, size := range ladder {
+					ordinal, ok := instanceSizeOrdinal(size)
+					Expect(ok).To(BeTrue(), size)
+					Expect(ordinal).To(BeNumerically(">", previous), size)
+					previous = ordinal
 				}
-
-				// constrain the packer to a single launch template type
-				rr := v1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceCPU:             resource.MustParse("24"),
-						v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
-					},
-					Limits: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+			})
+			It("should scale multiplied instance sizes relative to xlarge", func() {
+				xlarge, _ := instanceSizeOrdinal("xlarge")
+				for multiplier := int64(2); multiplier <= 32; multiplier++ {
+					ordinal, ok := instanceSizeOrdinal(fmt.Sprintf("%dxlarge", multiplier))
+					Expect(ok).To(BeTrue())
+					Expect(ordinal).To(Equal(multiplier * xlarge))
 				}
-
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod1 := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						Tolerations:          []v1.Toleration{t1, t2, t3},
-						ResourceRequirements: rr,
-					}),
-				)[0]
-				ExpectScheduled(ctx, env.Client, pod1)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				name1 := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput).LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName
-
-				pod2 := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{
-						Tolerations:          []v1.Toleration{t2, t3, t1},
-						ResourceRequirements: rr,
-					}),
-				)[0]
-
-				ExpectScheduled(ctx, env.Client, pod2)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				name2 := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput).LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName
-				Expect(name1).To(Equal(name2))
 			})
-			It("should tag with provisioner name", func() {
-				provisionerName := "the-provisioner"
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: provisionerName}}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
-
-				tags := map[string]string{
-					v1alpha5.ProvisionerNameLabelKey: provisionerName,
-					"Name":                           fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, provisionerName),
+			It("should rank partial metal sizes as the multiplied size they match", func() {
+				metal, _ := instanceSizeOrdinal("metal")
+				for size, multiplied := range map[string]string{
+					"metal-16xl": "16xlarge",
+					"metal-24xl": "24xlarge",
+					"metal-48xl": "48xlarge",
+				} {
+					ordinal, ok := instanceSizeOrdinal(size)
+					Expect(ok).To(BeTrue(), size)
+					expected, _ := instanceSizeOrdinal(multiplied)
+					Expect(ordinal).To(Equal(expected), size)
+					Expect(ordinal).To(BeNumerically("<", metal), size)
 				}
-				// tags should be included in both the instance and volume tag specification
-				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
-				ExpectTags(createFleetInput.TagSpecifications[0].Tags, tags)
-
-				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
-				ExpectTags(createFleetInput.TagSpecifications[1].Tags, tags)
 			})
-			It("should request that tags be applied to both instances and volumes", func() {
-				provider.Tags = map[string]string{
-					"tag1": "tag1value",
-					"tag2": "tag2value",
+			It("should not rank unrecognized instance sizes", func() {
+				for _, size := range []string{"", "huge", "xlargex", "0xlarge", "-2xlarge", "metal-xl", "metal-0xl", "metal-24xlarge", "metal-24"} {
+					_, ok := instanceSizeOrdinal(size)
+					Expect(ok).To(BeFalse(), size)
 				}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
-
-				// tags should be included in both the instance and volume tag specification
-				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
-				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
-
-				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
-				ExpectTags(createFleetInput.TagSpecifications[1].Tags, provider.Tags)
 			})
-
-			It("should override default tag names", func() {
-				// these tags are defaulted, so ensure users can override them
-				provider.Tags = map[string]string{
-					v1alpha5.ProvisionerNameLabelKey: "myprovisioner",
-					"Name":                           "myname",
+			It("should be compatible with requirements it satisfies", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a", "test-zone-2a"}},
+					v1.NodeSelectorRequirement{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpNotIn, Values: []string{v1alpha5.ArchitectureArm64}},
+					v1.NodeSelectorRequirement{Key: "custom-label", Operator: v1.NodeSelectorOpIn, Values: []string{"custom-value"}},
+				))).To(Succeed())
+			})
+			It("should not be compatible with zones it isn't offered in", func() {
+				err := ExpectInstanceType(provider, "m5.large").Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-2a"}},
+				))
+				Expect(err).To(MatchError(ContainSubstring("instance type m5.large not compatible with %s requirement [test-zone-2a]", v1.LabelTopologyZone)))
+			})
+			It("should not be compatible with other architectures", func() {
+				err := ExpectInstanceType(provider, "c6g.large").Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureAmd64}},
+				))
+				Expect(err).To(MatchError(ContainSubstring("instance type c6g.large not compatible with %s requirement [%s]", v1.LabelArchStable, v1alpha5.ArchitectureAmd64)))
+			})
+			It("should not satisfy any architecture with an unrecognized architecture", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.ProcessorInfo = &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"i386"})}
+				instanceType := NewTestInstanceType(provider, &info)
+				_, err := instanceType.architecture()
+				Expect(err).To(MatchError(ContainSubstring("unrecognized architectures [i386]")))
+				Expect(instanceType.Requirements().Get(v1.LabelArchStable).Len()).To(BeZero())
+				Expect(instanceType.Compatible(scheduling.NewNodeSelectorRequirements(
+					v1.NodeSelectorRequirement{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureAmd64, v1alpha5.ArchitectureArm64}},
+				))).ToNot(Succeed())
+				Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeFalse())
+			})
+			It("should recognize supported architectures", func() {
+				for name, expected := range map[string]string{"m5.large": v1alpha5.ArchitectureAmd64, "c6g.large": v1alpha5.ArchitectureArm64} {
+					architecture, err := ExpectInstanceType(provider, name).architecture()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(architecture).To(Equal(expected))
 				}
-
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
-
-				// tags should be included in both the instance and volume tag specification
-				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
-				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
-
-				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
-				ExpectTags(createFleetInput.TagSpecifications[1].Tags, provider.Tags)
 			})
-			It("should default to a generated launch template", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-
-				firstLt := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				launchTemplate := createFleetInput.LaunchTemplateConfigs[0].LaunchTemplateSpecification
-				Expect(createFleetInput.LaunchTemplateConfigs).To(HaveLen(1))
-
-				Expect(*createFleetInput.LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName).
-					To(Equal(*firstLt.LaunchTemplateName))
-				Expect(firstLt.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Encrypted).To(Equal(aws.Bool(true)))
-				Expect(*launchTemplate.Version).To(Equal("$Latest"))
+			It("should recognize architectures mapped by the provider", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.ProcessorInfo = &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"riscv64"})}
+				provider.ArchitectureMappings = map[string]string{"riscv64": "riscv64"}
+				instanceType := NewTestInstanceType(provider, &info)
+				architecture, err := instanceType.architecture()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(architecture).To(Equal("riscv64"))
+				Expect(instanceType.Requirements().Get(v1.LabelArchStable).Values().List()).To(ConsistOf("riscv64"))
+				Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeTrue())
 			})
-			It("should allow a launch template to be specified", func() {
-				provider.LaunchTemplateName = aws.String("test-launch-template")
-				provider.SecurityGroupSelector = nil
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(input.LaunchTemplateConfigs).To(HaveLen(1))
-				launchTemplate := input.LaunchTemplateConfigs[0].LaunchTemplateSpecification
-				Expect(*launchTemplate.LaunchTemplateName).To(Equal("test-launch-template"))
-				Expect(*launchTemplate.Version).To(Equal("$Latest"))
+			It("should discover instance types with architectures mapped by the provider", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("r8v.large")
+				info.ProcessorInfo = &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"riscv64"})}
+				fakeEC2API.DescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{InstanceTypes: []*ec2.InstanceTypeInfo{&info}}
+				fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					{InstanceType: aws.String("r8v.large"), Location: aws.String("test-zone-1a")},
+				}}
+				instanceTypeCache.Flush()
+				Expect(ExpectInstanceTypeNames(provider)).To(BeEmpty())
+				provider.ArchitectureMappings = map[string]string{"riscv64": "riscv64"}
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("r8v.large"))
 			})
-		})
-		Context("Subnets", func() {
-			It("should default to the cluster's subnets", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(
-					test.PodOptions{NodeSelector: map[string]string{v1.LabelArchStable: v1alpha5.ArchitectureAmd64}}))[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(input.LaunchTemplateConfigs).To(HaveLen(1))
-
-				foundNonGPULT := false
-				for _, v := range input.LaunchTemplateConfigs {
-					for _, ov := range v.Overrides {
-						if *ov.InstanceType == "m5.large" {
-							foundNonGPULT = true
-							Expect(v.Overrides).To(ContainElements(
-								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test1"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
-								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test2"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1b")},
-								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test3"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1c")},
-							))
-						}
-					}
+			It("should preserve the built-in architectures when the provider maps architectures", func() {
+				provider.ArchitectureMappings = map[string]string{"riscv64": "riscv64"}
+				for name, expected := range map[string]string{"m5.large": v1alpha5.ArchitectureAmd64, "c6g.large": v1alpha5.ArchitectureArm64} {
+					architecture, err := ExpectInstanceType(provider, name).architecture()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(architecture).To(Equal(expected))
 				}
-				Expect(foundNonGPULT).To(BeTrue())
+				Expect(kubeArchitectures(provider)).To(HaveKeyWithValue("riscv64", "riscv64"))
+				Expect(v1alpha1.AWSToKubeArchitectures).ToNot(HaveKey("riscv64"))
 			})
-			It("should launch instances into subnet with the most available IP addresses", func() {
+			Context("Capacity Type Compatibility", func() {
+				var info ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					info = *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				})
+				capacityTypeRequirements := func(operator v1.NodeSelectorOperator, capacityTypes ...string) scheduling.Requirements {
+					return scheduling.NewNodeSelectorRequirements(v1.NodeSelectorRequirement{Key: v1alpha5.LabelCapacityType, Operator: operator, Values: capacityTypes})
+				}
+				It("should name the missing capacity type for spot only instance types", func() {
+					info.SupportedUsageClasses = aws.StringSlice([]string{v1alpha1.CapacityTypeSpot})
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeSpot))).To(Succeed())
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeOnDemand))).To(MatchError(
+						"instance type m5.large has no offerings for capacity types [on-demand], offered capacity types are [spot]"))
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpNotIn, v1alpha1.CapacityTypeSpot))).To(MatchError(
+						"instance type m5.large has no offerings for capacity types other than [spot], offered capacity types are [spot]"))
+				})
+				It("should name the missing capacity type for on-demand only instance types", func() {
+					info.SupportedUsageClasses = aws.StringSlice([]string{v1alpha1.CapacityTypeOnDemand})
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeOnDemand))).To(Succeed())
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeSpot))).To(MatchError(
+						"instance type m5.large has no offerings for capacity types [spot], offered capacity types are [on-demand]"))
+				})
+				It("should be compatible with either capacity type when both are offered", func() {
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeOnDemand))).To(Succeed())
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeSpot))).To(Succeed())
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeOnDemand))).To(Succeed())
+					Expect(instanceType.Compatible(capacityTypeRequirements(v1.NodeSelectorOpIn, v1alpha1.CapacityTypeCapacityBlock))).To(MatchError(
+						ContainSubstring("offered capacity types are [on-demand spot]")))
+				})
+			})
+			Context("Explain", func() {
+				It("should explain a zone mismatch", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Explain(scheduling.NewNodeSelectorRequirements(
+						v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-2a"}},
+					))).To(Equal("topology.kubernetes.io/zone: instance type m5.large has [test-zone-1a test-zone-1b test-zone-1c], requested [test-zone-2a], incompatible"))
+				})
+				It("should explain an arch mismatch alongside compatible requirements", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Explain(scheduling.NewNodeSelectorRequirements(
+						v1.NodeSelectorRequirement{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}},
+						v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpNotIn, Values: []string{"test-zone-1a"}},
+					))).To(Equal("kubernetes.io/arch: instance type m5.large has [amd64], requested [arm64], incompatible\n" +
+						"topology.kubernetes.io/zone: instance type m5.large has [test-zone-1a test-zone-1b test-zone-1c], requested any value except [test-zone-1a], compatible"))
+				})
+				It("should ignore custom labels", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Explain(scheduling.NewLabelRequirements(map[string]string{"team": "a"}))).To(BeEmpty())
+				})
+			})
+			It("should only offer instance types in zones with subnets", func() {
+				fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{
+					InstanceTypeOfferings: lo.Map([]string{"test-zone-1a", "test-zone-1b", "test-zone-1c", "test-zone-1d"}, func(zone string, _ int) *ec2.InstanceTypeOffering {
+						return &ec2.InstanceTypeOffering{InstanceType: aws.String("m5.large"), Location: aws.String(zone)}
+					}),
+				}
 				fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-					{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
-						Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
-					{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
-						Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
+					{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1a")},
+					{SubnetId: aws.String("subnet-test2"), AvailabilityZone: aws.String("test-zone-1b")},
 				}}
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"}}))[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("test-subnet-2"))
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(lo.Uniq(lo.Map(instanceType.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.Zone }))).To(ConsistOf("test-zone-1a", "test-zone-1b"))
+				Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf("test-zone-1a", "test-zone-1b"))
 			})
-			It("should discover subnet by ID", func() {
-				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-test1"))
+			It("should label the number of zones the instance type is offered in", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceZoneCountLabelKey).Values().List()).To(ConsistOf("3"))
+				fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+					{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1a")},
+					{SubnetId: aws.String("subnet-test2"), AvailabilityZone: aws.String("test-zone-1b")},
+				}}
+				subnetCache.Flush()
+				Expect(ExpectInstanceType(provider, "m5.large").Requirements().Get(v1alpha1.InstanceZoneCountLabelKey).Values().List()).To(ConsistOf("2"))
 			})
-			It("should discover subnets by IDs", func() {
-				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1,subnet-test2"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
-					"subnet-test1",
-					"subnet-test2",
-				))
+			It("should label the zone and capacity types of instance types offered in a single zone", func() {
+				fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+					{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1a")},
+				}}
+				subnetCache.Flush()
+				requirements := ExpectInstanceType(provider, "m5.large").Requirements()
+				Expect(requirements.Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf("test-zone-1a"))
+				Expect(requirements.Get(v1alpha1.InstanceZoneCountLabelKey).Values().List()).To(ConsistOf("1"))
+				Expect(requirements.Get(v1alpha5.LabelCapacityType).Values().List()).To(ConsistOf(v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeSpot))
 			})
-			It("should discover subnets by IDs and tags", func() {
-				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1,subnet-test2", "foo": "bar"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
-					"subnet-test1",
-					"subnet-test2",
-				))
+			It("should not offer instance types in excluded zones", func() {
+				provider.ExcludedZones = []string{"test-zone-1b"}
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(lo.Uniq(lo.Map(instanceType.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.Zone }))).To(ConsistOf("test-zone-1a", "test-zone-1c"))
+				Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf("test-zone-1a", "test-zone-1c"))
 			})
-			It("should discover subnets by IDs intersected with tags", func() {
-				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test2", "foo": "bar"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
-				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
-					"subnet-test2",
-				))
+			It("should constrain zones by each provider's subnets", func() {
+				ExpectInstanceType(provider, "m5.large")
+				fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+					{SubnetId: aws.String("subnet-test3"), AvailabilityZone: aws.String("test-zone-1c")},
+				}}
+				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test3"}
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf("test-zone-1c"))
 			})
-		})
-		Context("Security Groups", func() {
-			It("should default to the clusters security groups", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"sg-test1",
-					"sg-test2",
-					"sg-test3",
-				))
+			It("should exclude instance families", func() {
+				provider.ExcludedInstanceFamilies = []string{"m5", "inf1"}
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("t3.large", "p3.8xlarge", "c6g.large"))
 			})
-			It("should discover security groups by tag", func() {
-				fakeEC2API.DescribeSecurityGroupsOutput = &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
-					{GroupId: aws.String("test-sg-1"), Tags: []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-1")}}},
-					{GroupId: aws.String("test-sg-2"), Tags: []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-2")}}},
-				}}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"test-sg-1",
-					"test-sg-2",
-				))
+			It("should only include instance families matching a pattern", func() {
+				m5 := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				provider.InstanceFamilyPatterns = []string{"c7*"}
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				for name, included := range map[string]bool{"c7g.large": true, "c7i.large": true, "c6i.large": false} {
+					info := m5
+					info.InstanceType = aws.String(name)
+					Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(Equal(included), name)
+				}
+				Expect(ExpectInstanceTypeNames(provider).List()).To(BeEmpty())
 			})
-			It("should discover security groups by ID", func() {
-				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"sg-test1",
-				))
+			It("should include instance families matching any of the patterns", func() {
+				provider.InstanceFamilyPatterns = []string{"m*", "t3"}
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("t3.large", "m5.large", "m5.xlarge", "m5.metal"))
 			})
-			It("should discover security groups by IDs", func() {
-				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1,sg-test2"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"sg-test1",
-					"sg-test2",
-				))
+			It("should exclude metal instance types", func() {
+				provider.ExcludeMetal = aws.Bool(true)
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.Has("m5.metal")).To(BeFalse())
+				Expect(names.HasAll("m5.large", "m5.xlarge", "t3.large")).To(BeTrue())
 			})
-			It("should discover security groups by IDs and tags", func() {
-				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1,sg-test2", "foo": "bar"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"sg-test1",
-					"sg-test2",
-				))
+			It("should include metal instance types by default", func() {
+				Expect(ExpectInstanceTypeNames(provider).Has("m5.metal")).To(BeTrue())
 			})
-			It("should discover security groups by IDs intersected with tags", func() {
-				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test2", "foo": "bar"}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
-					"sg-test2",
-				))
+			It("should summarize the instance type as a string", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").String()).To(Equal(
+					"m5.large (vcpu=2/memory=7577Mi/zones=test-zone-1a,test-zone-1b,test-zone-1c/capacityTypes=on-demand,spot)"))
 			})
-		})
-		Context("User Data", func() {
-			It("should not specify --use-max-pods=false when using ENI-based pod density", func() {
-				opts.AWSENILimitedPodDensity = true
-				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).NotTo(ContainSubstring("--use-max-pods false"))
+			It("should summarize the instance type as structured logging values", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").LogValues()).To(Equal([]interface{}{
+					"instance-type", "m5.large",
+					"vcpu", "2",
+					"memory", "7577Mi",
+					"zones", []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"},
+					"capacity-types", []string{"on-demand", "spot"},
+				}))
 			})
-			It("should specify --use-max-pods=false when not using ENI-based pod density", func() {
-				opts.AWSENILimitedPodDensity = false
-				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).To(ContainSubstring("--use-max-pods false"))
-				Expect(string(userData)).To(ContainSubstring("--max-pods=110"))
+			It("should rank cost efficiency independently of absolute price", func() {
+				small := ExpectInstanceType(provider, "m5.large")
+				large := ExpectInstanceType(provider, "inf1.2xlarge")
+				Expect(small.Price()).To(BeNumerically("<", large.Price()))
+				Expect(small.PricePerVCPU()).To(BeNumerically(">", large.PricePerVCPU()))
+				Expect(small.PricePerVCPU()).To(BeNumerically("~", small.Price()/2))
+				Expect(large.PricePerVCPU()).To(BeNumerically("~", large.Price()/8))
 			})
-			It("should specify --container-runtime containerd by default", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).To(ContainSubstring("--container-runtime containerd"))
+			It("should price offerings the same regardless of capacity type by default", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1a"})).To(Equal(instanceType.Price()))
+				Expect(instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeOnDemand, Zone: "test-zone-1a"})).To(Equal(instanceType.Price()))
 			})
-			It("should specify dockerd if specified in the provisionerSpec", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{
-					Provider: provider,
-					Kubelet:  &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")},
-				}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).To(ContainSubstring("--container-runtime dockerd"))
+			It("should rank spot offerings below on-demand offerings of the same instance type", func() {
+				provider.SpotDiscountPercentage = aws.Int64(30)
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				spot := instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1a"})
+				onDemand := instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeOnDemand, Zone: "test-zone-1a"})
+				Expect(spot).To(BeNumerically("<", onDemand))
+				Expect(spot).To(BeNumerically("~", instanceType.Price()*0.7))
+				Expect(onDemand).To(Equal(instanceType.Price()))
 			})
-			It("should specify --container-runtime docker when using Neuron GPUs", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
-					ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceCPU:             resource.MustParse("1"),
-							v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
-						},
-						Limits: map[v1.ResourceName]resource.Quantity{
-							v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
-						},
-					},
-				}))[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).To(ContainSubstring("--container-runtime docker"))
+			It("should scale the price of instance families", func() {
+				price := ExpectInstanceType(provider, "m5.xlarge").Price()
+				provider.InstanceFamilyPricePercentages = map[string]int64{"m5": 40}
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Price()).To(BeNumerically("~", price*0.4))
 			})
-			It("should specify --container-runtime containerd when using Nvidia GPUs", func() {
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
-					ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceCPU:             resource.MustParse("1"),
-							v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
-						},
-						Limits: map[v1.ResourceName]resource.Quantity{
-							v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
-						},
-					},
-				}))[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				Expect(string(userData)).To(ContainSubstring("--container-runtime containerd"))
+			It("should rank a covered family below an uncovered family that is cheaper on paper", func() {
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Price()).To(BeNumerically(">", ExpectInstanceType(provider, "t3.large").Price()))
+				provider.InstanceFamilyPricePercentages = map[string]int64{"m5": 40}
+				Expect(ExpectInstanceType(provider, "m5.xlarge").Price()).To(BeNumerically("<", ExpectInstanceType(provider, "t3.large").Price()))
 			})
-			Context("Bottlerocket", func() {
-				It("should merge in custom user data", func() {
-					opts.AWSENILimitedPodDensity = false
-					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-					content, _ := ioutil.ReadFile("testdata/br_userdata_input.golden")
-					providerRefName := strings.ToLower(randomdata.SillyName())
-					providerRef := &v1alpha5.ProviderRef{
-						Name: providerRefName,
-					}
-					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
-						UserData:   aws.String(string(content)),
-						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
-					ExpectApplied(ctx, env.Client, nodeTemplate)
-					controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
-					ExpectApplied(ctx, env.Client, newProvisioner)
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					ExpectScheduled(ctx, env.Client, pod)
-					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-					content, _ = ioutil.ReadFile("testdata/br_userdata_merged.golden")
-					// Newlines are always added for missing TOML fields, so strip them out before comparisons.
-					actualUserData := strings.Replace(string(userData), "\n", "", -1)
-					expectedUserData := strings.Replace(fmt.Sprintf(string(content), newProvisioner.Name), "\n", "", -1)
-					Expect(expectedUserData).To(Equal(actualUserData))
+			It("should exclude instance types priced above the max price", func() {
+				provider.MaxPrice = aws.Float64(ExpectInstanceType(provider, "m5.large").Price())
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.Has("m5.large")).To(BeTrue())
+				Expect(names.HasAny("m5.xlarge", "p3.8xlarge", "inf1.6xlarge")).To(BeFalse())
+				for _, instanceType := range ExpectInstanceTypes(provider) {
+					Expect(instanceType.(*InstanceType).Price()).To(BeNumerically("<=", *provider.MaxPrice))
+				}
+			})
+			It("should exclude compute optimized instance types below the min memory per vCPU", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				graviton := ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+				provider.MinMemoryPerVCPU = aws.Float64(6)
+				c5, r5 := info, info
+				c5.InstanceType = aws.String("c5.large")
+				c5.MemoryInfo = &ec2.MemoryInfo{SizeInMiB: aws.Int64(4096)}
+				computeOptimized := NewTestInstanceType(provider, &c5)
+				r5.InstanceType = aws.String("r5.large")
+				r5.MemoryInfo = &ec2.MemoryInfo{SizeInMiB: aws.Int64(16384)}
+				memoryOptimized := NewTestInstanceType(provider, &r5)
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				Expect(instanceTypeProvider.filterByProvider(computeOptimized, provider)).To(BeFalse())
+				Expect(instanceTypeProvider.filterByProvider(memoryOptimized, provider)).To(BeTrue())
+				Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, graviton), provider)).To(BeFalse())
+			})
+			It("should compare the min memory per vCPU against memory after the VM overhead", func() {
+				// m5.large has 4GiB per vCPU, of which less than 3.75GiB remains after the VM overhead
+				provider.MinMemoryPerVCPU = aws.Float64(3.75)
+				Expect(ExpectInstanceTypeNames(provider).Has("m5.large")).To(BeFalse())
+				provider.MinMemoryPerVCPU = aws.Float64(3.5)
+				Expect(ExpectInstanceTypeNames(provider).Has("m5.large")).To(BeTrue())
+			})
+			It("should estimate the memory of multi-TiB instance types without overflowing", func() {
+				info := *ExpectInstanceType(provider, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("u-24tb1.112xlarge")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(448)}
+				info.MemoryInfo = &ec2.MemoryInfo{SizeInMiB: aws.Int64(24 * 1024 * 1024)}
+				memory := NewTestInstanceType(provider, &info).memory()
+				// 92.5% of 25165824Mi, rounded down to a whole MiB
+				Expect(memory.Value()).To(Equal(int64(23278387) * 1024 * 1024))
+				Expect(memory.String()).To(Equal("23278387Mi"))
+			})
+			It("should estimate memory with the VM overhead rounded down to a whole MiB", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				for sizeInMiB, expected := range map[int64]string{1: "0", 8192: "7577Mi", 16384: "15155Mi", 786432: "727449Mi"} {
+					info.MemoryInfo = &ec2.MemoryInfo{SizeInMiB: aws.Int64(sizeInMiB)}
+					memory := NewTestInstanceType(provider, &info).memory()
+					Expect(memory.Cmp(resource.MustParse(expected))).To(Equal(0), fmt.Sprint(sizeInMiB))
+				}
+			})
+			It("should exclude nano and micro instance types below the min vCPU", func() {
+				provider.MinVCPU = aws.Int64(2)
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				for _, name := range []string{"t2.nano", "t2.micro"} {
+					info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+					info.InstanceType = aws.String(name)
+					info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(1)}
+					Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(BeFalse())
+				}
+				Expect(ExpectInstanceTypeNames(provider).HasAll("t3.large", "m5.large", "c6g.large")).To(BeTrue())
+				provider.MinVCPU = aws.Int64(4)
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.HasAny("t3.large", "m5.large", "c6g.large")).To(BeFalse())
+				Expect(names.HasAll("m5.xlarge", "m5.metal")).To(BeTrue())
+			})
+			It("should exclude single GPU instance types below the min GPUs", func() {
+				m5 := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				provider.MinGPUs = aws.Int64(2)
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				for manufacturer, name := range map[string]string{"NVIDIA": "g4dn.xlarge", "AMD": "g4ad.xlarge"} {
+					info := m5
+					info.InstanceType = aws.String(name)
+					info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+						Name: aws.String("GPU"), Manufacturer: aws.String(manufacturer), Count: aws.Int64(1),
+						MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+					}}}
+					Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(BeFalse(), name)
+					info.GpuInfo.Gpus[0].Count = aws.Int64(2)
+					Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(BeTrue(), name)
+				}
+				// p3.8xlarge has 4 GPUs, and instance types without GPUs are excluded
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("p3.8xlarge"))
+			})
+			It("should count physical GPUs against the min GPUs", func() {
+				info := *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+				provider.MinGPUs = aws.Int64(2)
+				provider.GPUReplicaFactor = aws.Int64(4)
+				info.InstanceType = aws.String("g4dn.xlarge")
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+					Name: aws.String("T4"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(1),
+					MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+				}}}
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("4")))
+				Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeFalse())
+			})
+			It("should compare burstable instance types by their baseline vCPUs against the min vCPU", func() {
+				provider.MinVCPU = aws.Int64(1)
+				Expect(ExpectInstanceTypeNames(provider).Has("t3.large")).To(BeTrue())
+				baseline := resource.MustParse("300m")
+				provider.BurstableVCPUBaseline = &baseline
+				Expect(ExpectInstanceTypeNames(provider).Has("t3.large")).To(BeFalse())
+			})
+			It("should only exclude offerings priced above the max price", func() {
+				provider.SpotDiscountPercentage = aws.Int64(50)
+				price := ExpectInstanceType(provider, "m5.xlarge").Price()
+				provider.MaxPrice = aws.Float64(price * 0.75)
+				instanceType := ExpectInstanceType(provider, "m5.xlarge")
+				Expect(instanceType.Offerings()).ToNot(BeEmpty())
+				for _, offering := range instanceType.Offerings() {
+					Expect(offering.CapacityType).To(Equal(v1alpha1.CapacityTypeSpot))
+				}
+				Expect(instanceType.Requirements().Get(v1alpha5.LabelCapacityType).Values().List()).To(ConsistOf(v1alpha1.CapacityTypeSpot))
+			})
+			It("should order offerings by zone and capacity type", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Offerings()).To(Equal([]cloudprovider.Offering{
+					{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1c", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					{Zone: "test-zone-1c", CapacityType: v1alpha1.CapacityTypeSpot},
+				}))
+			})
+			It("should deduplicate and sort offerings", func() {
+				Expect(normalizeOfferings([]cloudprovider.Offering{
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeSpot},
+				})).To(Equal([]cloudprovider.Offering{
+					{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeSpot},
+				}))
+			})
+			Context("Memory Correction", func() {
+				var memoryCorrectionCtx context.Context
+				var instanceTypeProvider *InstanceTypeProvider
+				BeforeEach(func() {
+					opts := opts
+					opts.AWSEnableMemoryCorrection = true
+					memoryCorrectionCtx = injection.WithOptions(ctx, opts)
+					instanceTypeProvider = cloudProvider.(*CloudProvider).instanceTypeProvider
+					instanceTypeProvider.memoryCorrections = NewMemoryCorrections()
 				})
-				It("should bootstrap when custom user data is empty", func() {
-					opts.AWSENILimitedPodDensity = false
-					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-					providerRefName := strings.ToLower(randomdata.SillyName())
-					providerRef := &v1alpha5.ProviderRef{
-						Name: providerRefName,
+				getInstanceType := func(ctx context.Context, name string) *InstanceType {
+					instanceTypes, err := instanceTypeProvider.Get(ctx, provider)
+					Expect(err).ToNot(HaveOccurred())
+					instanceType, ok := lo.Find(instanceTypes, func(instanceType cloudprovider.InstanceType) bool { return instanceType.Name() == name })
+					Expect(ok).To(BeTrue())
+					return instanceType.(*InstanceType)
+				}
+				// allocatable returns the allocatable memory of a node with factor times the predicted memory
+				allocatable := func(instanceType *InstanceType, factor float64) v1.ResourceList {
+					memory := *resource.NewQuantity(int64(float64(instanceType.resources.Memory().Value())*factor), resource.BinarySI)
+					memory.Sub(*instanceType.overhead.Memory())
+					return v1.ResourceList{v1.ResourceMemory: memory}
+				}
+				It("should not correct memory before any nodes are observed", func() {
+					Expect(getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()).To(Equal(getInstanceType(ctx, "m5.large").resources.Memory().Value()))
+				})
+				It("should correct memory from the allocatable memory reported by nodes", func() {
+					instanceType := getInstanceType(memoryCorrectionCtx, "m5.large")
+					before := instanceType.resources.Memory().Value()
+					instanceType.RecordAllocatable(allocatable(instanceType, 0.96))
+					Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(BeNumerically("~", 0.99, 0.0001))
+					Expect(getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()).To(BeNumerically("<", before))
+					Expect(getInstanceType(memoryCorrectionCtx, "m5.xlarge").resources.Memory().Value()).To(Equal(getInstanceType(ctx, "m5.xlarge").resources.Memory().Value()))
+				})
+				It("should converge on the observed allocatable memory", func() {
+					for i := 0; i < 50; i++ {
+						instanceType := getInstanceType(memoryCorrectionCtx, "m5.large")
+						instanceType.RecordAllocatable(allocatable(getInstanceType(ctx, "m5.large"), 1.05))
 					}
-					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
-						UserData:   nil,
-						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
-					ExpectApplied(ctx, env.Client, nodeTemplate)
-					controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
-					ExpectApplied(ctx, env.Client, newProvisioner)
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					ExpectScheduled(ctx, env.Client, pod)
-					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-					content, _ := ioutil.ReadFile("testdata/br_userdata_unmerged.golden")
-					actualUserData := strings.Replace(string(userData), "\n", "", -1)
-					expectedUserData := strings.Replace(fmt.Sprintf(string(content), newProvisioner.Name), "\n", "", -1)
-					Expect(expectedUserData).To(Equal(actualUserData))
+					Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(BeNumerically("~", 1.05, 0.001))
 				})
-				It("should not bootstrap when provider ref points to a non-existent resource", func() {
-					opts.AWSENILimitedPodDensity = false
-					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-					providerRef := &v1alpha5.ProviderRef{
-						Name: "doesnotexist",
+				It("should bound the correction", func() {
+					for i := 0; i < 50; i++ {
+						instanceType := getInstanceType(memoryCorrectionCtx, "m5.large")
+						instanceType.RecordAllocatable(allocatable(instanceType, 0.5))
 					}
-					controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
-					ExpectApplied(ctx, env.Client, newProvisioner)
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					// This will not be scheduled since we were pointed to a non-existent awsnodetemplate resource.
-					ExpectNotScheduled(ctx, env.Client, pod)
+					Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(Equal(MinMemoryCorrectionFactor))
+					for i := 0; i < 50; i++ {
+						instanceType := getInstanceType(memoryCorrectionCtx, "m5.large")
+						instanceType.RecordAllocatable(allocatable(instanceType, 2))
+					}
+					Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(Equal(MaxMemoryCorrectionFactor))
 				})
-				It("should not bootstrap on invalid toml user data", func() {
-					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-					providerRefName := strings.ToLower(randomdata.SillyName())
-					providerRef := &v1alpha5.ProviderRef{
-						Name: providerRefName,
+				It("should not correct memory unless enabled", func() {
+					instanceType := getInstanceType(ctx, "m5.large")
+					before := instanceType.resources.Memory().Value()
+					instanceType.RecordAllocatable(allocatable(instanceType, 0.95))
+					Expect(getInstanceType(ctx, "m5.large").resources.Memory().Value()).To(Equal(before))
+				})
+				Context("Persistence", func() {
+					AfterEach(func() {
+						err := clientSet.CoreV1().ConfigMaps("default").Delete(ctx, MemoryCorrectionsConfigMapName, metav1.DeleteOptions{})
+						Expect(client.IgnoreNotFound(err)).To(Succeed())
+					})
+					It("should restore persisted corrections after a restart", func() {
+						instanceType := getInstanceType(memoryCorrectionCtx, "m5.large")
+						instanceType.RecordAllocatable(allocatable(instanceType, 0.96))
+						corrected := getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()
+						Expect(instanceTypeProvider.memoryCorrections.Persist(ctx, clientSet, "default")).To(Succeed())
+						// Corrections that changed are persisted again, updating the config map
+						instanceType.RecordAllocatable(allocatable(instanceType, 0.96))
+						Expect(instanceTypeProvider.memoryCorrections.Persist(ctx, clientSet, "default")).To(Succeed())
+						factor := instanceTypeProvider.memoryCorrections.Factor("m5.large")
+						corrected = getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()
+
+						instanceTypeProvider.memoryCorrections = NewMemoryCorrections()
+						Expect(getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()).ToNot(Equal(corrected))
+						Expect(instanceTypeProvider.memoryCorrections.Load(ctx, clientSet, "default")).To(Succeed())
+						Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(Equal(factor))
+						Expect(getInstanceType(memoryCorrectionCtx, "m5.large").resources.Memory().Value()).To(Equal(corrected))
+					})
+					It("should not load corrections before any are persisted", func() {
+						Expect(instanceTypeProvider.memoryCorrections.Load(ctx, clientSet, "default")).To(Succeed())
+						Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(Equal(1.0))
+					})
+					It("should bound persisted corrections", func() {
+						_, err := clientSet.CoreV1().ConfigMaps("default").Create(ctx, &v1.ConfigMap{
+							ObjectMeta: metav1.ObjectMeta{Name: MemoryCorrectionsConfigMapName, Namespace: "default"},
+							Data:       map[string]string{"m5.large": "2", "m5.xlarge": "invalid"},
+						}, metav1.CreateOptions{})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(instanceTypeProvider.memoryCorrections.Load(ctx, clientSet, "default")).To(Succeed())
+						Expect(instanceTypeProvider.memoryCorrections.Factor("m5.large")).To(Equal(MaxMemoryCorrectionFactor))
+						Expect(instanceTypeProvider.memoryCorrections.Factor("m5.xlarge")).To(Equal(1.0))
+					})
+				})
+			})
+			Context("Max Pods", func() {
+				var suiteCtx context.Context
+				BeforeEach(func() {
+					// The trunk ENI reservation of pod ENI is covered separately
+					suiteCtx = ctx
+					opts := opts
+					opts.AWSEnablePodENI = false
+					ctx = injection.WithOptions(ctx, opts)
+				})
+				AfterEach(func() {
+					ctx = suiteCtx
+					ENILimitedMaxPodsSource = FormulaMaxPodsSource{}
+				})
+				DescribeTable("should limit pods",
+					func(name string, pods string, configure func(*options.Options)) {
+						opts := injection.GetOptions(ctx)
+						configure(&opts)
+						ctx = injection.WithOptions(ctx, opts)
+						Expect(ExpectInstanceType(provider, name).Resources()[v1.ResourcePods]).To(Equal(resource.MustParse(pods)))
+					},
+					Entry("by ENIs by default", "m5.large", "89", func(*options.Options) {}),
+					Entry("by the cluster wide pod density over ENI limits", "m5.large", "110", func(opts *options.Options) {
+						opts.AWSENILimitedPodDensity = false
+					}),
+					Entry("by per instance type pod density over the cluster wide pod density", "m5.large", "250", func(opts *options.Options) {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 250}
+						opts.AWSENILimitedPodDensity = false
+					}),
+					Entry("by per instance type pod density over ENI limits", "m5.large", "8", func(*options.Options) {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 8}
+					}),
+					Entry("by ENIs for instance types without a per instance type pod density", "m5.xlarge", "238", func(*options.Options) {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 8}
+					}),
+					Entry("by ENIs excluding the primary ENI with custom networking", "m5.large", "60", func(*options.Options) {
+						provider.CustomNetworking = aws.Bool(true)
+					}),
+					Entry("by ENIs excluding the primary ENI of larger instance types with custom networking", "m5.xlarge", "179", func(*options.Options) {
+						provider.CustomNetworking = aws.Bool(true)
+					}),
+					Entry("by the cluster wide pod density with custom networking", "m5.large", "110", func(opts *options.Options) {
+						provider.CustomNetworking = aws.Bool(true)
+						opts.AWSENILimitedPodDensity = false
+					}),
+					// 2 vCPUs use the kernel's minimum pid_max of 32768
+					Entry("by the PID budget on small instance types", "m5.large", "32", func(*options.Options) {
+						provider.PodPIDsLimit = aws.Int64(1024)
+					}),
+					// 96 vCPUs scale pid_max to 1024 per CPU
+					Entry("by the PID budget scaled with vCPUs", "m5.metal", "96", func(*options.Options) {
+						provider.PodPIDsLimit = aws.Int64(1024)
+					}),
+					Entry("by ENIs below the PID budget", "m5.large", "89", func(*options.Options) {
+						provider.PodPIDsLimit = aws.Int64(100)
+					}),
+					Entry("by the PID budget below per instance type pod density", "m5.large", "327", func(*options.Options) {
+						provider.PodPIDsLimit = aws.Int64(100)
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 500}
+					}),
+					Entry("by per instance type pod density without a PID budget", "m5.large", "500", func(*options.Options) {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 500}
+					}),
+					Entry("by the max pods table over the formula", "m5.large", "29", func(*options.Options) {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.large": 29}
+					}),
+					Entry("by the formula for instance types missing from the max pods table", "m5.xlarge", "238", func(*options.Options) {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.large": 29}
+					}),
+					Entry("by the max pods table excluding the primary ENI with custom networking", "m5.xlarge", "175", func(*options.Options) {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.xlarge": 234}
+						provider.CustomNetworking = aws.Bool(true)
+					}),
+					Entry("by per instance type pod density over the max pods table", "m5.large", "8", func(*options.Options) {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.large": 29}
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 8}
+					}),
+					Entry("by the IPv6 cap on small instance types", "m5.large", "110", func(*options.Options) {
+						provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6)
+					}),
+					Entry("by the IPv6 cap on instance types with at least 30 vCPUs", "m5.metal", "250", func(*options.Options) {
+						provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6)
+					}),
+					Entry("by the IPv6 cap rather than the max pods table", "m5.large", "110", func(*options.Options) {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.large": 29}
+						provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6)
+					}),
+				)
+				DescribeTable("should launch another node once the pods of the instance type are scheduled",
+					func(configure func()) {
+						configure()
+						ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+						var pods []*v1.Pod
+						for i := 0; i < 5; i++ {
+							pods = append(pods, test.UnschedulablePod(test.PodOptions{
+								NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+							}))
+						}
+						nodes := sets.NewString()
+						for _, pod := range ExpectProvisioned(ctx, env.Client, controller, pods...) {
+							nodes.Insert(ExpectScheduled(ctx, env.Client, pod).Name)
+						}
+						Expect(nodes.Len()).To(Equal(2))
+					},
+					Entry("with max pods per instance type", func() {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 4}
+					}),
+					Entry("with instance type overrides", func() {
+						provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {Pods: aws.Int32(4)}}
+					}),
+					// 32768 pid_max fits the PIDs of 4 pods
+					Entry("with a pod PIDs limit", func() { provider.PodPIDsLimit = aws.Int64(8192) }),
+				)
+				It("should reserve less memory for kubelet with custom networking", func() {
+					memory := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+					provider.CustomNetworking = aws.Bool(true)
+					customNetworkingMemory := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+					Expect(customNetworkingMemory.Cmp(memory)).To(Equal(-1))
+				})
+				It("should limit IPv6 pod density by prefixes on instance types with few addresses", func() {
+					info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					info.InstanceType = aws.String("t3.nano")
+					networkInfo := *info.NetworkInfo
+					networkInfo.MaximumNetworkInterfaces = aws.Int64(2)
+					networkInfo.Ipv4AddressesPerInterface = aws.Int64(2)
+					info.NetworkInfo = &networkInfo
+					Expect(NewTestInstanceType(provider, &info).Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("4")))
+					provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6)
+					Expect(NewTestInstanceType(provider, &info).Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("34")))
+				})
+				Context("Overflow", func() {
+					var info ec2.InstanceTypeInfo
+					BeforeEach(func() {
+						info = *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+						info.InstanceType = aws.String("m5.overflow")
+					})
+					podsOf := func(instanceType *InstanceType) int64 {
+						pods := instanceType.Resources()[v1.ResourcePods]
+						return pods.Value()
 					}
-					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
-						UserData:   aws.String("#/bin/bash\n ./not-toml.sh"),
-						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
-					ExpectApplied(ctx, env.Client, nodeTemplate)
-					controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
-					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
-					ExpectApplied(ctx, env.Client, newProvisioner)
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					// This will not be scheduled since userData cannot be generated for the prospective node.
-					ExpectNotScheduled(ctx, env.Client, pod)
+					withENIs := func(enis int64, addresses int64) *ec2.InstanceTypeInfo {
+						networkInfo := *info.NetworkInfo
+						networkInfo.MaximumNetworkInterfaces = aws.Int64(enis)
+						networkInfo.Ipv4AddressesPerInterface = aws.Int64(addresses)
+						info.NetworkInfo = &networkInfo
+						return &info
+					}
+					It("should compute pod density up to the int32 max", func() {
+						instanceType := NewTestInstanceType(provider, withENIs(1, math.MaxInt32-1))
+						Expect(podsOf(instanceType)).To(Equal(int64(math.MaxInt32)))
+						instanceType = NewTestInstanceType(provider, withENIs(2, math.MaxInt32/2))
+						Expect(podsOf(instanceType)).To(Equal(int64(math.MaxInt32 - 1)))
+					})
+					It("should clamp pod density past the int32 max", func() {
+						for _, enis := range [][]int64{{1, math.MaxInt32}, {2, math.MaxInt32/2 + 1}, {math.MaxInt32, math.MaxInt32}, {math.MaxInt64, math.MaxInt64}} {
+							instanceType := NewTestInstanceType(provider, withENIs(enis[0], enis[1]))
+							Expect(instanceType.eniLimitedPods()).To(Equal(MaxPodsLimit), fmt.Sprint(enis))
+							Expect(podsOf(instanceType)).To(Equal(MaxPodsLimit), fmt.Sprint(enis))
+						}
+					})
+					It("should not overflow prefix delegated pod density", func() {
+						provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6)
+						Expect(podsOf(NewTestInstanceType(provider, withENIs(math.MaxInt32, math.MaxInt32)))).To(Equal(int64(110)))
+					})
+					It("should clamp pod density from the max pods source", func() {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.overflow": math.MaxInt32 + 1}
+						Expect(podsOf(NewTestInstanceType(provider, &info))).To(Equal(MaxPodsLimit))
+					})
+					It("should not compute negative pod density", func() {
+						ENILimitedMaxPodsSource = MaxPodsTable{"m5.large": 29}
+						provider.CustomNetworking = aws.Bool(true)
+						info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+						networkInfo := *info.NetworkInfo
+						networkInfo.Ipv4AddressesPerInterface = aws.Int64(64)
+						info.NetworkInfo = &networkInfo
+						Expect(podsOf(NewTestInstanceType(provider, &info))).To(BeZero())
+					})
+				})
+				Context("Max Pods Source", func() {
+					It("should parse eni-max-pods.txt", func() {
+						table, err := ParseMaxPodsTable(strings.NewReader("# Mapping is calculated from AWS EC2 API\n\nm5.large 29\nm5.xlarge\t58\n"))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(table).To(Equal(MaxPodsTable{"m5.large": 29, "m5.xlarge": 58}))
+					})
+					It("should fail to parse malformed tables", func() {
+						_, err := ParseMaxPodsTable(strings.NewReader("m5.large\n"))
+						Expect(err).To(HaveOccurred())
+						_, err = ParseMaxPodsTable(strings.NewReader("m5.large many\n"))
+						Expect(err).To(HaveOccurred())
+					})
 				})
 			})
-			Context("Kubelet Args", func() {
-				It("should specify the --dns-cluster-ip flag when clusterDNSIP is set", func() {
-					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{
-						Kubelet:  &v1alpha5.KubeletConfiguration{ClusterDNS: []string{"10.0.10.100"}},
-						Provider: provider,
-					}))
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					ExpectScheduled(ctx, env.Client, pod)
-					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-					Expect(string(userData)).To(ContainSubstring("--dns-cluster-ip '10.0.10.100'"))
+			Context("Trunk ENI", func() {
+				DescribeTable("should limit pods by the trunk ENI",
+					func(pods string, configure func(*options.Options)) {
+						opts := opts
+						configure(&opts)
+						zones := sets.NewString("test-zone-1a")
+						instanceType := ExpectNewInstanceType(injection.WithOptions(ctx, opts), ExpectInstanceType(provider, "m5.large").InstanceTypeInfo, provider, zones, zones, nil)
+						Expect(instanceType.Resources()[v1.ResourcePods]).To(Equal(resource.MustParse(pods)))
+					},
+					// 3 ENIs with 30 addresses, one of which is taken by the trunk ENI
+					Entry("on trunking compatible instance types", "60", func(*options.Options) {}),
+					Entry("unless pod ENI is disabled", "89", func(opts *options.Options) { opts.AWSEnablePodENI = false }),
+					Entry("unless per instance type pod density is set", "100", func(*options.Options) {
+						provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 100}
+					}),
+				)
+				It("should only reserve pods on instance types that are trunking compatible", func() {
+					Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1alpha1.ResourceAWSPodENI]).ToNot(Equal(resource.MustParse("0")))
+					instanceType := ExpectInstanceType(provider, "t3.large")
+					Expect(instanceType.Resources()[v1alpha1.ResourceAWSPodENI]).To(Equal(resource.MustParse("0")))
+					pods, _ := FormulaMaxPodsSource{}.MaxPods(instanceType)
+					resources := instanceType.Resources()
+					Expect(resources.Pods().Value()).To(Equal(pods))
 				})
 			})
-			Context("Instance Profile", func() {
-				It("should use the default instance profile if none specified on the Provisioner", func() {
-					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					ExpectScheduled(ctx, env.Client, pod)
-					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-					Expect(*input.LaunchTemplateData.IamInstanceProfile.Name).To(Equal("test-instance-profile"))
+			Context("Instance Type Overrides", func() {
+				It("should replace the computed overhead and pods of overridden instance types", func() {
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {
+						CPU:              resource.NewMilliQuantity(250, resource.DecimalSI),
+						Memory:           resource.NewQuantity(1<<30, resource.BinarySI),
+						EphemeralStorage: resource.NewScaledQuantity(2, resource.Giga),
+						Pods:             aws.Int32(20),
+					}}
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("20")))
+					overhead := instanceType.Overhead()
+					Expect(overhead.Cpu().Cmp(resource.MustParse("250m"))).To(Equal(0))
+					Expect(overhead.Memory().Cmp(resource.MustParse("1Gi"))).To(Equal(0))
+					Expect(overhead.StorageEphemeral().Cmp(resource.MustParse("2G"))).To(Equal(0))
 				})
-				It("should use the instance profile on the Provisioner when specified", func() {
-					provider.InstanceProfile = aws.String("overridden-profile")
-					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-					ExpectScheduled(ctx, env.Client, pod)
-					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-					Expect(*input.LaunchTemplateData.IamInstanceProfile.Name).To(Equal("overridden-profile"))
+				It("should only replace the values that are overridden", func() {
+					computed := ExpectInstanceType(provider, "m5.large").Overhead()
+					computedPods := ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourcePods]
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {Memory: resource.NewQuantity(1<<30, resource.BinarySI)}}
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Resources()[v1.ResourcePods]).To(Equal(computedPods))
+					overhead := instanceType.Overhead()
+					Expect(overhead.Memory().Cmp(resource.MustParse("1Gi"))).To(Equal(0))
+					Expect(overhead.Cpu().Cmp(*computed.Cpu())).To(Equal(0))
+					Expect(overhead.StorageEphemeral().Cmp(*computed.StorageEphemeral())).To(Equal(0))
+				})
+				It("should not change instance types that aren't overridden", func() {
+					computed := ExpectInstanceType(provider, "m5.xlarge")
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {
+						CPU:  resource.NewMilliQuantity(250, resource.DecimalSI),
+						Pods: aws.Int32(20),
+					}}
+					instanceType := ExpectInstanceType(provider, "m5.xlarge")
+					Expect(instanceType.Resources()[v1.ResourcePods]).To(Equal(computed.Resources()[v1.ResourcePods]))
+					Expect(instanceType.Overhead()).To(Equal(computed.Overhead()))
+				})
+				It("should prefer instance type overrides over the per instance type pod density", func() {
+					provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 50}
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {Pods: aws.Int32(20)}}
+					Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("20")))
 				})
 			})
-		})
-		Context("Metadata Options", func() {
-			It("should default metadata options on generated launch template", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpEndpoint).To(Equal(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpProtocolIpv6).To(Equal(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Disabled))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpPutResponseHopLimit).To(Equal(int64(2)))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateRequired))
+			Context("Instance Types For Resources", func() {
+				var instanceTypes []*InstanceType
+				BeforeEach(func() {
+					instanceTypes = lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) *InstanceType {
+						return instanceType.(*InstanceType)
+					})
+				})
+				It("should filter by cpu", func() {
+					result := InstanceTypesForResources(instanceTypes, v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")})
+					Expect(InstanceTypeNames(result)).To(ContainElements("m5.xlarge", "p3.8xlarge"))
+					Expect(InstanceTypeNames(result)).ToNot(ContainElements("t3.large", "m5.large", "c6g.large"))
+					for _, instanceType := range result {
+						cpu := instanceType.Resources()[v1.ResourceCPU]
+						Expect(cpu.Cmp(resource.MustParse("4"))).To(BeNumerically(">=", 0))
+					}
+				})
+				It("should filter by memory", func() {
+					result := InstanceTypesForResources(instanceTypes, v1.ResourceList{v1.ResourceMemory: resource.MustParse("12Gi")})
+					Expect(InstanceTypeNames(result)).To(ContainElements("m5.xlarge", "p3.8xlarge"))
+					Expect(InstanceTypeNames(result)).ToNot(ContainElements("t3.large", "m5.large", "c6g.large"))
+				})
+				It("should filter by gpu", func() {
+					result := InstanceTypesForResources(instanceTypes, v1.ResourceList{
+						v1.ResourceCPU:             resource.MustParse("4"),
+						v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
+					})
+					Expect(InstanceTypeNames(result)).To(ConsistOf("p3.8xlarge"))
+				})
+				It("should not return instance types when no instance type covers the request", func() {
+					Expect(InstanceTypesForResources(instanceTypes, v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("5")})).To(BeEmpty())
+				})
+				It("should return all instance types for an empty request", func() {
+					Expect(InstanceTypesForResources(instanceTypes, v1.ResourceList{})).To(HaveLen(len(instanceTypes)))
+				})
 			})
-			It("should set metadata options on generated launch template from provisioner configuration", func() {
-				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
-				Expect(err).ToNot(HaveOccurred())
-				provider.MetadataOptions = &v1alpha1.MetadataOptions{
-					HTTPEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateDisabled),
-					HTTPProtocolIPv6:        aws.String(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled),
-					HTTPPutResponseHopLimit: aws.Int64(1),
-					HTTPTokens:              aws.String(ec2.LaunchTemplateHttpTokensStateOptional),
+			Context("Sort By Efficiency", func() {
+				var instanceTypes []*InstanceType
+				BeforeEach(func() {
+					instanceTypes = lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) *InstanceType {
+						return instanceType.(*InstanceType)
+					})
+				})
+				It("should order the tightest fit first", func() {
+					SortByEfficiency(instanceTypes, v1.ResourceList{v1.ResourceCPU: resource.MustParse("1500m"), v1.ResourceMemory: resource.MustParse("3Gi")})
+					Expect(InstanceTypeNames(instanceTypes)[0]).To(Equal("c6g.large"))
+					Expect(InstanceTypeNames(instanceTypes)[len(instanceTypes)-1]).To(Equal("m5.metal"))
+				})
+				It("should order by increasing waste", func() {
+					request := v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("4Gi")}
+					SortByEfficiency(instanceTypes, request)
+					for i := 1; i < len(instanceTypes); i++ {
+						Expect(instanceTypes[i-1].waste(request)).To(BeNumerically("<=", instanceTypes[i].waste(request)))
+					}
+				})
+				It("should order instance types that can't fit the request last", func() {
+					SortByEfficiency(instanceTypes, v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")})
+					Expect(InstanceTypeNames(instanceTypes)[0]).To(Equal("m5.xlarge"))
+					Expect(InstanceTypeNames(instanceTypes)[len(instanceTypes)-3:]).To(ConsistOf("c6g.large", "m5.large", "t3.large"))
+				})
+				It("should break ties by name", func() {
+					SortByEfficiency(instanceTypes, v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")})
+					Expect(InstanceTypeNames(instanceTypes)[:3]).To(Equal([]string{"c6g.large", "m5.large", "t3.large"}))
+				})
+			})
+			It("should price GPUs by model", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(8)}
+				gpuInstanceType := func(name string, gpu string) *InstanceType {
+					info := info
+					info.InstanceType = aws.String(name)
+					info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+						Name: aws.String(gpu), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(1),
+						MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+					}}}
+					return NewTestInstanceType(provider, &info)
 				}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpEndpoint).To(Equal(ec2.LaunchTemplateInstanceMetadataEndpointStateDisabled))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpProtocolIpv6).To(Equal(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpPutResponseHopLimit).To(Equal(int64(1)))
-				Expect(*input.LaunchTemplateData.MetadataOptions.HttpTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateOptional))
+				a100, t4 := gpuInstanceType("p4d.2xlarge", "A100"), gpuInstanceType("g4dn.2xlarge", "T4")
+				Expect(a100.Price()).To(BeNumerically(">", t4.Price()))
+				Expect(a100.Price() - t4.Price()).To(BeNumerically("~", GPUCostWeights["a100"]-GPUCostWeights["t4"]))
 			})
-		})
-		Context("Block Device Mappings", func() {
-			It("should default AL2 block device mappings", func() {
-				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-				provider.AMIFamily = &v1alpha1.AMIFamilyAL2
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("gp3"))
-				Expect(input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(BeNil())
+			It("should fall back to a flat weight for unknown GPU models", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				price := NewTestInstanceType(provider, &info).Price()
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+					Name: aws.String("Unknown"), Manufacturer: aws.String("NVIDIA"), Count: aws.Int64(2),
+					MemoryInfo: &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+				}}}
+				Expect(NewTestInstanceType(provider, &info).Price()).To(BeNumerically("~", price+10))
 			})
-			It("should use custom block device mapping", func() {
-				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-				provider.AMIFamily = &v1alpha1.AMIFamilyAL2
-				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
-					{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							DeleteOnTermination: aws.Bool(true),
-							Encrypted:           aws.Bool(true),
-							VolumeType:          aws.String("io2"),
-							VolumeSize:          resource.NewScaledQuantity(40, resource.Giga),
-							IOPS:                aws.Int64(10_000),
-							KMSKeyID:            aws.String("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"),
+			It("should report the price of each offering", func() {
+				provider.SpotDiscountPercentage = aws.Int64(20)
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(testutil.ToFloat64(offeringPriceGauge.WithLabelValues("m5.large", "test-zone-1a", v1alpha1.CapacityTypeOnDemand))).To(BeNumerically("~", instanceType.Price()))
+				Expect(testutil.ToFloat64(offeringPriceGauge.WithLabelValues("m5.large", "test-zone-1b", v1alpha1.CapacityTypeSpot))).To(BeNumerically("~", instanceType.Price()*0.8))
+			})
+			Context("Cheapest Offering", func() {
+				It("should prefer the first of equally priced offerings", func() {
+					offering, ok := ExpectInstanceType(provider, "m5.large").CheapestOffering()
+					Expect(ok).To(BeTrue())
+					Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand}))
+				})
+				It("should prefer discounted spot offerings over on-demand offerings", func() {
+					provider.SpotDiscountPercentage = aws.Int64(20)
+					offering, ok := ExpectInstanceType(provider, "m5.large").CheapestOffering()
+					Expect(ok).To(BeTrue())
+					Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot}))
+				})
+				It("should skip offerings that became unavailable", func() {
+					provider.SpotDiscountPercentage = aws.Int64(20)
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					cloudProvider.(*CloudProvider).instanceTypeProvider.CacheUnavailable(ctx, &ec2.CreateFleetError{
+						ErrorCode: aws.String("InsufficientInstanceCapacity"),
+						LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+							Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
 						},
-					},
+					}, v1alpha1.CapacityTypeSpot)
+					offering, ok := instanceType.CheapestOffering()
+					Expect(ok).To(BeTrue())
+					Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeSpot}))
+				})
+				It("should prefer capacity reservations", func() {
+					provider.SpotDiscountPercentage = aws.Int64(20)
+					info := ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					zones := sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+					instanceType := ExpectNewInstanceType(ctx, info, provider, zones, zones, []*ec2.CapacityReservation{{
+						CapacityReservationId: aws.String("cr-1234"),
+						InstanceType:          aws.String("m5.large"),
+						AvailabilityZone:      aws.String("test-zone-1c"),
+					}})
+					offering, ok := instanceType.CheapestOffering()
+					Expect(ok).To(BeTrue())
+					Expect(offering.CapacityReservationID).To(Equal("cr-1234"))
+				})
+				It("should not find an offering if none are available", func() {
+					info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					zones := sets.NewString("test-zone-1a")
+					instanceType := ExpectNewInstanceType(ctx, &info, provider, zones, sets.NewString(), nil)
+					_, ok := instanceType.CheapestOffering()
+					Expect(ok).To(BeFalse())
+				})
+			})
+			Context("Region", func() {
+				var zones []string
+				regionalInstanceTypeProvider := func(region string) *InstanceTypeProvider {
+					instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+					return NewInstanceTypeProvider(fakeEC2API, region, instanceTypeProvider.subnetProvider, instanceTypeProvider.capacityReservationProvider, instanceTypeProvider.priceProvider)
 				}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(40)))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("io2"))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(Equal(int64(10_000)))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.DeleteOnTermination).To(BeTrue())
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Encrypted).To(BeTrue())
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.KmsKeyId).To(Equal("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"))
+				BeforeEach(func() {
+					zones = []string{"us-west-2a", "us-west-2b", "us-west-2-lax-1a", "us-east-1a"}
+					fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: lo.Map(zones, func(zone string, i int) *ec2.Subnet {
+						return &ec2.Subnet{SubnetId: aws.String(fmt.Sprintf("subnet-test%d", i)), AvailabilityZone: aws.String(zone)}
+					})}
+					fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: lo.Map(zones, func(zone string, _ int) *ec2.InstanceTypeOffering {
+						return &ec2.InstanceTypeOffering{InstanceType: aws.String("m5.large"), Location: aws.String(zone)}
+					})}
+				})
+				It("should scope offerings to the zones of the region", func() {
+					for region, expected := range map[string][]string{
+						"us-west-2": {"us-west-2a", "us-west-2b", "us-west-2-lax-1a"},
+						"us-east-1": {"us-east-1a"},
+					} {
+						instanceTypes, err := regionalInstanceTypeProvider(region).Get(ctx, provider)
+						Expect(err).ToNot(HaveOccurred())
+						instanceType, ok := lo.Find(instanceTypes, func(instanceType cloudprovider.InstanceType) bool { return instanceType.Name() == "m5.large" })
+						Expect(ok).To(BeTrue())
+						Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf(expected))
+					}
+				})
+				It("should not scope offerings without a region", func() {
+					instanceTypes, err := regionalInstanceTypeProvider("").Get(ctx, provider)
+					Expect(err).ToNot(HaveOccurred())
+					instanceType, ok := lo.Find(instanceTypes, func(instanceType cloudprovider.InstanceType) bool { return instanceType.Name() == "m5.large" })
+					Expect(ok).To(BeTrue())
+					Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf(zones))
+				})
 			})
-			It("should default bottlerocket second volume with root volume size", func() {
-				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
-				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
-				ExpectScheduled(ctx, env.Client, pod)
-				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
-				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
-				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(2))
-				// Bottlerocket control volume
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(4)))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("gp3"))
-				Expect(input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(BeNil())
-				// Bottlerocket user volume
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(20)))
-				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeType).To(Equal("gp3"))
-				Expect(input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.Iops).To(BeNil())
+			Context("Zone Types", func() {
+				BeforeEach(func() {
+					zones := []string{"us-west-2a", "us-west-2-lax-1a", "us-west-2-wl1-sfo-wlz-1"}
+					fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: lo.Map(zones, func(zone string, i int) *ec2.Subnet {
+						return &ec2.Subnet{SubnetId: aws.String(fmt.Sprintf("subnet-test%d", i)), AvailabilityZone: aws.String(zone)}
+					})}
+					fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: lo.Map(zones, func(zone string, _ int) *ec2.InstanceTypeOffering {
+						return &ec2.InstanceTypeOffering{InstanceType: aws.String("m5.large"), Location: aws.String(zone)}
+					})}
+				})
+				It("should classify zones by their names", func() {
+					for zone, expected := range map[string]string{
+						"us-west-2a":              v1alpha1.ZoneTypeAvailabilityZone,
+						"us-gov-west-1b":          v1alpha1.ZoneTypeAvailabilityZone,
+						"test-zone-1a":            v1alpha1.ZoneTypeAvailabilityZone,
+						"us-west-2-lax-1a":        v1alpha1.ZoneTypeLocalZone,
+						"us-east-1-bos-1a":        v1alpha1.ZoneTypeLocalZone,
+						"us-west-2-wl1-sfo-wlz-1": v1alpha1.ZoneTypeWavelengthZone,
+						"us-east-1-wl1-bos-wlz-1": v1alpha1.ZoneTypeWavelengthZone,
+					} {
+						Expect(zoneType(zone)).To(Equal(expected), zone)
+					}
+				})
+				It("should label the zone types that the instance type is offered in", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf("us-west-2a", "us-west-2-lax-1a", "us-west-2-wl1-sfo-wlz-1"))
+					Expect(instanceType.Requirements().Get(v1alpha1.InstanceZoneTypeLabelKey).Values().List()).To(ConsistOf(
+						v1alpha1.ZoneTypeAvailabilityZone, v1alpha1.ZoneTypeLocalZone, v1alpha1.ZoneTypeWavelengthZone,
+					))
+				})
+				It("should only offer instance types in the provider's zone types", func() {
+					for zoneType, expected := range map[string]string{
+						v1alpha1.ZoneTypeAvailabilityZone: "us-west-2a",
+						v1alpha1.ZoneTypeLocalZone:        "us-west-2-lax-1a",
+						v1alpha1.ZoneTypeWavelengthZone:   "us-west-2-wl1-sfo-wlz-1",
+					} {
+						provider.ZoneTypes = []string{zoneType}
+						instanceType := ExpectInstanceType(provider, "m5.large")
+						Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf(expected))
+						Expect(instanceType.Requirements().Get(v1alpha1.InstanceZoneTypeLabelKey).Values().List()).To(ConsistOf(zoneType))
+					}
+				})
+				It("should only launch in zones of the required zone type", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1alpha1.InstanceZoneTypeLabelKey: v1alpha1.ZoneTypeLocalZone},
+					}))[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					for _, launchTemplateConfig := range input.LaunchTemplateConfigs {
+						for _, override := range launchTemplateConfig.Overrides {
+							Expect(aws.StringValue(override.AvailabilityZone)).To(Equal("us-west-2-lax-1a"))
+						}
+					}
+				})
 			})
-		})
-		Context("Ephemeral Storage", func() {
-			It("should pack pods when a daemonset has an ephemeral-storage request", func() {
-				ExpectApplied(ctx, env.Client, provisioner, test.DaemonSet(
-					test.DaemonSetOptions{PodOptions: test.PodOptions{
-						ResourceRequirements: v1.ResourceRequirements{
-							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"),
-								v1.ResourceMemory:           resource.MustParse("1Gi"),
-								v1.ResourceEphemeralStorage: resource.MustParse("1Gi")}},
-					}},
-				))
-				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())
-				ExpectScheduled(ctx, env.Client, pod[0])
+			Context("Batch Construction", func() {
+				It("should construct instance types in the order of their infos", func() {
+					infos := lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) *ec2.InstanceTypeInfo {
+						return instanceType.(*InstanceType).InstanceTypeInfo
+					})
+					infos = append(infos[len(infos)/2:], infos[:len(infos)/2]...)
+					zones := sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+					instanceTypeZones := map[string]sets.String{}
+					for _, info := range infos {
+						instanceTypeZones[aws.StringValue(info.InstanceType)] = zones
+					}
+					instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+					instanceTypes, err := instanceTypeProvider.NewInstanceTypes(ctx, infos, provider, instanceTypeZones, zones, nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instanceTypes).To(HaveLen(len(infos)))
+					for i, instanceType := range instanceTypes {
+						expected := ExpectNewInstanceType(ctx, infos[i], provider, zones, zones, nil)
+						Expect(instanceType.Name()).To(Equal(expected.Name()))
+						Expect(ExpectHash(instanceType)).To(Equal(ExpectHash(expected)))
+						Expect(instanceType.Offerings()).To(Equal(expected.Offerings()))
+						Expect(instanceType.Requirements()).To(Equal(expected.Requirements()))
+					}
+				})
+				It("should omit instance types once the context is cancelled", func() {
+					infos := lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) *ec2.InstanceTypeInfo {
+						return instanceType.(*InstanceType).InstanceTypeInfo
+					})
+					cancelled, cancel := context.WithCancel(ctx)
+					cancel()
+					instanceTypes, err := cloudProvider.(*CloudProvider).instanceTypeProvider.NewInstanceTypes(cancelled, infos, provider, nil, nil, nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instanceTypes).To(BeEmpty())
+				})
 			})
-			It("should pack pods with any ephemeral-storage request", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("1G"),
-						}}}))
-				ExpectScheduled(ctx, env.Client, pod[0])
+			Context("Preload", func() {
+				var instanceTypeProvider *InstanceTypeProvider
+				// Providers are preloaded as deserialized from provisioners, as they're resolved when getting instance types
+				var deserialized []*v1alpha1.AWS
+				BeforeEach(func() {
+					instanceTypeProvider = cloudProvider.(*CloudProvider).instanceTypeProvider
+					awsprovider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					deserialized = []*v1alpha1.AWS{awsprovider}
+					instanceTypeProvider.computed = cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval)
+					instanceTypeProvider.preloaded = nil
+				})
+				AfterEach(func() {
+					instanceTypeProvider.computed = nil
+					instanceTypeProvider.preloaded = nil
+				})
+				It("should signal once instance types are preloaded", func() {
+					Expect(cloudProvider.(*CloudProvider).Preloaded()).ToNot(BeClosed())
+					Expect(instanceTypeProvider.Preload(ctx, deserialized)).To(Succeed())
+					Expect(cloudProvider.(*CloudProvider).Preloaded()).To(BeClosed())
+				})
+				It("should not be ready until instance types are preloaded", func() {
+					ready := controllers.Preloaded(cloudProvider.(*CloudProvider))
+					Expect(ready(nil)).ToNot(Succeed())
+					Expect(instanceTypeProvider.Preload(ctx, deserialized)).To(Succeed())
+					Expect(ready(nil)).To(Succeed())
+				})
+				It("should be ready right away if instance types aren't preloaded", func() {
+					preload(ctx, cloudprovider.Options{}, instanceTypeProvider)
+					Expect(controllers.Preloaded(cloudProvider.(*CloudProvider))(nil)).To(Succeed())
+					Expect(instanceTypeProvider.computed.ItemCount()).To(BeZero())
+				})
+				It("should signal once instance types are preloaded even if a provider fails", func() {
+					fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{}
+					Expect(instanceTypeProvider.Preload(ctx, []*v1alpha1.AWS{provider})).ToNot(Succeed())
+					Expect(instanceTypeProvider.Preloaded()).To(BeClosed())
+				})
+				It("should use the preloaded resources, overhead, and requirements", func() {
+					Expect(instanceTypeProvider.Preload(ctx, deserialized)).To(Succeed())
+					Expect(instanceTypeProvider.computed.ItemCount()).ToNot(BeZero())
+					preloaded := instanceTypeProvider.computed.ItemCount()
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceTypeProvider.computed.ItemCount()).To(Equal(preloaded))
+					other := ExpectInstanceType(provider, "m5.large")
+					Expect(reflect.ValueOf(other.Resources()).Pointer()).To(Equal(reflect.ValueOf(instanceType.Resources()).Pointer()))
+					Expect(reflect.ValueOf(other.Overhead()).Pointer()).To(Equal(reflect.ValueOf(instanceType.Overhead()).Pointer()))
+					Expect(reflect.ValueOf(other.Requirements()).Pointer()).To(Equal(reflect.ValueOf(instanceType.Requirements()).Pointer()))
+				})
+				It("should compute the same values as without preloading", func() {
+					Expect(instanceTypeProvider.Preload(ctx, deserialized)).To(Succeed())
+					preloaded := ExpectInstanceType(provider, "m5.large")
+					instanceTypeProvider.computed = nil
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(preloaded.Resources()).To(Equal(instanceType.Resources()))
+					Expect(preloaded.Overhead()).To(Equal(instanceType.Overhead()))
+					Expect(preloaded.Requirements()).To(Equal(instanceType.Requirements()))
+				})
+				It("should recompute for providers that weren't preloaded", func() {
+					Expect(instanceTypeProvider.Preload(ctx, deserialized)).To(Succeed())
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					other := provider.DeepCopy()
+					other.ExcludedZones = []string{"test-zone-1a"}
+					excluded := ExpectInstanceType(other, "m5.large")
+					Expect(reflect.ValueOf(excluded.Requirements()).Pointer()).ToNot(Equal(reflect.ValueOf(instanceType.Requirements()).Pointer()))
+					Expect(excluded.Requirements().Get(v1.LabelTopologyZone).Has("test-zone-1a")).To(BeFalse())
+				})
 			})
-			It("should pack pods with large ephemeral-storage request", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
-						}}}))
-				ExpectScheduled(ctx, env.Client, pod[0])
+			Context("Hash", func() {
+				It("should hash the same instance type and provider consistently", func() {
+					hash := ExpectHash(ExpectInstanceType(provider, "m5.large"))
+					Expect(ExpectHash(ExpectInstanceType(provider, "m5.large"))).To(Equal(hash))
+					Expect(ExpectHash(ExpectInstanceType(provider.DeepCopy(), "m5.large"))).To(Equal(hash))
+				})
+				It("should hash instance types differently", func() {
+					Expect(ExpectHash(ExpectInstanceType(provider, "m5.large"))).ToNot(Equal(ExpectHash(ExpectInstanceType(provider, "m5.xlarge"))))
+				})
+				It("should hash instance types that only differ by AMI family differently", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					other := provider.DeepCopy()
+					other.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+					Expect(ExpectHash(instanceType.WithProvider(other))).ToNot(Equal(ExpectHash(instanceType)))
+				})
+				It("should hash instance types that only differ by a quantity differently", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					swap, other := provider.DeepCopy(), provider.DeepCopy()
+					swap.Swap, other.Swap = resource.NewQuantity(1<<30, resource.BinarySI), resource.NewQuantity(2<<30, resource.BinarySI)
+					Expect(ExpectHash(instanceType.WithProvider(swap))).ToNot(Equal(ExpectHash(instanceType.WithProvider(other))))
+				})
+				It("should hash instance types computed with different options differently", func() {
+					opts := opts
+					opts.AWSEnablePodENI = false
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					zones := sets.NewString("test-zone-1a")
+					withoutPodENI := ExpectNewInstanceType(injection.WithOptions(ctx, opts), instanceType.InstanceTypeInfo, provider, zones, zones, nil)
+					Expect(ExpectHash(withoutPodENI)).ToNot(Equal(ExpectHash(instanceType)))
+				})
 			})
-			It("should not pack pods if the sum of pod ephemeral-storage and overhead exceeds node capacity", func() {
-				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("19Gi"),
-						}}}))
-				ExpectNotScheduled(ctx, env.Client, pod[0])
+			Context("Offering Requirements", func() {
+				It("should describe a single zone and capacity type per offering", func() {
+					for _, offering := range ExpectInstanceType(provider, "m5.large").Offerings() {
+						requirements := offering.Requirements()
+						Expect(requirements.Get(v1.LabelTopologyZone).Values().List()).To(ConsistOf(offering.Zone))
+						Expect(requirements.Get(v1alpha5.LabelCapacityType).Values().List()).To(ConsistOf(offering.CapacityType))
+					}
+				})
+				It("should keep the instance type requirements as the union of its offerings", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					zones, capacityTypes := sets.NewString(), sets.NewString()
+					for _, offering := range instanceType.Offerings() {
+						requirements := offering.Requirements()
+						zones.Insert(requirements.Get(v1.LabelTopologyZone).Values().List()...)
+						capacityTypes.Insert(requirements.Get(v1alpha5.LabelCapacityType).Values().List()...)
+					}
+					Expect(instanceType.Requirements().Get(v1.LabelTopologyZone).Values()).To(Equal(zones))
+					Expect(instanceType.Requirements().Get(v1alpha5.LabelCapacityType).Values()).To(Equal(capacityTypes))
+				})
+				It("should not match requirements that are only met across offerings", func() {
+					info := ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					cloudProvider.(*CloudProvider).instanceTypeProvider.CacheUnavailable(ctx, &ec2.CreateFleetError{
+						ErrorCode: aws.String("InsufficientInstanceCapacity"),
+						LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+							Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1b")},
+						},
+					}, v1alpha1.CapacityTypeSpot)
+					zones := sets.NewString("test-zone-1a", "test-zone-1b")
+					instanceType := ExpectNewInstanceType(ctx, info, provider, zones, zones, nil)
+					requirements := scheduling.NewLabelRequirements(map[string]string{
+						v1.LabelTopologyZone:       "test-zone-1b",
+						v1alpha5.LabelCapacityType: v1alpha1.CapacityTypeSpot,
+					})
+					Expect(instanceType.Requirements().Compatible(requirements)).To(Succeed())
+					for _, offering := range instanceType.Offerings() {
+						Expect(offering.Requirements().Compatible(requirements)).ToNot(Succeed())
+					}
+				})
 			})
-			It("should launch multiple nodes if sum of pod ephemeral-storage requests exceeds a single nodes capacity", func() {
-				var nodes []*v1.Node
+			It("should score instance types by their capacity", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").ResourceScore()).To(BeNumerically(">", ExpectInstanceType(provider, "t3.large").ResourceScore()))
+				Expect(ExpectInstanceType(provider, "m5.xlarge").ResourceScore()).To(BeNumerically(">", ExpectInstanceType(provider, "m5.large").ResourceScore()))
+			})
+			It("should order equally priced instance types deterministically", func() {
+				t3, m5 := ExpectInstanceType(provider, "t3.large"), ExpectInstanceType(provider, "m5.large")
+				Expect(t3.Price()).To(Equal(m5.Price()))
+				// m5.large supports more pods than t3.large for the same price
+				for i := 0; i < 5; i++ {
+					instanceTypeCache.Flush()
+					names := lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() })
+					Expect(names[:3]).To(Equal([]string{"c6g.large", "m5.large", "t3.large"}))
+				}
+			})
+			It("should break ties between identical instance types by name", func() {
+				info := ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				// Only m5a.large supports pod ENI, which would reserve pods for its trunk ENI
+				opts := opts
+				opts.AWSEnablePodENI = false
+				ctx := injection.WithOptions(ctx, opts)
+				fakeEC2API.DescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{InstanceTypes: lo.Map([]string{"m5b.large", "m5a.large", "m5c.large"}, func(name string, _ int) *ec2.InstanceTypeInfo {
+					copied := *info
+					copied.InstanceType = aws.String(name)
+					return &copied
+				})}
+				fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: lo.Map([]string{"m5b.large", "m5a.large", "m5c.large"}, func(name string, _ int) *ec2.InstanceTypeOffering {
+					return &ec2.InstanceTypeOffering{InstanceType: aws.String(name), Location: aws.String("test-zone-1a")}
+				})}
+				instanceTypeCache.Flush()
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}).Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				names := lo.Map(instanceTypes, func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() })
+				Expect(names).To(Equal([]string{"m5a.large", "m5b.large", "m5c.large"}))
+			})
+			Context("Scorer", func() {
+				It("should score instance types by price by default", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Score()).To(Equal(instanceType.Price()))
+				})
+				It("should order instance types by a custom scorer", func() {
+					cloudProvider.(*CloudProvider).instanceTypeProvider.SetScorer(scorerFunc(func(instanceType *InstanceType, _ cloudprovider.Offering) float64 {
+						if instanceType.Name() == "m5.xlarge" {
+							return -1
+						}
+						return instanceType.Price()
+					}))
+					names := lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() })
+					Expect(names[0]).To(Equal("m5.xlarge"))
+				})
+				It("should score instance types by their best offering", func() {
+					cloudProvider.(*CloudProvider).instanceTypeProvider.SetScorer(scorerFunc(func(instanceType *InstanceType, offering cloudprovider.Offering) float64 {
+						if offering.Zone == "test-zone-1b" && offering.CapacityType == v1alpha1.CapacityTypeSpot {
+							return 1
+						}
+						return 100
+					}))
+					Expect(ExpectInstanceType(provider, "m5.large").Score()).To(Equal(1.0))
+				})
+				It("should launch the instance type preferred by a custom scorer", func() {
+					cloudProvider.(*CloudProvider).instanceTypeProvider.SetScorer(scorerFunc(func(instanceType *InstanceType, _ cloudprovider.Offering) float64 {
+						if instanceType.Name() == "m5.xlarge" {
+							return -1
+						}
+						return instanceType.Price()
+					}))
+					ExpectApplied(ctx, env.Client, provisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.xlarge"))
+				})
+				Context("Scoring Profiles", func() {
+					// The batch profile weights memory and the latency profile weights vCPUs, which rank t3.large and
+					// c6g.large in opposite orders
+					BeforeEach(func() {
+						instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+						instanceTypeProvider.SetProfileScorer("batch", scorerFunc(func(instanceType *InstanceType, _ cloudprovider.Offering) float64 {
+							return instanceType.PricePerGiBMemory()
+						}))
+						instanceTypeProvider.SetProfileScorer("latency", scorerFunc(func(instanceType *InstanceType, _ cloudprovider.Offering) float64 {
+							return instanceType.PricePerVCPU()
+						}))
+					})
+					AfterEach(func() {
+						instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+						instanceTypeProvider.SetProfileScorer("batch", nil)
+						instanceTypeProvider.SetProfileScorer("latency", nil)
+					})
+					ranked := func(provider *v1alpha1.AWS) []string {
+						return lo.Filter(lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() }), func(name string, _ int) bool {
+							return name == "t3.large" || name == "c6g.large"
+						})
+					}
+					It("should rank the same instance types differently for each profile", func() {
+						provider.ScoringProfile = aws.String("batch")
+						Expect(ranked(provider)).To(Equal([]string{"t3.large", "c6g.large"}))
+						provider.ScoringProfile = aws.String("latency")
+						Expect(ranked(provider)).To(Equal([]string{"c6g.large", "t3.large"}))
+					})
+					It("should score instance types with the profile's scorer", func() {
+						provider.ScoringProfile = aws.String("batch")
+						instanceType := ExpectInstanceType(provider, "t3.large")
+						Expect(instanceType.Score()).To(Equal(instanceType.PricePerGiBMemory()))
+					})
+					It("should use the default scorer without a profile or with an unknown profile", func() {
+						instanceType := ExpectInstanceType(provider, "t3.large")
+						Expect(instanceType.Score()).To(Equal(instanceType.Price()))
+						provider.ScoringProfile = aws.String("unknown")
+						instanceType = ExpectInstanceType(provider, "t3.large")
+						Expect(instanceType.Score()).To(Equal(instanceType.Price()))
+					})
+				})
+				Context("Carbon Intensity", func() {
+					BeforeEach(func() {
+						cloudProvider.(*CloudProvider).instanceTypeProvider.SetCarbonIntensitySource(StaticCarbonIntensitySource{
+							"test-zone-1a": 400,
+							"test-zone-1b": 50,
+						})
+					})
+					It("should annotate offerings with the carbon intensity of their zone", func() {
+						for _, offering := range ExpectInstanceType(provider, "m5.large").Offerings() {
+							Expect(offering.CarbonIntensity).To(Equal(map[string]float64{"test-zone-1a": 400, "test-zone-1b": 50, "test-zone-1c": 0}[offering.Zone]))
+						}
+					})
+					It("should not annotate offerings without a carbon intensity source", func() {
+						cloudProvider.(*CloudProvider).instanceTypeProvider.SetCarbonIntensitySource(nil)
+						for _, offering := range ExpectInstanceType(provider, "m5.large").Offerings() {
+							Expect(offering.CarbonIntensity).To(BeZero())
+						}
+					})
+					It("should score offerings in lower carbon zones better", func() {
+						instanceType := ExpectInstanceType(provider, "m5.large")
+						scorer := CarbonScorer{Weight: 0.001}
+						high := scorer.Score(instanceType, cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand, CarbonIntensity: 400})
+						low := scorer.Score(instanceType, cloudprovider.Offering{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand, CarbonIntensity: 50})
+						Expect(low).To(BeNumerically("<", high))
+						Expect(low).To(BeNumerically("~", instanceType.Price()+0.05, 1e-9))
+						Expect(CarbonScorer{}.Score(instanceType, cloudprovider.Offering{CarbonIntensity: 400})).To(Equal(instanceType.Price()))
+					})
+					It("should prefer instance types offered in lower carbon zones", func() {
+						fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+							{InstanceType: aws.String("m5.large"), Location: aws.String("test-zone-1a")},
+							{InstanceType: aws.String("m5.xlarge"), Location: aws.String("test-zone-1b")},
+						}}
+						offered := func() []string {
+							return lo.Filter(lo.Map(ExpectInstanceTypes(provider), func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() }), func(name string, _ int) bool {
+								return name == "m5.large" || name == "m5.xlarge"
+							})
+						}
+						Expect(offered()).To(Equal([]string{"m5.large", "m5.xlarge"}))
+						cloudProvider.(*CloudProvider).instanceTypeProvider.SetScorer(CarbonScorer{Weight: 1})
+						Expect(offered()).To(Equal([]string{"m5.xlarge", "m5.large"}))
+					})
+				})
+			})
+			It("should normalize price by the memory available to pods", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				memory := instanceType.Resources()[v1.ResourceMemory]
+				Expect(instanceType.PricePerGiBMemory()).To(BeNumerically("~", instanceType.Price()/(float64(memory.Value())/(1<<30))))
+			})
+			It("should treat instance types without vCPUs or memory as infinitely expensive", func() {
+				instanceType := &InstanceType{InstanceTypeInfo: &ec2.InstanceTypeInfo{
+					VCpuInfo:   &ec2.VCpuInfo{DefaultVCpus: aws.Int64(0)},
+					MemoryInfo: &ec2.MemoryInfo{SizeInMiB: aws.Int64(0)},
+				}}
+				Expect(math.IsInf(instanceType.PricePerVCPU(), 1)).To(BeTrue())
+				Expect(math.IsInf(instanceType.PricePerGiBMemory(), 1)).To(BeTrue())
+			})
+			It("should normalize price by the number of pods", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				pods := instanceType.Resources()[v1.ResourcePods]
+				Expect(instanceType.PricePerPod()).To(BeNumerically("~", instanceType.Price()/float64(pods.Value())))
+			})
+			It("should treat instance types without pods as infinitely expensive", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				instanceType := &InstanceType{InstanceTypeInfo: &info, resources: v1.ResourceList{v1.ResourcePods: resource.MustParse("0")}}
+				Expect(math.IsInf(instanceType.PricePerPod(), 1)).To(BeTrue())
+				Expect(math.IsInf((&InstanceType{InstanceTypeInfo: &info}).PricePerPod(), 1)).To(BeTrue())
+			})
+			It("should filter instance types by price per pod rather than absolute price", func() {
+				provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 10}
+				cheap, dense := ExpectInstanceType(provider, "m5.large"), ExpectInstanceType(provider, "m5.xlarge")
+				Expect(cheap.Price()).To(BeNumerically("<", dense.Price()))
+				Expect(cheap.PricePerPod()).To(BeNumerically(">", dense.PricePerPod()))
+				provider.MaxPricePerPod = aws.Float64((cheap.PricePerPod() + dense.PricePerPod()) / 2)
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.Has("m5.large")).To(BeFalse())
+				Expect(names.Has("m5.xlarge")).To(BeTrue())
+			})
+			It("should not launch AWS Pod ENI on a t3", func() {
 				ExpectApplied(ctx, env.Client, provisioner)
-				pods := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{
+							v1.LabelInstanceTypeStable: "t3.large",
 						},
-					},
-					}),
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
 						},
-					},
-					}),
-				)
-				for _, pod := range pods {
-					nodes = append(nodes, ExpectScheduled(ctx, env.Client, pod))
+					})) {
+					ExpectNotScheduled(ctx, env.Client, pod)
 				}
-				Expect(nodes).To(HaveLen(2))
 			})
-			It("should only pack pods with ephemeral-storage requests that will fit on an available node", func() {
+			It("should launch on metal", func() {
 				ExpectApplied(ctx, env.Client, provisioner)
-				pods := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{
+							v1.LabelInstanceTypeStable: "m5.metal",
 						},
-					},
-					}),
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("150Gi"),
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
 						},
-					},
-					}),
-				)
-				ExpectScheduled(ctx, env.Client, pods[0])
-				ExpectNotScheduled(ctx, env.Client, pods[1])
+					})) {
+					ExpectScheduled(ctx, env.Client, pod)
+				}
 			})
-			It("should not pack pod if no available instance types have enough storage", func() {
+			It("should fail to launch AWS Pod ENI if the command line option enabling it isn't set", func() {
+				instanceTypeCache.Flush()
+				// ensure the pod ENI option is off
+				optsCopy := opts
+				optsCopy.AWSEnablePodENI = false
+				cancelCtx, cancelFunc := context.WithCancel(injection.WithOptions(ctx, optsCopy))
+				// ensure the provisioner is shut down at the end of this test
+				defer cancelFunc()
+				// clear any cached instance types
+				cloudProvider.(*CloudProvider).instanceTypeProvider.cache = cache.New(InstanceTypesAndZonesCacheTTL, CacheCleanupInterval)
+				provisionContoller := provisioning.NewController(cancelCtx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
 				ExpectApplied(ctx, env.Client, provisioner)
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("150Gi"),
+				for _, pod := range ExpectProvisioned(cancelCtx, env.Client, provisionContoller,
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
 						},
-					},
-					}))[0]
-				ExpectNotScheduled(ctx, env.Client, pod)
+					})) {
+					ExpectNotScheduled(cancelCtx, env.Client, pod)
+				}
+				// and ensure no one gets our no-ENI instance types
+				instanceTypeCache.Flush()
+				cloudProvider.(*CloudProvider).instanceTypeProvider.cache = instanceTypeCache
 			})
-			It("should pack pods using the blockdevicemappings from the provider spec when defined", func() {
-				provider, _ = v1alpha1.Deserialize(provisioner.Spec.Provider)
-				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-					DeviceName: aws.String("/dev/xvda"),
-					EBS: &v1alpha1.BlockDevice{
-						VolumeSize: resource.NewScaledQuantity(50, resource.Giga),
-					},
-				}}
-				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
-				pod := ExpectProvisioned(ctx, env.Client, controller,
-					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
-						Requests: map[v1.ResourceName]resource.Quantity{
-							v1.ResourceEphemeralStorage: resource.MustParse("25Gi"),
+			It("should launch AWS Pod ENI on a compatible instance type", func() {
+				instanceTypeCache.Flush()
+				ExpectApplied(ctx, env.Client, provisioner)
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSPodENI: resource.MustParse("1")},
 						},
-					},
-					}))[0]
-
-				// capacity isn't recorded on the node any longer, but we know the pod should schedule
-				ExpectScheduled(ctx, env.Client, pod)
+					})) {
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKey(v1.LabelInstanceTypeStable))
+					supportsPodENI := func() bool {
+						limits, ok := vpc.Limits[node.Labels[v1.LabelInstanceTypeStable]]
+						return ok && limits.IsTrunkingCompatible
+					}
+					Expect(supportsPodENI()).To(Equal(true))
+				}
 			})
-		})
-	})
-	Context("Defaulting", func() {
-		// Intent here is that if updates occur on the controller, the Provisioner doesn't need to be recreated
-		It("should not set the InstanceProfile with the default if none provided in Provisioner", func() {
-			provisioner.SetDefaults(ctx)
-			constraints, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(constraints.InstanceProfile).To(BeNil())
-		})
-
-		It("should default requirements", func() {
-			provisioner.SetDefaults(ctx)
-			Expect(provisioner.Spec.Requirements).To(ContainElement(v1.NodeSelectorRequirement{
-				Key:      v1alpha5.LabelCapacityType,
-				Operator: v1.NodeSelectorOpIn,
-				Values:   []string{v1alpha1.CapacityTypeOnDemand},
-			}))
-			Expect(provisioner.Spec.Requirements).To(ContainElement(v1.NodeSelectorRequirement{
-				Key:      v1.LabelArchStable,
-				Operator: v1.NodeSelectorOpIn,
+			It("should launch instances for Nvidia GPU resource requests", func() {
+				nodeNames := sets.NewString()
+				ExpectApplied(ctx, env.Client, provisioner)
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+						},
+					}),
+					// Should pack onto same instance
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("2")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("2")},
+						},
+					}),
+					// Should pack onto a separate instance
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
+						},
+					})) {
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "p3.8xlarge"))
+					nodeNames.Insert(node.Name)
+				}
+				Expect(nodeNames.Len()).To(Equal(2))
+			})
+			It("should advertise one nvidia.com/gpu per physical GPU by default", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("4")))
+				provider.GPUReplicaFactor = aws.Int64(1)
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("4")))
+			})
+			It("should multiply nvidia.com/gpu by the GPU replica factor", func() {
+				provider.GPUReplicaFactor = aws.Int64(4)
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("16")))
+				Expect(ExpectInstanceType(provider, "inf1.2xlarge").Resources()[v1alpha1.ResourceAWSNeuron]).To(Equal(resource.MustParse("1")))
+			})
+			Context("MIG Profiles", func() {
+				var info ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					info = *ExpectInstanceType(provider, "p3.8xlarge").InstanceTypeInfo
+					info.InstanceType = aws.String("p4d.24xlarge")
+					info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+						Name:         aws.String("A100"),
+						Manufacturer: aws.String("NVIDIA"),
+						Count:        aws.Int64(8),
+						MemoryInfo:   &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(40960)},
+					}}}
+				})
+				It("should advertise MIG devices instead of whole GPUs", func() {
+					provider.MIGProfiles = map[string]string{"p4d": "1g.5gb"}
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Resources()[v1.ResourceName("nvidia.com/mig-1g.5gb")]).To(Equal(resource.MustParse("56")))
+					Expect(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("0")))
+					Expect(v1alpha1.HasNVIDIAGPUs(instanceType.Resources())).To(BeTrue())
+				})
+				It("should advertise fewer devices for larger profiles", func() {
+					for profile, expected := range map[string]string{"2g.10gb": "24", "3g.20gb": "16", "4g.20gb": "8", "7g.40gb": "8"} {
+						provider.MIGProfiles = map[string]string{"p4d": profile}
+						Expect(NewTestInstanceType(provider, &info).Resources()[v1.ResourceName("nvidia.com/mig-"+profile)]).To(Equal(resource.MustParse(expected)))
+					}
+				})
+				It("should advertise whole GPUs for families without a MIG profile", func() {
+					provider.MIGProfiles = map[string]string{"p5": "1g.10gb"}
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("8")))
+					Expect(instanceType.Resources()).ToNot(HaveKey(v1.ResourceName("nvidia.com/mig-1g.10gb")))
+				})
+				It("should not advertise MIG devices for instance types without NVIDIA GPUs", func() {
+					provider.MIGProfiles = map[string]string{"m5": "1g.5gb"}
+					Expect(ExpectInstanceType(provider, "m5.large").Resources()).ToNot(HaveKey(v1.ResourceName("nvidia.com/mig-1g.5gb")))
+				})
+			})
+			It("should advertise Habana Gaudi accelerators", func() {
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("dl1.24xlarge")
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+					Name:         aws.String("Gaudi HL-205"),
+					Manufacturer: aws.String("Habana"),
+					Count:        aws.Int64(8),
+					MemoryInfo:   &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(32768)},
+				}}}
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Resources()[v1alpha1.ResourceHabanaGaudi]).To(Equal(resource.MustParse("8")))
+				Expect(instanceType.Resources()[v1alpha1.ResourceNVIDIAGPU]).To(Equal(resource.MustParse("0")))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceGPUManufacturerLabelKey).Values().List()).To(ConsistOf("habana"))
+				Expect(instanceType.Requirements().Get(v1alpha1.InstanceGPUNameLabelKey).Values().List()).To(ConsistOf("gaudi-hl-205"))
+			})
+			It("should not advertise Habana Gaudi accelerators for other instance types", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()[v1alpha1.ResourceHabanaGaudi]).To(Equal(resource.MustParse("0")))
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1alpha1.ResourceHabanaGaudi]).To(Equal(resource.MustParse("0")))
+			})
+			It("should not advertise GPU memory by default", func() {
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()).ToNot(HaveKey(v1alpha1.ResourceGPUMemory))
+			})
+			It("should advertise the memory of a single GPU", func() {
+				provider.EnableGPUMemoryResource = aws.Bool(true)
+				info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+				info.InstanceType = aws.String("g4dn.xlarge")
+				info.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+					Name:         aws.String("T4"),
+					Manufacturer: aws.String("NVIDIA"),
+					Count:        aws.Int64(1),
+					MemoryInfo:   &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+				}}}
+				Expect(NewTestInstanceType(provider, &info).Resources()[v1alpha1.ResourceGPUMemory]).To(Equal(resource.MustParse("16384")))
+			})
+			It("should advertise the total memory of multiple GPUs", func() {
+				provider.EnableGPUMemoryResource = aws.Bool(true)
+				Expect(ExpectInstanceType(provider, "p3.8xlarge").Resources()[v1alpha1.ResourceGPUMemory]).To(Equal(resource.MustParse("65536")))
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1alpha1.ResourceGPUMemory]).To(Equal(resource.MustParse("0")))
+			})
+			It("should not advertise local storage by default", func() {
+				info := *ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+				info.InstanceType = aws.String("c6gd.large")
+				info.InstanceStorageInfo = &ec2.InstanceStorageInfo{TotalSizeInGB: aws.Int64(118)}
+				Expect(NewTestInstanceType(provider, &info).Resources()).ToNot(HaveKey(v1alpha1.ResourceLocalStorage))
+			})
+			It("should advertise the total size of instance store volumes as local storage", func() {
+				provider.EnableLocalStorageResource = aws.Bool(true)
+				info := *ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+				info.InstanceType = aws.String("m6gd.16xlarge")
+				info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+					TotalSizeInGB: aws.Int64(3800),
+					Disks:         []*ec2.DiskInfo{{Count: aws.Int64(2), SizeInGB: aws.Int64(1900), Type: aws.String(ec2.DiskTypeSsd)}},
+				}
+				instanceType := NewTestInstanceType(provider, &info)
+				Expect(instanceType.Resources()[v1alpha1.ResourceLocalStorage]).To(Equal(*resource.NewScaledQuantity(3800, resource.Giga)))
+				// Local storage is advertised separately from ephemeral storage
+				Expect(instanceType.Resources()[v1.ResourceEphemeralStorage]).To(Equal(ExpectInstanceType(provider, "c6g.large").Resources()[v1.ResourceEphemeralStorage]))
+			})
+			It("should advertise no local storage for instance types without instance store volumes", func() {
+				provider.EnableLocalStorageResource = aws.Bool(true)
+				localStorage := ExpectInstanceType(provider, "m5.large").Resources()[v1alpha1.ResourceLocalStorage]
+				Expect(localStorage.IsZero()).To(BeTrue())
+				Expect(resources.Fits(v1.ResourceList{v1alpha1.ResourceLocalStorage: resource.MustParse("1G")}, ExpectInstanceType(provider, "m5.large").Resources())).To(BeFalse())
+			})
+			Context("GPU Reserved Memory", func() {
+				var g4dn ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					g4dn = *ExpectInstanceType(provider, "m5.xlarge").InstanceTypeInfo
+					g4dn.InstanceType = aws.String("g4dn.xlarge")
+					g4dn.GpuInfo = &ec2.GpuInfo{Gpus: []*ec2.GpuDeviceInfo{{
+						Name:         aws.String("T4"),
+						Manufacturer: aws.String("NVIDIA"),
+						Count:        aws.Int64(1),
+						MemoryInfo:   &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(16384)},
+					}}}
+				})
+				It("should deduct the memory reserved by GPU drivers", func() {
+					reserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("0")}
+					unreserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					unreserved.Sub(GPUReservedMemory["t4"])
+					Expect(reserved.Cmp(unreserved)).To(Equal(0))
+				})
+				It("should prefer the provider's reservation for the GPU model", func() {
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("0")}
+					unreserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("1Gi")}
+					reserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					unreserved.Sub(resource.MustParse("1Gi"))
+					Expect(reserved.Cmp(unreserved)).To(Equal(0))
+				})
+				It("should reserve memory for each GPU", func() {
+					g4dn.GpuInfo.Gpus[0].Count = aws.Int64(4)
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("0")}
+					unreserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("512Mi")}
+					reserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					unreserved.Sub(resource.MustParse("2Gi"))
+					Expect(reserved.Cmp(unreserved)).To(Equal(0))
+				})
+				It("should reserve the default for unknown GPU models", func() {
+					g4dn.GpuInfo.Gpus[0].Name = aws.String("Unknown")
+					reserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					provider.GPUReservedMemory = map[string]resource.Quantity{"unknown": resource.MustParse("0")}
+					unreserved := NewTestInstanceType(provider, &g4dn).Resources()[v1.ResourceMemory]
+					unreserved.Sub(DefaultGPUReservedMemory)
+					Expect(reserved.Cmp(unreserved)).To(Equal(0))
+				})
+				It("should not reserve memory without GPUs", func() {
+					memory := ExpectInstanceType(provider, "m5.xlarge").Resources()[v1.ResourceMemory]
+					provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("1Gi")}
+					Expect(memory.Cmp(ExpectInstanceType(provider, "m5.xlarge").Resources()[v1.ResourceMemory])).To(Equal(0))
+				})
+			})
+			It("should launch instances for AWS Neuron resource requests", func() {
+				nodeNames := sets.NewString()
+				ExpectApplied(ctx, env.Client, provisioner)
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+						},
+					}),
+					// Should pack onto same instance
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
+						},
+					}),
+					// Should pack onto a separate instance
+					test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("4")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("4")},
+						},
+					}),
+				) {
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.6xlarge"))
+					nodeNames.Insert(node.Name)
+				}
+				Expect(nodeNames.Len()).To(Equal(2))
+			})
+		})
+		Context("Insufficient Capacity Error Cache", func() {
+			It("should report offering availability", func() {
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				ExpectInstanceTypes(provider)
+				Expect(testutil.ToFloat64(offeringAvailableGauge.WithLabelValues("m5.large", "test-zone-1a", v1alpha1.CapacityTypeSpot))).To(Equal(1.0))
+				instanceTypeProvider.CacheUnavailable(ctx, &ec2.CreateFleetError{
+					ErrorCode: aws.String("InsufficientInstanceCapacity"),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+					},
+				}, v1alpha1.CapacityTypeSpot)
+				Expect(testutil.ToFloat64(offeringAvailableGauge.WithLabelValues("m5.large", "test-zone-1a", v1alpha1.CapacityTypeSpot))).To(Equal(0.0))
+				instanceTypeCache.Flush()
+				ExpectInstanceTypes(provider)
+				Expect(testutil.ToFloat64(offeringAvailableGauge.WithLabelValues("m5.large", "test-zone-1a", v1alpha1.CapacityTypeSpot))).To(Equal(0.0))
+				Expect(testutil.ToFloat64(offeringAvailableGauge.WithLabelValues("m5.large", "test-zone-1b", v1alpha1.CapacityTypeSpot))).To(Equal(1.0))
+				Expect(testutil.ToFloat64(offeringAvailableGauge.WithLabelValues("m5.large", "test-zone-1a", v1alpha1.CapacityTypeOnDemand))).To(Equal(1.0))
+			})
+			It("should launch instances of different type on second reconciliation attempt with Insufficient Capacity Error Cache fallback", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "inf1.6xlarge", Zone: "test-zone-1a"}})
+				ExpectApplied(ctx, env.Client, provisioner)
+				pods := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"},
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+						},
+					}),
+					test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"},
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("1")},
+						},
+					}),
+				)
+				// it should've tried to pack them on a single inf1.6xlarge then hit an insufficient capacity error
+				for _, pod := range pods {
+					ExpectNotScheduled(ctx, env.Client, pod)
+				}
+				nodeNames := sets.NewString()
+				for _, pod := range ExpectProvisioned(ctx, env.Client, controller, pods...) {
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.2xlarge"))
+					nodeNames.Insert(node.Name)
+				}
+				Expect(nodeNames.Len()).To(Equal(2))
+			})
+			It("should launch instances in a different zone on second reconciliation attempt with Insufficient Capacity Error Cache fallback", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "p3.8xlarge", Zone: "test-zone-1a"}})
+				pod := test.UnschedulablePod(test.PodOptions{
+					ResourceRequirements: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+						Limits:   v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+					},
+				})
+				pod.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+					{
+						Weight: 1, Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+						}},
+					},
+				}}}
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
+				// it should've tried to pack them in test-zone-1a on a p3.8xlarge then hit insufficient capacity, the next attempt will try test-zone-1b
+				ExpectNotScheduled(ctx, env.Client, pod)
+
+				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Labels).To(SatisfyAll(
+					HaveKeyWithValue(v1.LabelInstanceTypeStable, "p3.8xlarge"),
+					HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b")))
+			})
+			It("should launch smaller instances than optimal if larger instance launch results in Insufficient Capacity Error", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{
+					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+				})
+				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+					Key:      v1.LabelInstanceType,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{"m5.large", "m5.xlarge"},
+				})
+				pods := []*v1.Pod{}
+				for i := 0; i < 2; i++ {
+					pods = append(pods, test.UnschedulablePod(test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+						},
+						NodeSelector: map[string]string{
+							v1.LabelTopologyZone: "test-zone-1a",
+						},
+					}))
+				}
+				// Provisions 2 m5.large instances since m5.xlarge was ICE'd
+				ExpectApplied(ctx, env.Client, provisioner)
+				pods = ExpectProvisioned(ctx, env.Client, controller, pods...)
+				for _, pod := range pods {
+					ExpectNotScheduled(ctx, env.Client, pod)
+				}
+				pods = ExpectProvisioned(ctx, env.Client, controller, pods...)
+				for _, pod := range pods {
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels[v1.LabelInstanceTypeStable]).To(Equal("m5.large"))
+				}
+			})
+			It("should launch instances on later reconciliation attempt with Insufficient Capacity Error Cache expiry", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "inf1.6xlarge", Zone: "test-zone-1a"}})
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "inf1.6xlarge"},
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
+							Limits:   v1.ResourceList{v1alpha1.ResourceAWSNeuron: resource.MustParse("2")},
+						},
+					}),
+				)[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				// capacity shortage is over - expire the item from the cache and try again
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{})
+				unavailableOfferingsCache.Delete(UnavailableOfferingsCacheKey("inf1.6xlarge", "test-zone-1a", v1alpha1.CapacityTypeOnDemand))
+				pod = ExpectProvisioned(ctx, env.Client, controller, pod)[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "inf1.6xlarge"))
+			})
+			It("should launch on-demand capacity if flexible to both spot and on demand, but spot if unavailable", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.large", Zone: "test-zone-1a"}})
+				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+					{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeOnDemand}},
+					{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+					{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				}
+				// Spot Unavailable
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				// Fallback to OD
+				pod = ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeOnDemand))
+			})
+			It("should return all instance types, even though with no offerings due to Insufficient Capacity Error", func() {
+				fakeEC2API.SetInsufficientCapacityPools([]fake.CapacityPool{
+					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+					{CapacityType: v1alpha1.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+					{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+					{CapacityType: v1alpha1.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+				})
+				provisioner.Spec.Requirements = nil
+				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+					Key:      v1.LabelInstanceType,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{"m5.xlarge"},
+				})
+				provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+					Key:      v1alpha5.LabelCapacityType,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{"spot", "on-demand"},
+				})
+
+				ExpectApplied(ctx, env.Client, provisioner)
+				for _, ct := range []string{v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeSpot} {
+					for _, zone := range []string{"test-zone-1a", "test-zone-1b"} {
+						ExpectProvisioned(ctx, env.Client, controller,
+							test.UnschedulablePod(test.PodOptions{
+								ResourceRequirements: v1.ResourceRequirements{
+									Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+								},
+								NodeSelector: map[string]string{
+									v1alpha5.LabelCapacityType: ct,
+									v1.LabelTopologyZone:       zone,
+								},
+							}))
+					}
+				}
+
+				instanceTypeCache.Flush()
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner.Spec.Provider)
+				Expect(err).To(BeNil())
+				instanceTypeNames := sets.NewString()
+				for _, it := range instanceTypes {
+					instanceTypeNames.Insert(it.Name())
+					if it.Name() == "m5.xlarge" {
+						// should have no valid offerings
+						Expect(it.Offerings()).To(HaveLen(0))
+					}
+				}
+				Expect(instanceTypeNames.Has("m5.xlarge"))
+			})
+		})
+		Context("CapacityType", func() {
+			It("should default to on-demand", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeOnDemand))
+			})
+			It("should launch spot capacity if flexible to both spot and on demand", func() {
+				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+					{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeSpot, v1alpha1.CapacityTypeOnDemand}}}
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeSpot))
+			})
+			Context("Capacity Blocks", func() {
+				BeforeEach(func() {
+					info := *ExpectInstanceType(provider, "m5.large").InstanceTypeInfo
+					info.SupportedUsageClasses = aws.StringSlice([]string{v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeCapacityBlock})
+					fakeEC2API.DescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{InstanceTypes: []*ec2.InstanceTypeInfo{&info}}
+					fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+						{InstanceType: aws.String("m5.large"), Location: aws.String("test-zone-1a")},
+					}}
+					instanceTypeCache.Flush()
+					provider.CapacityReservationSelector = map[string]string{"foo": "bar"}
+					fakeEC2API.DescribeCapacityReservationsOutput = &ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{{
+						CapacityReservationId:  aws.String("cr-block-1"),
+						InstanceType:           aws.String("m5.large"),
+						AvailabilityZone:       aws.String("test-zone-1a"),
+						AvailableInstanceCount: aws.Int64(1),
+						InstanceMatchCriteria:  aws.String(ec2.InstanceMatchCriteriaTargeted),
+						ReservationType:        aws.String(ec2.CapacityReservationTypeCapacityBlock),
+					}}}
+				})
+				It("should offer capacity blocks", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Offerings()).To(ContainElement(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeCapacityBlock, CapacityReservationID: "cr-block-1"}))
+					Expect(instanceType.Requirements().Get(v1alpha5.LabelCapacityType).Values().List()).To(ConsistOf(v1alpha1.CapacityTypeOnDemand, v1alpha1.CapacityTypeCapacityBlock))
+				})
+				It("should only offer capacity blocks in discovered capacity block reservations", func() {
+					fakeEC2API.DescribeCapacityReservationsOutput = nil
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Offerings()).To(ConsistOf(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand}))
+				})
+				It("should launch capacity blocks through the capacity block market, targeting their reservation", func() {
+					provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+						{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeCapacityBlock}}}
+					ExpectApplied(ctx, env.Client, provisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeCapacityBlock))
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelCapacityReservationID, "cr-block-1"))
+					launchTemplate := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(aws.StringValue(launchTemplate.LaunchTemplateData.InstanceMarketOptions.MarketType)).To(Equal(ec2.MarketTypeCapacityBlock))
+					Expect(aws.StringValue(launchTemplate.LaunchTemplateData.CapacityReservationSpecification.CapacityReservationTarget.CapacityReservationId)).To(Equal("cr-block-1"))
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					Expect(aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(ec2.DefaultTargetCapacityTypeCapacityBlock))
+					for _, launchTemplateConfig := range input.LaunchTemplateConfigs {
+						for _, override := range launchTemplateConfig.Overrides {
+							Expect(aws.StringValue(override.InstanceType)).To(Equal("m5.large"))
+							Expect(aws.StringValue(override.AvailabilityZone)).To(Equal("test-zone-1a"))
+						}
+					}
+				})
+				It("should launch capacity blocks if flexible to capacity blocks and on demand", func() {
+					provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+						{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeCapacityBlock, v1alpha1.CapacityTypeOnDemand}}}
+					ExpectApplied(ctx, env.Client, provisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeCapacityBlock))
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					Expect(aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha1.CapacityTypeCapacityBlock))
+				})
+				It("should not launch capacity blocks by default", func() {
+					ExpectApplied(ctx, env.Client, provisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha1.CapacityTypeOnDemand))
+					launchTemplate := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(launchTemplate.LaunchTemplateData.InstanceMarketOptions).To(BeNil())
+				})
+			})
+			Context("Capacity Reservations", func() {
+				BeforeEach(func() {
+					provider.CapacityReservationSelector = map[string]string{"foo": "bar"}
+					fakeEC2API.DescribeCapacityReservationsOutput = &ec2.DescribeCapacityReservationsOutput{CapacityReservations: []*ec2.CapacityReservation{
+						{
+							CapacityReservationId:  aws.String("cr-test-1"),
+							InstanceType:           aws.String("m5.large"),
+							AvailabilityZone:       aws.String("test-zone-1a"),
+							AvailableInstanceCount: aws.Int64(1),
+							InstanceMatchCriteria:  aws.String(ec2.InstanceMatchCriteriaOpen),
+						},
+						{
+							CapacityReservationId:  aws.String("cr-test-2"),
+							InstanceType:           aws.String("m5.large"),
+							AvailabilityZone:       aws.String("test-zone-1b"),
+							AvailableInstanceCount: aws.Int64(0),
+							InstanceMatchCriteria:  aws.String(ec2.InstanceMatchCriteriaOpen),
+						},
+						{
+							CapacityReservationId:  aws.String("cr-test-3"),
+							InstanceType:           aws.String("m5.large"),
+							AvailabilityZone:       aws.String("test-zone-1b"),
+							AvailableInstanceCount: aws.Int64(1),
+							InstanceMatchCriteria:  aws.String(ec2.InstanceMatchCriteriaTargeted),
+						},
+					}}
+				})
+				It("should offer instance types in capacity reservations", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Offerings()).To(ContainElements(
+						cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand, CapacityReservationID: "cr-test-1"},
+						cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand},
+					))
+					Expect(instanceType.Requirements().Get(v1alpha1.LabelCapacityReservationID).Values().List()).To(ConsistOf("cr-test-1"))
+				})
+				It("should not offer capacity reservations without available instances", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Offerings()).ToNot(ContainElement(
+						cloudprovider.Offering{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand, CapacityReservationID: "cr-test-2"}))
+				})
+				It("should not offer targeted capacity reservations", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.Offerings()).ToNot(ContainElement(
+						cloudprovider.Offering{Zone: "test-zone-1b", CapacityType: v1alpha1.CapacityTypeOnDemand, CapacityReservationID: "cr-test-3"}))
+				})
+				It("should not offer capacity reservations for other instance types", func() {
+					instanceType := ExpectInstanceType(provider, "m5.xlarge")
+					for _, offering := range instanceType.Offerings() {
+						Expect(offering.CapacityReservationID).To(BeEmpty())
+					}
+					Expect(instanceType.Requirements().Get(v1alpha1.LabelCapacityReservationID).Type()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+					Expect(instanceType.Requirements().Compatible(scheduling.NewNodeSelectorRequirements(v1.NodeSelectorRequirement{
+						Key: v1alpha1.LabelCapacityReservationID, Operator: v1.NodeSelectorOpIn, Values: []string{"cr-test-1"},
+					}))).ToNot(Succeed())
+				})
+				It("should not launch pods pinned to a capacity reservation into instance types outside of it", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-test-1", v1.LabelInstanceTypeStable: "m5.xlarge"},
+					}))[0]
+					ExpectNotScheduled(ctx, env.Client, pod)
+				})
+				It("should not discover capacity reservations without a selector", func() {
+					provider.CapacityReservationSelector = nil
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					for _, offering := range instanceType.Offerings() {
+						Expect(offering.CapacityReservationID).To(BeEmpty())
+					}
+				})
+				It("should price capacity reservations below other offerings", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.OfferingPrice(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand, CapacityReservationID: "cr-test-1"})).To(BeZero())
+					Expect(instanceType.OfferingPrice(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand})).To(BeNumerically(">", 0))
+				})
+				It("should launch into capacity reservations", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-test-1"},
+					}))[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelCapacityReservationID, "cr-test-1"))
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1a"))
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					Expect(aws.StringValue(input.OnDemandOptions.CapacityReservationOptions.UsageStrategy)).To(Equal(ec2.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst))
+				})
+				It("should target the capacity reservation that the node is pinned to", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1alpha1.LabelCapacityReservationID: "cr-test-1"},
+					}))[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					launchTemplate := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(aws.StringValue(launchTemplate.LaunchTemplateData.CapacityReservationSpecification.CapacityReservationTarget.CapacityReservationId)).To(Equal("cr-test-1"))
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					for _, launchTemplateConfig := range input.LaunchTemplateConfigs {
+						for _, override := range launchTemplateConfig.Overrides {
+							Expect(aws.StringValue(override.InstanceType)).To(Equal("m5.large"))
+							Expect(aws.StringValue(override.AvailabilityZone)).To(Equal("test-zone-1a"))
+						}
+					}
+				})
+				It("should let EC2 choose the capacity reservation if the node isn't pinned to one", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large", v1.LabelTopologyZone: "test-zone-1a"},
+					}))[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelCapacityReservationID, "cr-test-1"))
+					launchTemplate := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(launchTemplate.LaunchTemplateData.CapacityReservationSpecification).To(BeNil())
+				})
+				It("should not label nodes that aren't launched into capacity reservations", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"},
+					}))[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).ToNot(HaveKey(v1alpha1.LabelCapacityReservationID))
+					input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+					Expect(input.OnDemandOptions.CapacityReservationOptions).To(BeNil())
+				})
+			})
+		})
+		Context("LaunchTemplates", func() {
+			BeforeEach(func() {
+				// The trunk ENI reserves pods on instance types that support pod ENI, so each of them would be launched from
+				// its own launch template that configures their max pods
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			AfterEach(func() {
+				controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			It("should use same launch template for equivalent constraints", func() {
+				t1 := v1.Toleration{
+					Key:      "Abacus",
+					Operator: "Equal",
+					Value:    "Zebra",
+					Effect:   "NoSchedule",
+				}
+				t2 := v1.Toleration{
+					Key:      "Zebra",
+					Operator: "Equal",
+					Value:    "Abacus",
+					Effect:   "NoSchedule",
+				}
+				t3 := v1.Toleration{
+					Key:      "Boar",
+					Operator: "Equal",
+					Value:    "Abacus",
+					Effect:   "NoSchedule",
+				}
+
+				// constrain the packer to a single launch template type
+				rr := v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:             resource.MustParse("24"),
+						v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
+					},
+					Limits: v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+				}
+
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod1 := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						Tolerations:          []v1.Toleration{t1, t2, t3},
+						ResourceRequirements: rr,
+					}),
+				)[0]
+				ExpectScheduled(ctx, env.Client, pod1)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				name1 := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput).LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName
+
+				pod2 := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{
+						Tolerations:          []v1.Toleration{t2, t3, t1},
+						ResourceRequirements: rr,
+					}),
+				)[0]
+
+				ExpectScheduled(ctx, env.Client, pod2)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				name2 := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput).LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName
+				Expect(name1).To(Equal(name2))
+			})
+			It("should tag with provisioner name", func() {
+				provisionerName := "the-provisioner"
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: provisionerName}}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
+
+				tags := map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisionerName,
+					"Name":                           fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, provisionerName),
+				}
+				// tags should be included in both the instance and volume tag specification
+				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, tags)
+
+				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
+				ExpectTags(createFleetInput.TagSpecifications[1].Tags, tags)
+			})
+			It("should request that tags be applied to both instances and volumes", func() {
+				provider.Tags = map[string]string{
+					"tag1": "tag1value",
+					"tag2": "tag2value",
+				}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
+
+				// tags should be included in both the instance and volume tag specification
+				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
+
+				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
+				ExpectTags(createFleetInput.TagSpecifications[1].Tags, provider.Tags)
+			})
+
+			It("should override default tag names", func() {
+				// these tags are defaulted, so ensure users can override them
+				provider.Tags = map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: "myprovisioner",
+					"Name":                           "myname",
+				}
+
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(createFleetInput.TagSpecifications).To(HaveLen(2))
+
+				// tags should be included in both the instance and volume tag specification
+				Expect(*createFleetInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeInstance))
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
+
+				Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
+				ExpectTags(createFleetInput.TagSpecifications[1].Tags, provider.Tags)
+			})
+			It("should default to a generated launch template", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+
+				firstLt := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				launchTemplate := createFleetInput.LaunchTemplateConfigs[0].LaunchTemplateSpecification
+				Expect(createFleetInput.LaunchTemplateConfigs).To(HaveLen(1))
+
+				Expect(*createFleetInput.LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName).
+					To(Equal(*firstLt.LaunchTemplateName))
+				Expect(firstLt.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Encrypted).To(Equal(aws.Bool(true)))
+				Expect(*launchTemplate.Version).To(Equal("$Latest"))
+			})
+			It("should allow a launch template to be specified", func() {
+				provider.LaunchTemplateName = aws.String("test-launch-template")
+				provider.SecurityGroupSelector = nil
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(input.LaunchTemplateConfigs).To(HaveLen(1))
+				launchTemplate := input.LaunchTemplateConfigs[0].LaunchTemplateSpecification
+				Expect(*launchTemplate.LaunchTemplateName).To(Equal("test-launch-template"))
+				Expect(*launchTemplate.Version).To(Equal("$Latest"))
+			})
+		})
+		Context("Subnets", func() {
+			It("should default to the cluster's subnets", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(
+					test.PodOptions{NodeSelector: map[string]string{v1.LabelArchStable: v1alpha5.ArchitectureAmd64}}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(input.LaunchTemplateConfigs).To(HaveLen(1))
+
+				foundNonGPULT := false
+				for _, v := range input.LaunchTemplateConfigs {
+					for _, ov := range v.Overrides {
+						if *ov.InstanceType == "m5.large" {
+							foundNonGPULT = true
+							Expect(v.Overrides).To(ContainElements(
+								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test1"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test2"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1b")},
+								&ec2.FleetLaunchTemplateOverridesRequest{SubnetId: aws.String("subnet-test3"), InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1c")},
+							))
+						}
+					}
+				}
+				Expect(foundNonGPULT).To(BeTrue())
+			})
+			It("should launch instances into subnet with the most available IP addresses", func() {
+				fakeEC2API.DescribeSubnetsOutput = &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+					{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
+						Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
+					{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+						Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
+				}}
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"}}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("test-subnet-2"))
+			})
+			It("should discover subnet by ID", func() {
+				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-test1"))
+			})
+			It("should discover subnets by IDs", func() {
+				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1,subnet-test2"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
+					"subnet-test1",
+					"subnet-test2",
+				))
+			})
+			It("should discover subnets by IDs and tags", func() {
+				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test1,subnet-test2", "foo": "bar"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
+					"subnet-test1",
+					"subnet-test2",
+				))
+			})
+			It("should discover subnets by IDs intersected with tags", func() {
+				provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test2", "foo": "bar"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop().(*ec2.CreateFleetInput)
+				Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf(
+					"subnet-test2",
+				))
+			})
+		})
+		Context("Security Groups", func() {
+			BeforeEach(func() {
+				// The trunk ENI reserves pods on instance types that support pod ENI, so each of them would be launched from
+				// its own launch template that configures their max pods
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			AfterEach(func() {
+				controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			It("should default to the clusters security groups", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"sg-test1",
+					"sg-test2",
+					"sg-test3",
+				))
+			})
+			It("should discover security groups by tag", func() {
+				fakeEC2API.DescribeSecurityGroupsOutput = &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+					{GroupId: aws.String("test-sg-1"), Tags: []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-1")}}},
+					{GroupId: aws.String("test-sg-2"), Tags: []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("test-sg-2")}}},
+				}}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"test-sg-1",
+					"test-sg-2",
+				))
+			})
+			It("should discover security groups by ID", func() {
+				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"sg-test1",
+				))
+			})
+			It("should discover security groups by IDs", func() {
+				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1,sg-test2"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"sg-test1",
+					"sg-test2",
+				))
+			})
+			It("should discover security groups by IDs and tags", func() {
+				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test1,sg-test2", "foo": "bar"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"sg-test1",
+					"sg-test2",
+				))
+			})
+			It("should discover security groups by IDs intersected with tags", func() {
+				provider.SecurityGroupSelector = map[string]string{"aws-ids": "sg-test2", "foo": "bar"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(aws.StringValueSlice(input.LaunchTemplateData.SecurityGroupIds)).To(ConsistOf(
+					"sg-test2",
+				))
+			})
+		})
+		Context("User Data", func() {
+			BeforeEach(func() {
+				// The trunk ENI reserves pods on instance types that support pod ENI, so each of them would be launched from
+				// its own launch template that configures their max pods
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			AfterEach(func() {
+				controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			It("should not specify --use-max-pods=false when using ENI-based pod density", func() {
+				opts.AWSENILimitedPodDensity = true
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).NotTo(ContainSubstring("--use-max-pods false"))
+			})
+			It("should specify --use-max-pods=false when not using ENI-based pod density", func() {
+				opts.AWSENILimitedPodDensity = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("--use-max-pods false"))
+				Expect(string(userData)).To(ContainSubstring("--max-pods=110"))
+			})
+			DescribeTable("should configure kubelet with the allocatable pods of the instance type",
+				func(configure func(*options.Options)) {
+					opts := opts
+					opts.AWSEnablePodENI = false
+					configure(&opts)
+					controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+						NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+					}))[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+					zones := sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+					instanceType := ExpectNewInstanceType(injection.WithOptions(ctx, opts), ExpectInstanceType(provider, "m5.large").InstanceTypeInfo, provider, zones, zones, nil)
+					allocatable := resources.Subtract(instanceType.Resources(), instanceType.Overhead())
+					Expect(string(userData)).To(ContainSubstring("--use-max-pods false"))
+					Expect(string(userData)).To(ContainSubstring(fmt.Sprintf("--max-pods=%d'", allocatable.Pods().Value())))
+				},
+				Entry("with max pods per instance type", func(*options.Options) { provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 8} }),
+				Entry("with instance type overrides", func(*options.Options) {
+					provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{"m5.large": {Pods: aws.Int32(12)}}
+				}),
+				Entry("with a pod PIDs limit", func(*options.Options) { provider.PodPIDsLimit = aws.Int64(2048) }),
+				Entry("with custom networking", func(*options.Options) { provider.CustomNetworking = aws.Bool(true) }),
+				Entry("with IPv6", func(*options.Options) { provider.IPFamily = aws.String(v1alpha1.IPFamilyIPv6) }),
+				Entry("with pod ENI", func(opts *options.Options) { opts.AWSEnablePodENI = true }),
+				Entry("without ENI limited pod density", func(opts *options.Options) { opts.AWSENILimitedPodDensity = false }),
+			)
+			It("should launch instance types configured with different max pods from different launch templates", func() {
+				provider.MaxPodsPerInstanceType = map[string]int32{"m5.large": 8}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+					NodeSelector: map[string]string{v1alpha1.InstanceFamilyLabelKey: "m5"},
+				}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(2))
+				var configured []string
+				fakeEC2API.CalledWithCreateLaunchTemplateInput.Each(func(input interface{}) bool {
+					userData, _ := base64.StdEncoding.DecodeString(*input.(*ec2.CreateLaunchTemplateInput).LaunchTemplateData.UserData)
+					if strings.Contains(string(userData), "--max-pods=") {
+						configured = append(configured, string(userData))
+					}
+					return false
+				})
+				Expect(configured).To(HaveLen(1))
+				Expect(configured[0]).To(ContainSubstring("--max-pods=8'"))
+			})
+			It("should specify --container-runtime containerd by default", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("--container-runtime containerd"))
+			})
+			It("should specify dockerd if specified in the provisionerSpec", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{
+					Provider: provider,
+					Kubelet:  &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")},
+				}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("--container-runtime dockerd"))
+			})
+			It("should specify --container-runtime docker when using Neuron GPUs", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+					ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceCPU:             resource.MustParse("1"),
+							v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
+						},
+						Limits: map[v1.ResourceName]resource.Quantity{
+							v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
+						},
+					},
+				}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("--container-runtime docker"))
+			})
+			It("should specify --container-runtime containerd when using Nvidia GPUs", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(test.PodOptions{
+					ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceCPU:             resource.MustParse("1"),
+							v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
+						},
+						Limits: map[v1.ResourceName]resource.Quantity{
+							v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
+						},
+					},
+				}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("--container-runtime containerd"))
+			})
+			Context("Bottlerocket", func() {
+				It("should merge in custom user data", func() {
+					opts.AWSENILimitedPodDensity = false
+					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+					content, _ := ioutil.ReadFile("testdata/br_userdata_input.golden")
+					providerRefName := strings.ToLower(randomdata.SillyName())
+					providerRef := &v1alpha5.ProviderRef{
+						Name: providerRefName,
+					}
+					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
+						UserData:   aws.String(string(content)),
+						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
+					ExpectApplied(ctx, env.Client, newProvisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+					content, _ = ioutil.ReadFile("testdata/br_userdata_merged.golden")
+					// Newlines are always added for missing TOML fields, so strip them out before comparisons.
+					actualUserData := strings.Replace(string(userData), "\n", "", -1)
+					expectedUserData := strings.Replace(fmt.Sprintf(string(content), newProvisioner.Name), "\n", "", -1)
+					Expect(expectedUserData).To(Equal(actualUserData))
+				})
+				It("should bootstrap when custom user data is empty", func() {
+					opts.AWSENILimitedPodDensity = false
+					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+					providerRefName := strings.ToLower(randomdata.SillyName())
+					providerRef := &v1alpha5.ProviderRef{
+						Name: providerRefName,
+					}
+					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
+						UserData:   nil,
+						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
+					ExpectApplied(ctx, env.Client, newProvisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+					content, _ := ioutil.ReadFile("testdata/br_userdata_unmerged.golden")
+					actualUserData := strings.Replace(string(userData), "\n", "", -1)
+					expectedUserData := strings.Replace(fmt.Sprintf(string(content), newProvisioner.Name), "\n", "", -1)
+					Expect(expectedUserData).To(Equal(actualUserData))
+				})
+				It("should not bootstrap when provider ref points to a non-existent resource", func() {
+					opts.AWSENILimitedPodDensity = false
+					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+					providerRef := &v1alpha5.ProviderRef{
+						Name: "doesnotexist",
+					}
+					controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
+					ExpectApplied(ctx, env.Client, newProvisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					// This will not be scheduled since we were pointed to a non-existent awsnodetemplate resource.
+					ExpectNotScheduled(ctx, env.Client, pod)
+				})
+				It("should not bootstrap on invalid toml user data", func() {
+					provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+					providerRefName := strings.ToLower(randomdata.SillyName())
+					providerRef := &v1alpha5.ProviderRef{
+						Name: providerRefName,
+					}
+					nodeTemplate := test.AWSNodeTemplate(test.AWSNodeTemplateOptions{
+						UserData:   aws.String("#/bin/bash\n ./not-toml.sh"),
+						ObjectMeta: metav1.ObjectMeta{Name: providerRefName}})
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+					newProvisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider, ProviderRef: providerRef})
+					ExpectApplied(ctx, env.Client, newProvisioner)
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					// This will not be scheduled since userData cannot be generated for the prospective node.
+					ExpectNotScheduled(ctx, env.Client, pod)
+				})
+			})
+			Context("Kubelet Args", func() {
+				It("should specify the --dns-cluster-ip flag when clusterDNSIP is set", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{
+						Kubelet:  &v1alpha5.KubeletConfiguration{ClusterDNS: []string{"10.0.10.100"}},
+						Provider: provider,
+					}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+					Expect(string(userData)).To(ContainSubstring("--dns-cluster-ip '10.0.10.100'"))
+				})
+			})
+			Context("Instance Profile", func() {
+				It("should use the default instance profile if none specified on the Provisioner", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(*input.LaunchTemplateData.IamInstanceProfile.Name).To(Equal("test-instance-profile"))
+				})
+				It("should use the instance profile on the Provisioner when specified", func() {
+					provider.InstanceProfile = aws.String("overridden-profile")
+					ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+					input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+					Expect(*input.LaunchTemplateData.IamInstanceProfile.Name).To(Equal("overridden-profile"))
+				})
+			})
+		})
+		Context("Metadata Options", func() {
+			BeforeEach(func() {
+				// The trunk ENI reserves pods on instance types that support pod ENI, so each of them would be launched from
+				// its own launch template that configures their max pods
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			AfterEach(func() {
+				controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			It("should default metadata options on generated launch template", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpEndpoint).To(Equal(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpProtocolIpv6).To(Equal(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Disabled))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpPutResponseHopLimit).To(Equal(int64(2)))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateRequired))
+			})
+			It("should set metadata options on generated launch template from provisioner configuration", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MetadataOptions = &v1alpha1.MetadataOptions{
+					HTTPEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateDisabled),
+					HTTPProtocolIPv6:        aws.String(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled),
+					HTTPPutResponseHopLimit: aws.Int64(1),
+					HTTPTokens:              aws.String(ec2.LaunchTemplateHttpTokensStateOptional),
+				}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpEndpoint).To(Equal(ec2.LaunchTemplateInstanceMetadataEndpointStateDisabled))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpProtocolIpv6).To(Equal(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpPutResponseHopLimit).To(Equal(int64(1)))
+				Expect(*input.LaunchTemplateData.MetadataOptions.HttpTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateOptional))
+			})
+		})
+		Context("Block Device Mappings", func() {
+			BeforeEach(func() {
+				// The trunk ENI reserves pods on instance types that support pod ENI, so each of them would be launched from
+				// its own launch template that configures their max pods
+				opts := opts
+				opts.AWSEnablePodENI = false
+				controller = provisioning.NewController(injection.WithOptions(ctx, opts), cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			AfterEach(func() {
+				controller = provisioning.NewController(ctx, cfg, env.Client, clientSet.CoreV1(), recorder, cloudProvider, cluster)
+			})
+			It("should default AL2 block device mappings", func() {
+				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				provider.AMIFamily = &v1alpha1.AMIFamilyAL2
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(20)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("gp3"))
+				Expect(input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(BeNil())
+			})
+			It("should use custom block device mapping", func() {
+				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				provider.AMIFamily = &v1alpha1.AMIFamilyAL2
+				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+					{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							DeleteOnTermination: aws.Bool(true),
+							Encrypted:           aws.Bool(true),
+							VolumeType:          aws.String("io2"),
+							VolumeSize:          resource.NewScaledQuantity(40, resource.Giga),
+							IOPS:                aws.Int64(10_000),
+							KMSKeyID:            aws.String("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"),
+						},
+					},
+				}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(1))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(40)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("io2"))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(Equal(int64(10_000)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.DeleteOnTermination).To(BeTrue())
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Encrypted).To(BeTrue())
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.KmsKeyId).To(Equal("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"))
+			})
+			It("should default bottlerocket second volume with root volume size", func() {
+				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(2))
+				// Bottlerocket control volume
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(4)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeType).To(Equal("gp3"))
+				Expect(input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.Iops).To(BeNil())
+				// Bottlerocket user volume
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(20)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeType).To(Equal("gp3"))
+				Expect(input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.Iops).To(BeNil())
+			})
+			It("should resize the default ephemeral volume to the default volume size", func() {
+				provider, _ := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.DefaultVolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Cardinality()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().(*ec2.CreateLaunchTemplateInput)
+				Expect(len(input.LaunchTemplateData.BlockDeviceMappings)).To(Equal(2))
+				// Bottlerocket control volume
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(4)))
+				// Bottlerocket user volume
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(100)))
+				Expect(*input.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeType).To(Equal("gp3"))
+				Expect(amifamily.DefaultEBS.VolumeSize.Cmp(*resource.NewScaledQuantity(20, resource.Giga))).To(Equal(0))
+			})
+		})
+		Context("Ephemeral Storage", func() {
+			It("should pack pods when a daemonset has an ephemeral-storage request", func() {
+				ExpectApplied(ctx, env.Client, provisioner, test.DaemonSet(
+					test.DaemonSetOptions{PodOptions: test.PodOptions{
+						ResourceRequirements: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"),
+								v1.ResourceMemory:           resource.MustParse("1Gi"),
+								v1.ResourceEphemeralStorage: resource.MustParse("1Gi")}},
+					}},
+				))
+				pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod())
+				ExpectScheduled(ctx, env.Client, pod[0])
+			})
+			It("should pack pods with any ephemeral-storage request", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("1G"),
+						}}}))
+				ExpectScheduled(ctx, env.Client, pod[0])
+			})
+			It("should pack pods with large ephemeral-storage request", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+						}}}))
+				ExpectScheduled(ctx, env.Client, pod[0])
+			})
+			It("should not pack pods if the sum of pod ephemeral-storage and overhead exceeds node capacity", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("19Gi"),
+						}}}))
+				ExpectNotScheduled(ctx, env.Client, pod[0])
+			})
+			It("should launch multiple nodes if sum of pod ephemeral-storage requests exceeds a single nodes capacity", func() {
+				var nodes []*v1.Node
+				ExpectApplied(ctx, env.Client, provisioner)
+				pods := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+						},
+					},
+					}),
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+						},
+					},
+					}),
+				)
+				for _, pod := range pods {
+					nodes = append(nodes, ExpectScheduled(ctx, env.Client, pod))
+				}
+				Expect(nodes).To(HaveLen(2))
+			})
+			It("should only pack pods with ephemeral-storage requests that will fit on an available node", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pods := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+						},
+					},
+					}),
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("150Gi"),
+						},
+					},
+					}),
+				)
+				ExpectScheduled(ctx, env.Client, pods[0])
+				ExpectNotScheduled(ctx, env.Client, pods[1])
+			})
+			It("should not pack pod if no available instance types have enough storage", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("150Gi"),
+						},
+					},
+					}))[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should pack pods using the blockdevicemappings from the provider spec when defined", func() {
+				provider, _ = v1alpha1.Deserialize(provisioner.Spec.Provider)
+				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+					DeviceName: aws.String("/dev/xvda"),
+					EBS: &v1alpha1.BlockDevice{
+						VolumeSize: resource.NewScaledQuantity(50, resource.Giga),
+					},
+				}}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+						Requests: map[v1.ResourceName]resource.Quantity{
+							v1.ResourceEphemeralStorage: resource.MustParse("25Gi"),
+						},
+					},
+					}))[0]
+
+				// capacity isn't recorded on the node any longer, but we know the pod should schedule
+				ExpectScheduled(ctx, env.Client, pod)
+			})
+		})
+		Context("Ephemeral Storage Overhead", func() {
+			BeforeEach(func() {
+				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+					DeviceName: aws.String("/dev/xvda"),
+					EBS:        &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(6, resource.Giga)},
+				}}
+				// The image cache reservation is covered separately
+				provider.ImageGCReservationPercentage = aws.Int64(0)
+			})
+			Context("Image GC Reservation", func() {
+				BeforeEach(func() {
+					provider.ImageGCReservationPercentage = nil
+					provider.BlockDeviceMappings[0].EBS.VolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+				})
+				It("should reserve the default percentage of the volume for the image cache", func() {
+					overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+					expected := resource.MustParse("5Gi")
+					expected.Add(*resource.NewScaledQuantity(15, resource.Giga))
+					Expect(overhead.Cmp(expected)).To(Equal(0))
+				})
+				It("should reserve a custom percentage of the volume for the image cache", func() {
+					provider.ImageGCReservationPercentage = aws.Int64(30)
+					overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+					expected := resource.MustParse("5Gi")
+					expected.Add(*resource.NewScaledQuantity(30, resource.Giga))
+					Expect(overhead.Cmp(expected)).To(Equal(0))
+				})
+				It("should leave the minimum allocatable ephemeral storage", func() {
+					provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(90, resource.Giga)
+					overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+					Expect(overhead.Cmp(*resource.NewScaledQuantity(10, resource.Giga))).To(Equal(0))
+				})
+			})
+			It("should use the AMI family's overhead by default", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]).To(Equal(resource.MustParse("5Gi")))
+			})
+			It("should reduce the overhead to leave the minimum allocatable ephemeral storage", func() {
+				provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(4, resource.Giga)
+				overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+				Expect(overhead.Cmp(*resource.NewScaledQuantity(2, resource.Giga))).To(Equal(0))
+			})
+			It("should not reduce the overhead below zero", func() {
+				provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(10, resource.Giga)
+				overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+				Expect(overhead.IsZero()).To(BeTrue())
+			})
+			It("should not change the overhead when the volume is large enough", func() {
+				provider.BlockDeviceMappings[0].EBS.VolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+				provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(4, resource.Giga)
+				Expect(ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]).To(Equal(resource.MustParse("5Gi")))
+			})
+			It("should warn when the ephemeral volume can't hold the overhead", func() {
+				provider.BlockDeviceMappings[0].EBS.VolumeSize = resource.NewScaledQuantity(4, resource.Giga)
+				Expect(ephemeralStorageWarning(provider)).To(MatchError(ContainSubstring("ephemeral volume size 4G is not larger than the ephemeral storage overhead 5Gi")))
+			})
+			It("should not warn for volumes larger than the overhead", func() {
+				Expect(ephemeralStorageWarning(provider)).To(Succeed())
+				provider.BlockDeviceMappings = nil
+				Expect(ephemeralStorageWarning(provider)).To(Succeed())
+			})
+			Context("RAID0 Instance Store Policy", func() {
+				var info ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					provider.InstanceStorePolicy = aws.String(v1alpha1.InstanceStorePolicyRAID0)
+					info = *ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+				})
+				It("should use a single NVMe disk for ephemeral storage", func() {
+					info.InstanceType = aws.String("c7gd.large")
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+						TotalSizeInGB: aws.Int64(118),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(1), SizeInGB: aws.Int64(118), Type: aws.String(ec2.DiskTypeSsd)}},
+					}
+					Expect(NewTestInstanceType(provider, &info).Resources()[v1.ResourceEphemeralStorage]).To(Equal(*resource.NewScaledQuantity(118, resource.Giga)))
+				})
+				It("should aggregate multiple NVMe disks less the RAID0 overhead", func() {
+					info.InstanceType = aws.String("m7gd.16xlarge")
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+						TotalSizeInGB: aws.Int64(3800),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(2), SizeInGB: aws.Int64(1900), Type: aws.String(ec2.DiskTypeSsd)}},
+					}
+					expected := *resource.NewScaledQuantity(3800, resource.Giga)
+					expected.Sub(resource.MustParse("256Mi"))
+					ephemeralStorage := NewTestInstanceType(provider, &info).Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(expected)).To(Equal(0))
+				})
+				It("should use the ephemeral volume for instance types without NVMe instance store", func() {
+					ephemeralStorage := ExpectInstanceType(provider, "c6g.large").Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportUnsupported),
+						TotalSizeInGB: aws.Int64(160),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(1), SizeInGB: aws.Int64(160), Type: aws.String(ec2.DiskTypeHdd)}},
+					}
+					ephemeralStorage = NewTestInstanceType(provider, &info).Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+				})
+				It("should deduct swap from ephemeral storage", func() {
+					provider.Swap = resource.NewScaledQuantity(18, resource.Giga)
+					info.InstanceType = aws.String("c7gd.large")
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+						TotalSizeInGB: aws.Int64(118),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(1), SizeInGB: aws.Int64(118), Type: aws.String(ec2.DiskTypeSsd)}},
+					}
+					ephemeralStorage := NewTestInstanceType(provider, &info).Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(*resource.NewScaledQuantity(100, resource.Giga))).To(Equal(0))
+				})
+				It("should use the ephemeral volume without the RAID0 policy", func() {
+					provider.InstanceStorePolicy = nil
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+						TotalSizeInGB: aws.Int64(118),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(1), SizeInGB: aws.Int64(118), Type: aws.String(ec2.DiskTypeSsd)}},
+					}
+					ephemeralStorage := NewTestInstanceType(provider, &info).Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+				})
+			})
+			Context("Swap", func() {
+				var info ec2.InstanceTypeInfo
+				BeforeEach(func() {
+					info = *ExpectInstanceType(provider, "c6g.large").InstanceTypeInfo
+					info.InstanceType = aws.String("c7gd.large")
+					info.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+						NvmeSupport:   aws.String(ec2.EphemeralNvmeSupportRequired),
+						TotalSizeInGB: aws.Int64(118),
+						Disks:         []*ec2.DiskInfo{{Count: aws.Int64(1), SizeInGB: aws.Int64(118), Type: aws.String(ec2.DiskTypeSsd)}},
+					}
+				})
+				It("should not change resources or exclude instance types without swap", func() {
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeTrue())
+					Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(ExpectInstanceType(provider, "m5.large"), provider)).To(BeTrue())
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+				})
+				It("should not deduct swap from an ephemeral volume", func() {
+					provider.Swap = resource.NewScaledQuantity(18, resource.Giga)
+					instanceType := NewTestInstanceType(provider, &info)
+					Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(instanceType, provider)).To(BeTrue())
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+				})
+				It("should exclude instance types without enough NVMe instance store for swap", func() {
+					provider.Swap = resource.NewScaledQuantity(118, resource.Giga)
+					Expect(cloudProvider.(*CloudProvider).instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, &info), provider)).To(BeFalse())
+					provider.Swap = resource.NewScaledQuantity(18, resource.Giga)
+					Expect(ExpectInstanceTypeNames(provider).Has("m5.large")).To(BeFalse())
+				})
+			})
+			Context("Containerd Data Device", func() {
+				BeforeEach(func() {
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(6, resource.Giga)}},
+						{DeviceName: aws.String("/dev/xvdb"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(100, resource.Giga)}},
+					}
+				})
+				It("should use the root volume for ephemeral storage by default", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+				})
+				It("should use the containerd data volume for ephemeral storage", func() {
+					provider.ContainerdDataDevice = aws.String("/dev/xvdb")
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("100G"))).To(Equal(0))
+					Expect(instanceType.RootDevice()).To(Equal("/dev/xvda"))
+				})
+				It("should compute the overhead against the containerd data volume", func() {
+					provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(4, resource.Giga)
+					overhead := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]
+					Expect(overhead.Cmp(*resource.NewScaledQuantity(2, resource.Giga))).To(Equal(0))
+					provider.ContainerdDataDevice = aws.String("/dev/xvdb")
+					Expect(ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceEphemeralStorage]).To(Equal(resource.MustParse("5Gi")))
+				})
+				It("should not warn for a small root volume with a larger containerd data volume", func() {
+					provider.BlockDeviceMappings[0].EBS.VolumeSize = resource.NewScaledQuantity(4, resource.Giga)
+					Expect(ephemeralStorageWarning(provider)).ToNot(Succeed())
+					provider.ContainerdDataDevice = aws.String("/dev/xvdb")
+					Expect(ephemeralStorageWarning(provider)).To(Succeed())
+				})
+			})
+			Context("WithProvider", func() {
+				It("should recompute ephemeral storage for the provider's block device mappings", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					other := provider.DeepCopy()
+					other.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS:        &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(50, resource.Giga)},
+					}}
+					clone := instanceType.WithProvider(other)
+					ephemeralStorage := clone.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("50G"))).To(Equal(0))
+					ephemeralStorage = instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("6G"))).To(Equal(0))
+					Expect(clone.InstanceTypeInfo).To(BeIdenticalTo(instanceType.InstanceTypeInfo))
+					Expect(clone.Offerings()).To(Equal(instanceType.Offerings()))
+				})
+				It("should recompute overhead for the provider's AMI family", func() {
+					provider.MinAllocatableEphemeralStorage = resource.NewScaledQuantity(4, resource.Giga)
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					other := provider.DeepCopy()
+					other.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+					clone := instanceType.WithProvider(other)
+					Expect(clone.RootDevice()).To(Equal("/dev/xvdb"))
+					Expect(instanceType.RootDevice()).To(Equal("/dev/xvda"))
+					// The Bottlerocket data volume isn't mapped, so it has the default size and the full overhead
+					Expect(clone.Overhead()[v1.ResourceEphemeralStorage]).To(Equal(resource.MustParse("5Gi")))
+					overhead := instanceType.Overhead()[v1.ResourceEphemeralStorage]
+					Expect(overhead.Cmp(*resource.NewScaledQuantity(2, resource.Giga))).To(Equal(0))
+				})
+				It("should recompute pods for the provider", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					other := provider.DeepCopy()
+					other.MaxPodsPerInstanceType = map[string]int32{"m5.large": 20}
+					pods := instanceType.WithProvider(other).Resources()[v1.ResourcePods]
+					Expect(pods.Value()).To(BeNumerically("==", 20))
+					pods = instanceType.Resources()[v1.ResourcePods]
+					Expect(pods.Value()).To(BeNumerically("==", 60))
+				})
+			})
+			Context("AMI Family Ephemeral Device", func() {
+				BeforeEach(func() {
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(4, resource.Giga)}},
+						{DeviceName: aws.String("/dev/xvdb"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(60, resource.Giga)}},
+					}
+				})
+				It("should resolve ephemeral storage against Bottlerocket's data volume", func() {
+					provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.EphemeralDevice()).To(Equal("/dev/xvdb"))
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("60G"))).To(Equal(0))
+					Expect(ephemeralStorageWarning(provider)).To(Succeed())
+				})
+				It("should resolve ephemeral storage against AL2's root volume", func() {
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.EphemeralDevice()).To(Equal("/dev/xvda"))
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("4G"))).To(Equal(0))
+					Expect(ephemeralStorageWarning(provider)).ToNot(Succeed())
+				})
+				It("should prefer the containerd data device over the AMI family's ephemeral device", func() {
+					provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+					provider.ContainerdDataDevice = aws.String("/dev/xvda")
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					Expect(instanceType.EphemeralDevice()).To(Equal("/dev/xvda"))
+					Expect(instanceType.RootDevice()).To(Equal("/dev/xvdb"))
+					ephemeralStorage := instanceType.Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(resource.MustParse("4G"))).To(Equal(0))
+				})
+			})
+			It("should report the AMI family's root device", func() {
+				for amiFamily, device := range map[string]string{
+					v1alpha1.AMIFamilyAL2:          "/dev/xvda",
+					v1alpha1.AMIFamilyBottlerocket: "/dev/xvdb",
+					v1alpha1.AMIFamilyUbuntu:       "/dev/sda1",
+				} {
+					provider.AMIFamily = aws.String(amiFamily)
+					Expect(ExpectInstanceType(provider, "m5.large").RootDevice()).To(Equal(device))
+				}
+			})
+			It("should use the volume size of the ephemeral volume", func() {
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceEphemeralStorage]).To(Equal(resource.MustParse("6G")))
+			})
+			It("should fall back to the default volume size when the ephemeral volume has no size", func() {
+				provider.BlockDeviceMappings[0].EBS = &v1alpha1.BlockDevice{SnapshotID: aws.String("snap-0123456789")}
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceEphemeralStorage]).To(Equal(*amifamily.DefaultEBS.VolumeSize))
+			})
+			It("should use the AMI family's default volume size without block device mappings", func() {
+				provider.BlockDeviceMappings = nil
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceEphemeralStorage]).To(Equal(*amifamily.DefaultEBS.VolumeSize))
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceEphemeralStorage]).To(Equal(*amifamily.DefaultEBS.VolumeSize))
+				// macOS AMIs default to a larger root volume
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyMacOS)
+				Expect(ephemeralVolumeSize(provider)).To(Equal(*resource.NewScaledQuantity(200, resource.Giga)))
+			})
+			It("should use the provider's default volume size without block device mappings", func() {
+				provider.BlockDeviceMappings = nil
+				provider.DefaultVolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+				for _, family := range []string{v1alpha1.AMIFamilyAL2, v1alpha1.AMIFamilyBottlerocket} {
+					provider.AMIFamily = aws.String(family)
+					ephemeralStorage := ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceEphemeralStorage]
+					Expect(ephemeralStorage.Cmp(*resource.NewScaledQuantity(100, resource.Giga))).To(Equal(0), family)
+				}
+			})
+			It("should only consider the AMI family's ephemeral volume", func() {
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				provider.BlockDeviceMappings[0].EBS.VolumeSize = resource.NewScaledQuantity(4, resource.Giga)
+				Expect(ephemeralStorageWarning(provider)).To(Succeed())
+			})
+		})
+		Context("Overhead Calculators", func() {
+			AfterEach(func() {
+				delete(OverheadCalculators, v1alpha1.AMIFamilyUbuntu)
+			})
+			It("should use the default calculator for every AMI family by default", func() {
+				overhead := ExpectInstanceType(provider, "m5.large").Overhead()
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyUbuntu)
+				Expect(ExpectInstanceType(provider, "m5.large").Overhead()).To(Equal(overhead))
+			})
+			It("should use the AMI family's calculator", func() {
+				OverheadCalculators[v1alpha1.AMIFamilyUbuntu] = stubOverheadCalculator{v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1"),
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				}}
+				al2 := ExpectInstanceType(provider, "m5.large").Overhead()
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyUbuntu)
+				ubuntu := ExpectInstanceType(provider, "m5.large").Overhead()
+				Expect(ubuntu).ToNot(Equal(al2))
+				Expect(ubuntu).To(Equal(v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1"),
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				}))
+			})
+			It("should never reserve overhead from device resources", func() {
+				OverheadCalculators[v1alpha1.AMIFamilyUbuntu] = stubOverheadCalculator{v1.ResourceList{
+					v1.ResourceCPU:             resource.MustParse("1"),
+					v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
+					v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
+					v1alpha1.ResourceAWSPodENI: resource.MustParse("1"),
+				}}
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyUbuntu)
+				instanceType := ExpectInstanceType(provider, "p3.8xlarge")
+				Expect(instanceType.Overhead()).To(Equal(v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}))
+				// Every GPU remains allocatable after overhead
+				Expect(resources.Fits(resources.Merge(
+					v1.ResourceList{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("4")},
+					instanceType.Overhead(),
+				), instanceType.Resources())).To(BeTrue())
+			})
+		})
+		Context("DaemonSet Overhead", func() {
+			It("should reserve the AMI family's DaemonSet baseline", func() {
+				al2 := ExpectInstanceType(provider, "m5.large").Overhead()
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				bottlerocket := ExpectInstanceType(provider, "m5.large").Overhead()
+				for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+					expected := al2[name]
+					expected.Add(AMIFamilyDaemonSetOverhead[v1alpha1.AMIFamilyBottlerocket][name])
+					Expect(expected.Cmp(bottlerocket[name])).To(Equal(0), string(name))
+				}
+			})
+			It("should replace the AMI family's baseline with the provider's DaemonSet overhead", func() {
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				provider.DaemonSetOverhead = v1.ResourceList{v1.ResourceCPU: resource.MustParse("0")}
+				overhead := ExpectInstanceType(provider, "m5.large").Overhead()
+				provider.DaemonSetOverhead = v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}
+				reserved := ExpectInstanceType(provider, "m5.large").Overhead()
+				cpu := reserved[v1.ResourceCPU]
+				Expect(cpu.Cmp(overhead[v1.ResourceCPU])).To(Equal(0))
+				expected := overhead[v1.ResourceMemory]
+				expected.Add(resource.MustParse("1Gi"))
+				Expect(expected.Cmp(reserved[v1.ResourceMemory])).To(Equal(0))
+			})
+			It("should reserve the provider's DaemonSet overhead for AMI families without a baseline", func() {
+				al2 := ExpectInstanceType(provider, "m5.large").Overhead()
+				provider.DaemonSetOverhead = v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")}
+				overhead := ExpectInstanceType(provider, "m5.large").Overhead()
+				expected := al2[v1.ResourceCPU]
+				expected.Add(resource.MustParse("250m"))
+				Expect(expected.Cmp(overhead[v1.ResourceCPU])).To(Equal(0))
+			})
+			It("should be replaced by instance type overrides", func() {
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{
+					"m5.large": {Memory: resource.NewQuantity(1<<30, resource.BinarySI)},
+				}
+				memory := ExpectInstanceType(provider, "m5.large").Overhead()[v1.ResourceMemory]
+				Expect(memory.Cmp(resource.MustParse("1Gi"))).To(Equal(0))
+			})
+		})
+		Context("Reserved CPUs", func() {
+			allocatableCPU := func(instanceType cloudprovider.InstanceType) resource.Quantity {
+				cpu := instanceType.Resources()[v1.ResourceCPU]
+				cpu.Sub(instanceType.Overhead()[v1.ResourceCPU])
+				return cpu
+			}
+			It("should reduce allocatable CPU by the reserved CPUs", func() {
+				allocatable := allocatableCPU(ExpectInstanceType(provider, "m5.xlarge"))
+				provider.ReservedCPUs = aws.Int64(2)
+				reserved := allocatableCPU(ExpectInstanceType(provider, "m5.xlarge"))
+				reserved.Add(resource.MustParse("2"))
+				Expect(reserved.Cmp(allocatable)).To(Equal(0))
+			})
+			It("should reserve CPUs in addition to instance type overrides", func() {
+				provider.ReservedCPUs = aws.Int64(1)
+				provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{
+					"m5.xlarge": {CPU: resource.NewMilliQuantity(500, resource.DecimalSI)},
+				}
+				cpu := ExpectInstanceType(provider, "m5.xlarge").Overhead()[v1.ResourceCPU]
+				Expect(cpu.Cmp(resource.MustParse("1500m"))).To(Equal(0))
+			})
+			It("should exclude instance types without vCPUs left over", func() {
+				provider.ReservedCPUs = aws.Int64(2)
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.HasAny("t3.large", "m5.large", "c6g.large")).To(BeFalse())
+				Expect(names.Has("m5.xlarge")).To(BeTrue())
+			})
+		})
+		Context("MacOS", func() {
+			var mac1, mac2 *ec2.InstanceTypeInfo
+			BeforeEach(func() {
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyMacOS)
+				provider.DedicatedHosts = aws.Bool(true)
+				info := *ExpectInstanceType(&v1alpha1.AWS{}, "m5.metal").InstanceTypeInfo
+				info.InstanceType = aws.String("mac1.metal")
+				info.SupportedUsageClasses = aws.StringSlice([]string{"host"})
+				info.ProcessorInfo = &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"x86_64_mac"})}
+				mac1 = &info
+				arm := info
+				arm.InstanceType = aws.String("mac2.metal")
+				arm.ProcessorInfo = &ec2.ProcessorInfo{SupportedArchitectures: aws.StringSlice([]string{"arm64_mac"})}
+				mac2 = &arm
+			})
+			It("should advertise the macos operating system for mac instance types", func() {
+				Expect(NewTestInstanceType(provider, mac1).Requirements().Get(v1.LabelOSStable).Values().List()).To(ConsistOf(v1alpha5.OperatingSystemMacOS))
+				Expect(NewTestInstanceType(provider, mac2).Requirements().Get(v1.LabelOSStable).Values().List()).To(ConsistOf(v1alpha5.OperatingSystemMacOS))
+			})
+			It("should discover mac instance types by their architectures", func() {
+				fakeEC2API.DescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{InstanceTypes: []*ec2.InstanceTypeInfo{mac1, mac2}}
+				fakeEC2API.DescribeInstanceTypeOfferingsOutput = &ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					{InstanceType: aws.String("mac1.metal"), Location: aws.String("test-zone-1a")},
+					{InstanceType: aws.String("mac2.metal"), Location: aws.String("test-zone-1a")},
+				}}
+				instanceTypeCache.Flush()
+				Expect(ExpectInstanceTypeNames(provider).List()).To(ConsistOf("mac1.metal", "mac2.metal"))
+				Expect(ExpectInstanceType(provider, "mac1.metal").Requirements().Get(v1.LabelArchStable).Values().List()).To(ConsistOf(v1alpha5.ArchitectureAmd64))
+				Expect(ExpectInstanceType(provider, "mac2.metal").Requirements().Get(v1.LabelArchStable).Values().List()).To(ConsistOf(v1alpha5.ArchitectureArm64))
+			})
+			It("should advertise the linux operating system for other instance types", func() {
+				provider.AMIFamily = nil
+				Expect(ExpectInstanceType(provider, "m5.metal").Requirements().Get(v1.LabelOSStable).Values().List()).To(ConsistOf(v1alpha5.OperatingSystemLinux))
+			})
+			It("should only include mac instance types with the MacOS AMI family", func() {
+				instanceTypeProvider := cloudProvider.(*CloudProvider).instanceTypeProvider
+				Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, mac1), provider)).To(BeTrue())
+				Expect(ExpectInstanceTypeNames(provider)).To(BeEmpty())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyAL2)
+				Expect(instanceTypeProvider.filterByProvider(NewTestInstanceType(provider, mac1), provider)).To(BeFalse())
+			})
+			It("should not limit pods by ENIs", func() {
+				Expect(NewTestInstanceType(provider, mac1).Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("110")))
+				provider.MaxPodsPerInstanceType = map[string]int32{"mac1.metal": 50}
+				Expect(NewTestInstanceType(provider, mac1).Resources()[v1.ResourcePods]).To(Equal(resource.MustParse("50")))
+			})
+			It("should reserve kubelet memory for the pod density", func() {
+				expected := kubeReservedMemory(110)
+				memory := NewTestInstanceType(provider, mac1).Overhead()[v1.ResourceMemory]
+				Expect(memory.Cmp(expected)).To(BeNumerically(">=", 0))
+			})
+			It("should resolve macOS AMIs by architecture", func() {
+				macOS := amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{})
+				Expect(macOS.SSMAlias("1.22", NewTestInstanceType(provider, mac1))).To(Equal("/aws/service/ec2-macos/sonoma/x86_64_mac/latest/image_id"))
+				Expect(macOS.SSMAlias("1.22", NewTestInstanceType(provider, mac2))).To(Equal("/aws/service/ec2-macos/sonoma/arm64_mac/latest/image_id"))
+			})
+			It("should pass custom user data through unchanged", func() {
+				macOS := amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{ClusterName: "test-cluster"})
+				script, err := macOS.UserData(nil, nil, nil, nil, nil, aws.String("#!/bin/bash\necho hello")).Script()
+				Expect(err).ToNot(HaveOccurred())
+				userData, err := base64.StdEncoding.DecodeString(script)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(userData)).To(Equal("#!/bin/bash\necho hello"))
+			})
+			It("should use a root volume large enough for macOS by default", func() {
+				mappings := amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{}).DefaultBlockDeviceMappings()
+				Expect(mappings).To(HaveLen(1))
+				Expect(aws.StringValue(mappings[0].DeviceName)).To(Equal("/dev/sda1"))
+				Expect(mappings[0].EBS.VolumeSize.Cmp(resource.MustParse("100G"))).To(BeNumerically(">", 0))
+			})
+		})
+		Context("HugePages", func() {
+			It("should not advertise huge pages by default", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Resources()).ToNot(HaveKey(v1alpha1.ResourceHugePages2Mi))
+				Expect(instanceType.Resources()).ToNot(HaveKey(v1alpha1.ResourceHugePages1Gi))
+			})
+			It("should advertise 2Mi huge pages and deduct them from memory", func() {
+				memory := ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceMemory]
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages2Mi: resource.MustParse("1Gi")}
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Resources()[v1alpha1.ResourceHugePages2Mi]).To(Equal(resource.MustParse("1Gi")))
+				memory.Sub(resource.MustParse("1Gi"))
+				Expect(memory.Cmp(instanceType.Resources()[v1.ResourceMemory])).To(Equal(0))
+			})
+			It("should advertise 1Gi huge pages and deduct them from memory", func() {
+				memory := ExpectInstanceType(provider, "m5.xlarge").Resources()[v1.ResourceMemory]
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages1Gi: resource.MustParse("4Gi")}
+				instanceType := ExpectInstanceType(provider, "m5.xlarge")
+				Expect(instanceType.Resources()[v1alpha1.ResourceHugePages1Gi]).To(Equal(resource.MustParse("4Gi")))
+				memory.Sub(resource.MustParse("4Gi"))
+				Expect(memory.Cmp(instanceType.Resources()[v1.ResourceMemory])).To(Equal(0))
+			})
+			It("should deduct huge pages of both sizes from memory", func() {
+				memory := ExpectInstanceType(provider, "m5.xlarge").Resources()[v1.ResourceMemory]
+				provider.HugePages = v1.ResourceList{
+					v1alpha1.ResourceHugePages2Mi: resource.MustParse("512Mi"),
+					v1alpha1.ResourceHugePages1Gi: resource.MustParse("2Gi"),
+				}
+				memory.Sub(resource.MustParse("2560Mi"))
+				Expect(memory.Cmp(ExpectInstanceType(provider, "m5.xlarge").Resources()[v1.ResourceMemory])).To(Equal(0))
+			})
+			It("should exclude instance types without enough memory for huge pages", func() {
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages1Gi: resource.MustParse("8Gi")}
+				names := ExpectInstanceTypeNames(provider)
+				Expect(names.Has("m5.large")).To(BeFalse())
+				Expect(names.Has("m5.xlarge")).To(BeTrue())
+			})
+			It("should launch instances for huge page resource requests", func() {
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages2Mi: resource.MustParse("1Gi")}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				requests := v1.ResourceList{
+					v1.ResourceMemory:             resource.MustParse("1Gi"),
+					v1alpha1.ResourceHugePages2Mi: resource.MustParse("512Mi"),
+				}
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: requests, Limits: requests}}),
+				)[0]
+				ExpectScheduled(ctx, env.Client, pod)
+			})
+		})
+		Context("Burstable vCPU Baseline", func() {
+			It("should advertise every vCPU of burstable instance types by default", func() {
+				cpu := ExpectInstanceType(provider, "t3.large").Resources()[v1.ResourceCPU]
+				Expect(cpu.Cmp(resource.MustParse("2"))).To(Equal(0))
+			})
+			It("should limit burstable instance types to a baseline of 0.2 vCPU", func() {
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(200, resource.DecimalSI)
+				info := *ExpectInstanceType(provider, "t3.large").InstanceTypeInfo
+				info.InstanceType = aws.String("t3.small")
+				info.VCpuInfo = &ec2.VCpuInfo{DefaultVCpus: aws.Int64(1), DefaultCores: aws.Int64(1)}
+				cpu := NewTestInstanceType(provider, &info).Resources()[v1.ResourceCPU]
+				Expect(cpu.String()).To(Equal("200m"))
+				cpu = ExpectInstanceType(provider, "t3.large").Resources()[v1.ResourceCPU]
+				Expect(cpu.String()).To(Equal("400m"))
+			})
+			It("should not limit instance types that aren't burstable", func() {
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(200, resource.DecimalSI)
+				cpu := ExpectInstanceType(provider, "m5.large").Resources()[v1.ResourceCPU]
+				Expect(cpu.Cmp(resource.MustParse("2"))).To(Equal(0))
+			})
+			It("should compute overhead from every vCPU", func() {
+				overhead := ExpectInstanceType(provider, "t3.large").Overhead()[v1.ResourceCPU]
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(200, resource.DecimalSI)
+				Expect(overhead.Cmp(ExpectInstanceType(provider, "t3.large").Overhead()[v1.ResourceCPU])).To(Equal(0))
+			})
+		})
+		Context("Extra Resources", func() {
+			It("should advertise a smarter-devices/fuse device for each pod by default", func() {
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Resources()[v1alpha1.ResourceSmarterDevicesFuse]).To(Equal(instanceType.Resources()[v1.ResourcePods]))
+			})
+			It("should pack pods requesting a fuse device as densely as other pods by default", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				requests := v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("1"), v1.ResourceCPU: resource.MustParse("10m")}
+				pods := ExpectProvisioned(ctx, env.Client, controller, lo.Times(5, func(_ int) *v1.Pod {
+					return test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: requests, Limits: requests}})
+				})...)
+				nodes := sets.NewString()
+				for _, pod := range pods {
+					nodes.Insert(ExpectScheduled(ctx, env.Client, pod).Name)
+				}
+				Expect(nodes.Len()).To(Equal(1))
+			})
+			It("should limit the pods packed onto a node by configured extra resources", func() {
+				provider.ExtraResources = v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("1")}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				requests := v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("1"), v1.ResourceCPU: resource.MustParse("10m")}
+				pods := ExpectProvisioned(ctx, env.Client, controller, lo.Times(2, func(_ int) *v1.Pod {
+					return test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: requests, Limits: requests}})
+				})...)
+				Expect(ExpectScheduled(ctx, env.Client, pods[0]).Name).ToNot(Equal(ExpectScheduled(ctx, env.Client, pods[1]).Name))
+			})
+			It("should advertise configured extra resources instead of the default", func() {
+				provider.ExtraResources = v1.ResourceList{"squat.ai/fuse": resource.MustParse("10"), "example.com/device": resource.MustParse("2")}
+				instanceType := ExpectInstanceType(provider, "m5.large")
+				Expect(instanceType.Resources()["squat.ai/fuse"]).To(Equal(resource.MustParse("10")))
+				Expect(instanceType.Resources()["example.com/device"]).To(Equal(resource.MustParse("2")))
+				Expect(instanceType.Resources()).ToNot(HaveKey(v1alpha1.ResourceSmarterDevicesFuse))
+			})
+			It("should not advertise extra resources with a quantity of zero", func() {
+				provider.ExtraResources = v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("0")}
+				Expect(ExpectInstanceType(provider, "m5.large").Resources()).ToNot(HaveKey(v1alpha1.ResourceSmarterDevicesFuse))
+			})
+			It("should launch instances for extra resource requests", func() {
+				provider.ExtraResources = v1.ResourceList{"squat.ai/fuse": resource.MustParse("10")}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				requests := v1.ResourceList{"squat.ai/fuse": resource.MustParse("1")}
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: requests, Limits: requests}}),
+				)[0]
+				ExpectScheduled(ctx, env.Client, pod)
+			})
+			It("should not launch instances for unadvertised extra resources", func() {
+				provider.ExtraResources = v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("0")}
+				ExpectApplied(ctx, env.Client, test.Provisioner(test.ProvisionerOptions{Provider: provider}))
+				requests := v1.ResourceList{v1alpha1.ResourceSmarterDevicesFuse: resource.MustParse("1")}
+				pod := ExpectProvisioned(ctx, env.Client, controller,
+					test.UnschedulablePod(test.PodOptions{ResourceRequirements: v1.ResourceRequirements{Requests: requests, Limits: requests}}),
+				)[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+		})
+	})
+	Context("Pricing", func() {
+		var fakeClock *clock.FakeClock
+		var priceProvider *PriceProvider
+		spotPrices := func(price string) *ec2.DescribeSpotPriceHistoryOutput {
+			return &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{{
+				AvailabilityZone: aws.String("test-zone-1a"),
+				InstanceType:     aws.String("m5.large"),
+				SpotPrice:        aws.String(price),
+			}}}
+		}
+		BeforeEach(func() {
+			fakeClock = clock.NewFakeClock(time.Now())
+			priceProvider = NewPriceProvider(fakeEC2API, "", time.Hour, 6*time.Hour)
+			priceProvider.clock = fakeClock
+			cloudProvider.(*CloudProvider).instanceTypeProvider.priceProvider = priceProvider
+		})
+		It("should serve cached prices until the refresh interval has passed", func() {
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+			price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.04))
+
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.05")
+			fakeClock.Step(30 * time.Minute)
+			price, _ = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(price).To(Equal(0.04))
+
+			fakeClock.Step(30 * time.Minute)
+			price, _ = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(price).To(Equal(0.05))
+		})
+		It("should only keep the prices of zones in the region", func() {
+			priceProvider = NewPriceProvider(fakeEC2API, "us-west-2", time.Hour, 6*time.Hour)
+			fakeEC2API.DescribeSpotPriceHistoryOutput = &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{
+				{AvailabilityZone: aws.String("us-west-2a"), InstanceType: aws.String("m5.large"), SpotPrice: aws.String("0.04")},
+				{AvailabilityZone: aws.String("us-east-1a"), InstanceType: aws.String("m5.large"), SpotPrice: aws.String("0.03")},
+			}}
+			price, ok := priceProvider.SpotPrice(ctx, "m5.large", "us-west-2a")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.04))
+			_, ok = priceProvider.SpotPrice(ctx, "m5.large", "us-east-1a")
+			Expect(ok).To(BeFalse())
+		})
+		It("should keep the most recent price across product descriptions", func() {
+			now := fakeClock.Now()
+			fakeEC2API.DescribeSpotPriceHistoryOutput = &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{
+				{AvailabilityZone: aws.String("test-zone-1a"), InstanceType: aws.String("m5.large"), ProductDescription: aws.String("Linux/UNIX (Amazon VPC)"), SpotPrice: aws.String("0.05"), Timestamp: aws.Time(now)},
+				{AvailabilityZone: aws.String("test-zone-1a"), InstanceType: aws.String("m5.large"), ProductDescription: aws.String("Linux/UNIX"), SpotPrice: aws.String("0.04"), Timestamp: aws.Time(now.Add(-time.Hour))},
+				{AvailabilityZone: aws.String("test-zone-1b"), InstanceType: aws.String("m5.large"), ProductDescription: aws.String("Linux/UNIX (Amazon VPC)"), SpotPrice: aws.String("0.05"), Timestamp: aws.Time(now.Add(-time.Hour))},
+				{AvailabilityZone: aws.String("test-zone-1b"), InstanceType: aws.String("m5.large"), ProductDescription: aws.String("Linux/UNIX"), SpotPrice: aws.String("0.04"), Timestamp: aws.Time(now)},
+			}}
+			price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.05))
+			price, ok = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1b")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.04))
+		})
+		It("should not return a price for unknown instance types or zones", func() {
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+			_, ok := priceProvider.SpotPrice(ctx, "m5.xlarge", "test-zone-1a")
+			Expect(ok).To(BeFalse())
+			_, ok = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1b")
+			Expect(ok).To(BeFalse())
+		})
+		It("should serve stale prices if a refresh fails", func() {
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+			_, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeTrue())
+
+			stale := testutil.ToFloat64(stalePricesServedCounter)
+			fakeEC2API.DescribeSpotPriceHistoryErr = fmt.Errorf("api unavailable")
+			fakeClock.Step(2 * time.Hour)
+			price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.04))
+			Expect(testutil.ToFloat64(stalePricesServedCounter)).To(Equal(stale + 1))
+			Expect(testutil.ToFloat64(priceLastRefreshAgeGauge)).To(BeNumerically(">=", (2 * time.Hour).Seconds()))
+
+			// still stale until the refresh is retried
+			price, _ = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(price).To(Equal(0.04))
+			Expect(testutil.ToFloat64(stalePricesServedCounter)).To(Equal(stale + 2))
+
+			fakeEC2API.DescribeSpotPriceHistoryErr = nil
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.05")
+			fakeClock.Step(PriceRefreshRetryInterval)
+			price, _ = priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(price).To(Equal(0.05))
+			Expect(testutil.ToFloat64(stalePricesServedCounter)).To(Equal(stale + 2))
+		})
+		It("should not return a price if the first refresh fails", func() {
+			fakeEC2API.DescribeSpotPriceHistoryErr = fmt.Errorf("api unavailable")
+			_, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeFalse())
+		})
+		It("should keep the previous prices if a price can't be parsed", func() {
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+			priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("not-a-price")
+			fakeClock.Step(time.Hour)
+			price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+			Expect(ok).To(BeTrue())
+			Expect(price).To(Equal(0.04))
+		})
+		It("should price spot offerings at their cached spot price", func() {
+			fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+			provider.SpotDiscountPercentage = aws.Int64(20)
+			instanceType := ExpectInstanceType(provider, "m5.large")
+			Expect(instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1a"})).To(Equal(0.04))
+			// spot offerings without a cached price are discounted instead
+			Expect(instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1b"})).To(BeNumerically("~", instanceType.Price()*0.8))
+			Expect(instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeOnDemand, Zone: "test-zone-1a"})).To(Equal(instanceType.Price()))
+			offering, ok := instanceType.CheapestOffering()
+			Expect(ok).To(BeTrue())
+			Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot}))
+		})
+		Context("Max Age", func() {
+			BeforeEach(func() {
+				fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.04")
+				_, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeTrue())
+			})
+			It("should serve fresh prices", func() {
+				expired := testutil.ToFloat64(expiredPricesCounter)
+				fakeClock.Step(5 * time.Hour)
+				price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeTrue())
+				Expect(price).To(Equal(0.04))
+				Expect(testutil.ToFloat64(expiredPricesCounter)).To(Equal(expired))
+			})
+			It("should serve stale prices younger than the max age", func() {
+				expired := testutil.ToFloat64(expiredPricesCounter)
+				fakeEC2API.DescribeSpotPriceHistoryErr = fmt.Errorf("api unavailable")
+				fakeClock.Step(6 * time.Hour)
+				price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeTrue())
+				Expect(price).To(Equal(0.04))
+				Expect(testutil.ToFloat64(expiredPricesCounter)).To(Equal(expired))
+			})
+			It("should not serve stale prices older than the max age", func() {
+				expired := testutil.ToFloat64(expiredPricesCounter)
+				fakeEC2API.DescribeSpotPriceHistoryErr = fmt.Errorf("api unavailable")
+				fakeClock.Step(6*time.Hour + time.Minute)
+				_, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeFalse())
+				Expect(testutil.ToFloat64(expiredPricesCounter)).To(Equal(expired + 1))
+
+				// prices are served again once they're refreshed
+				fakeEC2API.DescribeSpotPriceHistoryErr = nil
+				fakeEC2API.DescribeSpotPriceHistoryOutput = spotPrices("0.05")
+				fakeClock.Step(PriceRefreshRetryInterval)
+				price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeTrue())
+				Expect(price).To(Equal(0.05))
+			})
+			It("should not serve prices older than the max age that aren't due for a refresh", func() {
+				expired := testutil.ToFloat64(expiredPricesCounter)
+				priceProvider.refreshInterval = 12 * time.Hour
+				fakeClock.Step(6*time.Hour + time.Minute)
+				_, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeFalse())
+				Expect(testutil.ToFloat64(expiredPricesCounter)).To(Equal(expired + 1))
+			})
+			DescribeTable("should price spot offerings by the age of the cached spot prices",
+				func(age time.Duration, refreshErr error, expired bool) {
+					provider.SpotDiscountPercentage = aws.Int64(20)
+					fakeEC2API.DescribeSpotPriceHistoryErr = refreshErr
+					fakeClock.Step(age)
+					instanceType := ExpectInstanceType(provider, "m5.large")
+					spot := instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1a"})
+					discounted := instanceType.OfferingPrice(cloudprovider.Offering{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1b"})
+					offering, ok := instanceType.CheapestOffering()
+					Expect(ok).To(BeTrue())
+					if expired {
+						// spot offerings are priced as on-demand, so the first on-demand offering is as cheap as any
+						Expect(spot).To(Equal(instanceType.Price()))
+						Expect(discounted).To(Equal(instanceType.Price()))
+						Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeOnDemand}))
+					} else {
+						Expect(spot).To(Equal(0.04))
+						Expect(discounted).To(BeNumerically("~", instanceType.Price()*0.8))
+						Expect(offering).To(Equal(cloudprovider.Offering{Zone: "test-zone-1a", CapacityType: v1alpha1.CapacityTypeSpot}))
+					}
+				},
+				Entry("at fresh prices", 5*time.Hour, nil, false),
+				Entry("at stale prices younger than the max age", 6*time.Hour, fmt.Errorf("api unavailable"), false),
+				Entry("as on-demand once prices are older than the max age", 6*time.Hour+time.Minute, fmt.Errorf("api unavailable"), true),
+			)
+			It("should always serve stale prices without a max age", func() {
+				priceProvider.maxAge = 0
+				fakeEC2API.DescribeSpotPriceHistoryErr = fmt.Errorf("api unavailable")
+				fakeClock.Step(48 * time.Hour)
+				price, ok := priceProvider.SpotPrice(ctx, "m5.large", "test-zone-1a")
+				Expect(ok).To(BeTrue())
+				Expect(price).To(Equal(0.04))
+			})
+		})
+	})
+	Context("Defaulting", func() {
+		// Intent here is that if updates occur on the controller, the Provisioner doesn't need to be recreated
+		It("should not set the InstanceProfile with the default if none provided in Provisioner", func() {
+			provisioner.SetDefaults(ctx)
+			constraints, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(constraints.InstanceProfile).To(BeNil())
+		})
+
+		It("should default requirements", func() {
+			provisioner.SetDefaults(ctx)
+			Expect(provisioner.Spec.Requirements).To(ContainElement(v1.NodeSelectorRequirement{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha1.CapacityTypeOnDemand},
+			}))
+			Expect(provisioner.Spec.Requirements).To(ContainElement(v1.NodeSelectorRequirement{
+				Key:      v1.LabelArchStable,
+				Operator: v1.NodeSelectorOpIn,
 				Values:   []string{v1alpha5.ArchitectureAmd64},
 			}))
 		})
-	})
-	Context("Validation", func() {
-		It("should validate", func() {
-			Expect(provisioner.Validate(ctx)).To(Succeed())
+	})
+	Context("Validation", func() {
+		It("should validate", func() {
+			Expect(provisioner.Validate(ctx)).To(Succeed())
+		})
+		It("should not panic if provider undefined", func() {
+			provisioner.Spec.Provider = nil
+			Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+		})
+
+		Context("SubnetSelector", func() {
+			It("should not allow empty string keys or values", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				for key, value := range map[string]string{
+					"":    "value",
+					"key": "",
+				} {
+					provider.SubnetSelector = map[string]string{key: value}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("SecurityGroupSelector", func() {
+			It("should not allow with a custom launch template", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.LaunchTemplateName = aws.String("my-lt")
+				provider.SecurityGroupSelector = map[string]string{"key": "value"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow empty string keys or values", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				for key, value := range map[string]string{
+					"":    "value",
+					"key": "",
+				} {
+					provider.SecurityGroupSelector = map[string]string{key: value}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("Labels", func() {
+			It("should not allow unrecognized labels with the aws label prefix", func() {
+				provisioner.Spec.Labels = map[string]string{v1alpha1.LabelDomain + "/" + randomdata.SillyName(): randomdata.SillyName()}
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should support well known labels", func() {
+				for _, label := range []string{
+					v1alpha1.InstanceFamilyLabelKey,
+					v1alpha1.InstanceSizeLabelKey,
+					v1alpha1.InstanceCPULabelKey,
+					v1alpha1.InstanceMemoryLabelKey,
+					v1alpha1.InstanceGPUNameLabelKey,
+					v1alpha1.InstanceGPUManufacturerLabelKey,
+					v1alpha1.InstanceGPUCountLabelKey,
+					v1alpha1.InstanceGPUMemoryLabelKey,
+					v1alpha1.InstanceGPUMemoryTierLabelKey,
+					v1alpha1.InstanceSpotInterruptionRateLabelKey,
+					v1alpha1.InstanceAcceleratorNameLabelKey,
+					v1alpha1.InstanceAcceleratorManufacturerLabelKey,
+				} {
+					provisioner.Spec.Labels = map[string]string{label: randomdata.SillyName()}
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				}
+			})
+		})
+		Context("MetadataOptions", func() {
+			It("should not allow with a custom launch template", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.LaunchTemplateName = aws.String("my-lt")
+				provider.MetadataOptions = &v1alpha1.MetadataOptions{}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should allow missing values", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MetadataOptions = &v1alpha1.MetadataOptions{}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			Context("HTTPEndpoint", func() {
+				It("should allow enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					for _, value := range ec2.LaunchTemplateInstanceMetadataEndpointState_Values() {
+						provider.MetadataOptions = &v1alpha1.MetadataOptions{
+							HTTPEndpoint: &value,
+						}
+						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).To(Succeed())
+					}
+				})
+				It("should not allow non-enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{
+						HTTPEndpoint: aws.String(randomdata.SillyName()),
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+			})
+			Context("HTTPProtocolIpv6", func() {
+				It("should allow enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					for _, value := range ec2.LaunchTemplateInstanceMetadataProtocolIpv6_Values() {
+						provider.MetadataOptions = &v1alpha1.MetadataOptions{
+							HTTPProtocolIPv6: &value,
+						}
+						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).To(Succeed())
+					}
+				})
+				It("should not allow non-enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{
+						HTTPProtocolIPv6: aws.String(randomdata.SillyName()),
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+			})
+			Context("HTTPPutResponseHopLimit", func() {
+				It("should validate inside accepted range", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{
+						HTTPPutResponseHopLimit: aws.Int64(int64(randomdata.Number(1, 65))),
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				})
+				It("should not validate outside accepted range", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{}
+					// We expect to be able to invalidate any hop limit between
+					// [math.MinInt64, 1). But, to avoid a panic here, we can't
+					// exceed math.MaxInt for the difference between bounds of
+					// the random number range. So we divide the range
+					// approximately in half and test on both halves.
+					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(math.MinInt64, math.MinInt64/2)))
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(math.MinInt64/2, 1)))
+					provisioner = test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+
+					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(65, math.MaxInt64)))
+					provisioner = test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+			})
+			Context("HTTPTokens", func() {
+				It("should allow enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					for _, value := range ec2.LaunchTemplateHttpTokensState_Values() {
+						provider.MetadataOptions = &v1alpha1.MetadataOptions{
+							HTTPTokens: aws.String(value),
+						}
+						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).To(Succeed())
+					}
+				})
+				It("should not allow non-enum values", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MetadataOptions = &v1alpha1.MetadataOptions{
+						HTTPTokens: aws.String(randomdata.SillyName()),
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+			})
+			Context("DefaultVolumeSize", func() {
+				It("should allow a default volume size", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.DefaultVolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				})
+				It("should not allow with a custom launch template or block device mappings", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.LaunchTemplateName = aws.String("my-lt")
+					provider.DefaultVolumeSize = resource.NewScaledQuantity(100, resource.Giga)
+					Expect(test.Provisioner(test.ProvisionerOptions{Provider: provider}).Validate(ctx)).ToNot(Succeed())
+					provider.LaunchTemplateName = nil
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS:        &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(50, resource.Giga)},
+					}}
+					Expect(test.Provisioner(test.ProvisionerOptions{Provider: provider}).Validate(ctx)).ToNot(Succeed())
+				})
+				It("should not allow out of bounds volume sizes", func() {
+					for _, size := range []*resource.Quantity{resource.NewScaledQuantity(0, resource.Giga), resource.NewScaledQuantity(65, resource.Tera)} {
+						provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+						Expect(err).ToNot(HaveOccurred())
+						provider.DefaultVolumeSize = size
+						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+					}
+				})
+			})
+			Context("BlockDeviceMappings", func() {
+				It("should not allow with a custom launch template", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.LaunchTemplateName = aws.String("my-lt")
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							VolumeSize: resource.NewScaledQuantity(1, resource.Giga),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+				It("should validate minimal device mapping", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							VolumeSize: resource.NewScaledQuantity(1, resource.Giga),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				})
+				It("should validate device mappings with unique device names", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(20, resource.Giga)}},
+						{DeviceName: aws.String("/dev/xvdb"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(100, resource.Giga)}},
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				})
+				It("should not allow device mappings with duplicate device names", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+						{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(20, resource.Giga)}},
+						{DeviceName: aws.String("/dev/xvdb"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(100, resource.Giga)}},
+						{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(50, resource.Giga)}},
+					}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					err = provisioner.Validate(ctx)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("/dev/xvda is the device name of another block device mapping"))
+					Expect(err.Error()).To(ContainSubstring("blockDeviceMappings[2].deviceName"))
+				})
+				It("should validate ebs device mapping with snapshotID only", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							SnapshotID: aws.String("snap-0123456789"),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				})
+				It("should not allow volume size below minimum", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							VolumeSize: resource.NewScaledQuantity(100, resource.Mega),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+				It("should not allow volume size above max", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS: &v1alpha1.BlockDevice{
+							VolumeSize: resource.NewScaledQuantity(65, resource.Tera),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+				It("should not allow zero or negative volume sizes", func() {
+					for _, volumeSize := range []resource.Quantity{resource.MustParse("0"), resource.MustParse("-20Gi")} {
+						provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+						Expect(err).ToNot(HaveOccurred())
+						provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+							DeviceName: aws.String("/dev/xvda"),
+							EBS:        &v1alpha1.BlockDevice{VolumeSize: &volumeSize, SnapshotID: aws.String("snap-0123456789")},
+						}}
+						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+						Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+					}
+				})
+				It("should not allow nil device name", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						EBS: &v1alpha1.BlockDevice{
+							VolumeSize: resource.NewScaledQuantity(65, resource.Tera),
+						},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+				It("should not allow nil volume size", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+						EBS:        &v1alpha1.BlockDevice{},
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+				It("should not allow empty ebs block", func() {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
+						DeviceName: aws.String("/dev/xvda"),
+					}}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				})
+			})
+		})
+		Context("ExcludedInstanceFamilies", func() {
+			It("should allow instance families", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExcludedInstanceFamilies = []string{"p2", "g3"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty or fully qualified instance types", func() {
+				for _, family := range []string{"", "p2.xlarge"} {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.ExcludedInstanceFamilies = []string{family}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
 		})
-		It("should not panic if provider undefined", func() {
-			provisioner.Spec.Provider = nil
-			Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+		Context("InstanceFamilyPatterns", func() {
+			It("should allow glob patterns", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceFamilyPatterns = []string{"m*", "c7?", "r[56]", "p3"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty, malformed, or fully qualified patterns", func() {
+				for _, pattern := range []string{"", "c[7", "m5.*"} {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.InstanceFamilyPatterns = []string{pattern}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
 		})
-
-		Context("SubnetSelector", func() {
-			It("should not allow empty string keys or values", func() {
+		Context("ArchitectureMappings", func() {
+			It("should allow mapping new architectures", func() {
 				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 				Expect(err).ToNot(HaveOccurred())
-				for key, value := range map[string]string{
-					"":    "value",
-					"key": "",
+				provider.ArchitectureMappings = map[string]string{"riscv64": "riscv64"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty mappings or overriding built-in architectures", func() {
+				for _, mappings := range []map[string]string{
+					{"": v1alpha5.ArchitectureAmd64},
+					{"riscv64": ""},
+					{"x86_64": v1alpha5.ArchitectureArm64},
+					{"arm64_mac": v1alpha5.ArchitectureArm64},
 				} {
-					provider.SubnetSelector = map[string]string{key: value}
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.ArchitectureMappings = mappings
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 				}
 			})
 		})
-		Context("SecurityGroupSelector", func() {
-			It("should not allow with a custom launch template", func() {
+		Context("GPUReplicaFactor", func() {
+			It("should allow positive factors", func() {
 				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 				Expect(err).ToNot(HaveOccurred())
-				provider.LaunchTemplateName = aws.String("my-lt")
-				provider.SecurityGroupSelector = map[string]string{"key": "value"}
+				provider.GPUReplicaFactor = aws.Int64(4)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow factors below 1", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.GPUReplicaFactor = aws.Int64(0)
 				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 			})
-			It("should not allow empty string keys or values", func() {
+		})
+		Context("MIGProfiles", func() {
+			It("should allow MIG profiles", func() {
 				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 				Expect(err).ToNot(HaveOccurred())
-				for key, value := range map[string]string{
-					"":    "value",
-					"key": "",
+				provider.MIGProfiles = map[string]string{"p4d": "1g.5gb", "p5": "3g.40gb"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow malformed profiles or instance types as keys", func() {
+				for _, profiles := range []map[string]string{
+					{"p4d": ""},
+					{"p4d": "8g.80gb"},
+					{"p4d": "mig-1g.5gb"},
+					{"p4d.24xlarge": "1g.5gb"},
+					{"": "1g.5gb"},
 				} {
-					provider.SecurityGroupSelector = map[string]string{key: value}
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.MIGProfiles = profiles
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 				}
 			})
+			It("should not allow MIG devices as extra resources", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExtraResources = v1.ResourceList{"nvidia.com/mig-1g.5gb": resource.MustParse("7")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
 		})
-		Context("Labels", func() {
-			It("should not allow unrecognized labels with the aws label prefix", func() {
-				provisioner.Spec.Labels = map[string]string{v1alpha1.LabelDomain + "/" + randomdata.SillyName(): randomdata.SillyName()}
+		Context("GPUReservedMemory", func() {
+			It("should allow non-negative reservations", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("0"), "a100": resource.MustParse("2Gi")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow negative reservations", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.GPUReservedMemory = map[string]resource.Quantity{"t4": resource.MustParse("-1Gi")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 			})
-			It("should support well known labels", func() {
-				for _, label := range []string{
-					v1alpha1.InstanceFamilyLabelKey,
-					v1alpha1.InstanceSizeLabelKey,
-					v1alpha1.InstanceCPULabelKey,
-					v1alpha1.InstanceMemoryLabelKey,
-					v1alpha1.InstanceGPUNameLabelKey,
-					v1alpha1.InstanceGPUManufacturerLabelKey,
-					v1alpha1.InstanceGPUCountLabelKey,
-					v1alpha1.InstanceGPUMemoryLabelKey,
-				} {
-					provisioner.Spec.Labels = map[string]string{label: randomdata.SillyName()}
-					Expect(provisioner.Validate(ctx)).To(Succeed())
+		})
+		Context("ContainerdDataDevice", func() {
+			It("should allow devices with a block device mapping", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+					{DeviceName: aws.String("/dev/xvda"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(20, resource.Giga)}},
+					{DeviceName: aws.String("/dev/xvdb"), EBS: &v1alpha1.BlockDevice{VolumeSize: resource.NewScaledQuantity(100, resource.Giga)}},
 				}
+				provider.ContainerdDataDevice = aws.String("/dev/xvdb")
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
 			})
-		})
-		Context("MetadataOptions", func() {
-			It("should not allow with a custom launch template", func() {
+			It("should not allow devices without a block device mapping", func() {
 				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 				Expect(err).ToNot(HaveOccurred())
-				provider.LaunchTemplateName = aws.String("my-lt")
-				provider.MetadataOptions = &v1alpha1.MetadataOptions{}
+				provider.ContainerdDataDevice = aws.String("/dev/xvdb")
 				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 			})
-			It("should allow missing values", func() {
+		})
+		Context("PodPIDsLimit", func() {
+			It("should allow positive limits", func() {
 				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 				Expect(err).ToNot(HaveOccurred())
-				provider.MetadataOptions = &v1alpha1.MetadataOptions{}
+				provider.PodPIDsLimit = aws.Int64(4096)
 				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 				Expect(provisioner.Validate(ctx)).To(Succeed())
 			})
-			Context("HTTPEndpoint", func() {
-				It("should allow enum values", func() {
+			It("should not allow limits that aren't positive", func() {
+				for _, limit := range []int64{0, -1} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					for _, value := range ec2.LaunchTemplateInstanceMetadataEndpointState_Values() {
-						provider.MetadataOptions = &v1alpha1.MetadataOptions{
-							HTTPEndpoint: &value,
-						}
-						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-						Expect(provisioner.Validate(ctx)).To(Succeed())
-					}
-				})
-				It("should not allow non-enum values", func() {
+					provider.PodPIDsLimit = aws.Int64(limit)
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("MaxPodsPerInstanceType", func() {
+			It("should allow positive pod densities", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MaxPodsPerInstanceType = map[string]int32{"m5.24xlarge": 250}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow non-positive pod densities or empty instance types", func() {
+				for _, maxPods := range []map[string]int32{{"m5.24xlarge": 0}, {"m5.24xlarge": -1}, {"": 10}} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.MetadataOptions = &v1alpha1.MetadataOptions{
-						HTTPEndpoint: aws.String(randomdata.SillyName()),
-					}
+					provider.MaxPodsPerInstanceType = maxPods
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
+				}
 			})
-			Context("HTTPProtocolIpv6", func() {
-				It("should allow enum values", func() {
+		})
+		Context("InstanceTypeOverrides", func() {
+			It("should allow non-negative overhead and positive pods", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceTypeOverrides = map[string]v1alpha1.InstanceTypeOverride{
+					"m5.large":  {CPU: resource.NewQuantity(0, resource.DecimalSI), Memory: resource.NewQuantity(1<<30, resource.BinarySI), Pods: aws.Int32(20)},
+					"m5.xlarge": {EphemeralStorage: resource.NewScaledQuantity(1, resource.Giga)},
+				}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow negative overhead, non-positive pods, or empty instance types", func() {
+				for _, overrides := range []map[string]v1alpha1.InstanceTypeOverride{
+					{"m5.large": {CPU: resource.NewMilliQuantity(-100, resource.DecimalSI)}},
+					{"m5.large": {Memory: resource.NewQuantity(-1, resource.BinarySI)}},
+					{"m5.large": {EphemeralStorage: resource.NewQuantity(-1, resource.BinarySI)}},
+					{"m5.large": {Pods: aws.Int32(0)}},
+					{"": {Pods: aws.Int32(10)}},
+				} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					for _, value := range ec2.LaunchTemplateInstanceMetadataProtocolIpv6_Values() {
-						provider.MetadataOptions = &v1alpha1.MetadataOptions{
-							HTTPProtocolIPv6: &value,
-						}
-						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-						Expect(provisioner.Validate(ctx)).To(Succeed())
-					}
-				})
-				It("should not allow non-enum values", func() {
+					provider.InstanceTypeOverrides = overrides
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("DaemonSetOverhead", func() {
+			It("should allow non-negative cpu, memory, and ephemeral storage", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.DaemonSetOverhead = v1.ResourceList{
+					v1.ResourceCPU:              resource.MustParse("0"),
+					v1.ResourceMemory:           resource.MustParse("200Mi"),
+					v1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+				}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow negative quantities or other resources", func() {
+				for _, overhead := range []v1.ResourceList{
+					{v1.ResourceCPU: resource.MustParse("-100m")},
+					{v1.ResourceMemory: resource.MustParse("-1Mi")},
+					{v1.ResourcePods: resource.MustParse("1")},
+					{v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1")},
+				} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.MetadataOptions = &v1alpha1.MetadataOptions{
-						HTTPProtocolIPv6: aws.String(randomdata.SillyName()),
-					}
+					provider.DaemonSetOverhead = overhead
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
+				}
+			})
+		})
+		Context("ReservedCPUs", func() {
+			It("should allow non-negative reserved CPUs", func() {
+				for _, reservedCPUs := range []int64{0, 2} {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.ReservedCPUs = aws.Int64(reservedCPUs)
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				}
+			})
+			It("should not allow negative reserved CPUs", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ReservedCPUs = aws.Int64(-1)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("InstanceStorePolicy", func() {
+			It("should allow RAID0", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceStorePolicy = aws.String(v1alpha1.InstanceStorePolicyRAID0)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow unknown policies", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceStorePolicy = aws.String("RAID5")
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("IPFamily", func() {
+			It("should allow supported IP families", func() {
+				for _, ipFamily := range v1alpha1.SupportedIPFamilies {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.IPFamily = aws.String(ipFamily)
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				}
+			})
+			It("should not allow unknown IP families", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.IPFamily = aws.String("dual")
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("Swap", func() {
+			It("should allow positive sizes", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.Swap = resource.NewScaledQuantity(16, resource.Giga)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow sizes that aren't positive", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.Swap = resource.NewQuantity(0, resource.BinarySI)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("BurstableVCPUBaseline", func() {
+			It("should allow fractions of a vCPU", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(200, resource.DecimalSI)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow baselines that aren't positive", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(0, resource.DecimalSI)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow baselines of more than one vCPU", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.BurstableVCPUBaseline = resource.NewMilliQuantity(1500, resource.DecimalSI)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("ExcludedZones", func() {
+			It("should allow zones", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExcludedZones = []string{"us-west-2-lax-1a"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty zones", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExcludedZones = []string{""}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("ZoneTypes", func() {
+			It("should allow supported zone types", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ZoneTypes = v1alpha1.SupportedZoneTypes
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow unsupported zone types", func() {
+				for _, zoneType := range []string{"", "outpost", "LocalZone"} {
+					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+					Expect(err).ToNot(HaveOccurred())
+					provider.ZoneTypes = []string{v1alpha1.ZoneTypeAvailabilityZone, zoneType}
+					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("VirtualizationTypes", func() {
+			It("should allow supported virtualization types", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.VirtualizationTypes = v1alpha1.SupportedVirtualizationTypes
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
 			})
-			Context("HTTPPutResponseHopLimit", func() {
-				It("should validate inside accepted range", func() {
+			It("should not allow unsupported virtualization types", func() {
+				for _, virtualizationType := range []string{"", "pv", "HVM"} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.MetadataOptions = &v1alpha1.MetadataOptions{
-						HTTPPutResponseHopLimit: aws.Int64(int64(randomdata.Number(1, 65))),
-					}
+					provider.VirtualizationTypes = []string{v1alpha1.VirtualizationTypeHVM, virtualizationType}
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-					Expect(provisioner.Validate(ctx)).To(Succeed())
-				})
-				It("should not validate outside accepted range", func() {
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("CapacityReservationSelector", func() {
+			It("should allow tags and ids", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.CapacityReservationSelector = map[string]string{"foo": "bar", "aws-ids": "cr-0123456789abcdef0,cr-test"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty tags", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.CapacityReservationSelector = map[string]string{"foo": ""}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow invalid ids", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.CapacityReservationSelector = map[string]string{"aws-ids": "subnet-test"}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("MaxPrice", func() {
+			It("should allow positive prices", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MaxPrice = aws.Float64(1.5)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow prices that aren't positive", func() {
+				for _, price := range []float64{0, -1} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.MetadataOptions = &v1alpha1.MetadataOptions{}
-					// We expect to be able to invalidate any hop limit between
-					// [math.MinInt64, 1). But, to avoid a panic here, we can't
-					// exceed math.MaxInt for the difference between bounds of
-					// the random number range. So we divide the range
-					// approximately in half and test on both halves.
-					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(math.MinInt64, math.MinInt64/2)))
+					provider.MaxPrice = aws.Float64(price)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(math.MinInt64/2, 1)))
-					provisioner = test.Provisioner(test.ProvisionerOptions{Provider: provider})
-					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-
-					provider.MetadataOptions.HTTPPutResponseHopLimit = aws.Int64(int64(randomdata.Number(65, math.MaxInt64)))
-					provisioner = test.Provisioner(test.ProvisionerOptions{Provider: provider})
-					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
+				}
 			})
-			Context("HTTPTokens", func() {
-				It("should allow enum values", func() {
-					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
-					Expect(err).ToNot(HaveOccurred())
-					for _, value := range ec2.LaunchTemplateHttpTokensState_Values() {
-						provider.MetadataOptions = &v1alpha1.MetadataOptions{
-							HTTPTokens: aws.String(value),
-						}
-						provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-						Expect(provisioner.Validate(ctx)).To(Succeed())
-					}
-				})
-				It("should not allow non-enum values", func() {
+		})
+		Context("MaxPricePerPod", func() {
+			It("should allow positive prices", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MaxPricePerPod = aws.Float64(0.5)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow prices that aren't positive", func() {
+				for _, price := range []float64{0, -1} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.MetadataOptions = &v1alpha1.MetadataOptions{
-						HTTPTokens: aws.String(randomdata.SillyName()),
-					}
+					provider.MaxPricePerPod = aws.Float64(price)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
+				}
 			})
-			Context("BlockDeviceMappings", func() {
-				It("should not allow with a custom launch template", func() {
+		})
+		Context("ScoringProfile", func() {
+			It("should allow named profiles", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ScoringProfile = aws.String("batch")
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow empty profiles", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ScoringProfile = aws.String("")
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("MinMemoryPerVCPU", func() {
+			It("should allow positive ratios", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MinMemoryPerVCPU = aws.Float64(7.5)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow ratios that aren't positive", func() {
+				for _, ratio := range []float64{0, -2} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.LaunchTemplateName = aws.String("my-lt")
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							VolumeSize: resource.NewScaledQuantity(1, resource.Giga),
-						},
-					}}
+					provider.MinMemoryPerVCPU = aws.Float64(ratio)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
-				It("should validate minimal device mapping", func() {
+				}
+			})
+		})
+		Context("ImageGCReservationPercentage", func() {
+			It("should allow percentages from 0 to 99", func() {
+				for _, percentage := range []int64{0, 15, 99} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							VolumeSize: resource.NewScaledQuantity(1, resource.Giga),
-						},
-					}}
+					provider.ImageGCReservationPercentage = aws.Int64(percentage)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).To(Succeed())
-				})
-				It("should validate ebs device mapping with snapshotID only", func() {
+				}
+			})
+			It("should not allow percentages outside of 0 to 99", func() {
+				for _, percentage := range []int64{-1, 100} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							SnapshotID: aws.String("snap-0123456789"),
-						},
-					}}
+					provider.ImageGCReservationPercentage = aws.Int64(percentage)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-					Expect(provisioner.Validate(ctx)).To(Succeed())
-				})
-				It("should not allow volume size below minimum", func() {
+					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+				}
+			})
+		})
+		Context("MinVCPU", func() {
+			It("should allow positive counts", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MinVCPU = aws.Int64(2)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow counts that aren't positive", func() {
+				for _, count := range []int64{0, -2} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							VolumeSize: resource.NewScaledQuantity(100, resource.Mega),
-						},
-					}}
+					provider.MinVCPU = aws.Int64(count)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
-				It("should not allow volume size above max", func() {
+				}
+			})
+		})
+		Context("MinGPUs", func() {
+			It("should allow positive counts", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.MinGPUs = aws.Int64(2)
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow counts that aren't positive", func() {
+				for _, count := range []int64{0, -2} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS: &v1alpha1.BlockDevice{
-							VolumeSize: resource.NewScaledQuantity(65, resource.Tera),
-						},
-					}}
+					provider.MinGPUs = aws.Int64(count)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
-				It("should not allow nil device name", func() {
+				}
+			})
+		})
+		Context("SpotDiscountPercentage", func() {
+			It("should allow percentages from 0 to 99", func() {
+				for _, discount := range []int64{0, 50, 99} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						EBS: &v1alpha1.BlockDevice{
-							VolumeSize: resource.NewScaledQuantity(65, resource.Tera),
-						},
-					}}
+					provider.SpotDiscountPercentage = aws.Int64(discount)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
-					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
-				It("should not allow nil volume size", func() {
+					Expect(provisioner.Validate(ctx)).To(Succeed())
+				}
+			})
+			It("should not allow percentages outside of 0 to 99", func() {
+				for _, discount := range []int64{-1, 100} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-						EBS:        &v1alpha1.BlockDevice{},
-					}}
+					provider.SpotDiscountPercentage = aws.Int64(discount)
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
-				It("should not allow empty ebs block", func() {
+				}
+			})
+		})
+		Context("InstanceFamilyPricePercentages", func() {
+			It("should allow positive percentages", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceFamilyPricePercentages = map[string]int64{"m5": 60, "c5": 150}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow non-positive percentages", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceFamilyPricePercentages = map[string]int64{"m5": 0}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow instance types as keys", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.InstanceFamilyPricePercentages = map[string]int64{"m5.large": 60}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("HugePages", func() {
+			It("should allow whole pages of supported sizes", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.HugePages = v1.ResourceList{
+					v1alpha1.ResourceHugePages2Mi: resource.MustParse("512Mi"),
+					v1alpha1.ResourceHugePages1Gi: resource.MustParse("2Gi"),
+				}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow unsupported page sizes", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.HugePages = v1.ResourceList{v1.ResourceHugePagesPrefix + "16Gi": resource.MustParse("16Gi")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow partial pages", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages1Gi: resource.MustParse("1536Mi")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+			It("should not allow negative values", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.HugePages = v1.ResourceList{v1alpha1.ResourceHugePages2Mi: resource.MustParse("-2Mi")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
+			})
+		})
+		Context("ExtraResources", func() {
+			It("should allow extended resources", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExtraResources = v1.ResourceList{"squat.ai/fuse": resource.MustParse("10")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).To(Succeed())
+			})
+			It("should not allow resources computed by karpenter", func() {
+				for _, name := range []v1.ResourceName{v1.ResourceCPU, v1alpha1.ResourceNVIDIAGPU, v1alpha1.ResourceHugePages2Mi} {
 					provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
 					Expect(err).ToNot(HaveOccurred())
-					provider.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{
-						DeviceName: aws.String("/dev/xvda"),
-					}}
+					provider.ExtraResources = v1.ResourceList{name: resource.MustParse("1")}
 					provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
 					Expect(provisioner.Validate(ctx)).ToNot(Succeed())
-				})
+				}
+			})
+			It("should not allow negative values", func() {
+				provider, err := v1alpha1.Deserialize(provisioner.Spec.Provider)
+				Expect(err).ToNot(HaveOccurred())
+				provider.ExtraResources = v1.ResourceList{"squat.ai/fuse": resource.MustParse("-1")}
+				provisioner := test.Provisioner(test.ProvisionerOptions{Provider: provider})
+				Expect(provisioner.Validate(ctx)).ToNot(Succeed())
 			})
 		})
 	})
@@ -1558,3 +5445,67 @@ func ExpectTags(tags []*ec2.Tag, expected map[string]string) {
 		Expect(foundValue).To(Equal(expValue))
 	}
 }
+
+// ExpectInstanceType returns the named instance type as resolved for the provider
+func ExpectInstanceType(provider *v1alpha1.AWS, name string) *InstanceType {
+	instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}).Spec.Provider)
+	Expect(err).ToNot(HaveOccurred())
+	for _, instanceType := range instanceTypes {
+		if instanceType.Name() == name {
+			return instanceType.(*InstanceType)
+		}
+	}
+	Fail(fmt.Sprintf("expected to find instance type %s", name))
+	return nil
+}
+
+// ExpectInstanceTypes returns all instance types resolved for the provider
+func ExpectInstanceTypes(provider *v1alpha1.AWS) []cloudprovider.InstanceType {
+	instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}).Spec.Provider)
+	Expect(err).ToNot(HaveOccurred())
+	return instanceTypes
+}
+
+// ExpectInstanceTypeNames returns the names of all instance types resolved for the provider
+func ExpectInstanceTypeNames(provider *v1alpha1.AWS) sets.String {
+	return sets.NewString(InstanceTypeNames(ExpectInstanceTypes(provider))...)
+}
+
+// InstanceTypeNames returns the names of the instance types, in order
+func InstanceTypeNames[T cloudprovider.InstanceType](instanceTypes []T) []string {
+	return lo.Map(instanceTypes, func(instanceType T, _ int) string { return instanceType.Name() })
+}
+
+type stubOverheadCalculator struct {
+	overhead v1.ResourceList
+}
+
+func (s stubOverheadCalculator) Overhead(*InstanceType, amifamily.AMIFamily) v1.ResourceList {
+	return s.overhead
+}
+
+type scorerFunc func(*InstanceType, cloudprovider.Offering) float64
+
+func (s scorerFunc) Score(instanceType *InstanceType, offering cloudprovider.Offering) float64 {
+	return s(instanceType, offering)
+}
+
+// NewTestInstanceType constructs an instance type from info, as if EC2 offered it in every test zone
+func NewTestInstanceType(provider *v1alpha1.AWS, info *ec2.InstanceTypeInfo) *InstanceType {
+	zones := sets.NewString("test-zone-1a", "test-zone-1b", "test-zone-1c")
+	return ExpectNewInstanceType(ctx, info, provider, zones, zones, nil)
+}
+
+// ExpectNewInstanceType constructs an instance type, hashing the provider with the options in ctx as Get does
+func ExpectNewInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, offeredZones sets.String, subnetZones sets.String,
+	capacityReservations []*ec2.CapacityReservation) *InstanceType {
+	providerHash, err := hashProvider(provider, injection.GetOptions(ctx).AWSEnablePodENI, injection.GetOptions(ctx).AWSENILimitedPodDensity)
+	Expect(err).ToNot(HaveOccurred())
+	return cloudProvider.(*CloudProvider).instanceTypeProvider.newInstanceType(ctx, info, provider, providerHash, offeredZones, subnetZones, capacityReservations)
+}
+
+func ExpectHash(instanceType *InstanceType) uint64 {
+	hash, err := instanceType.Hash()
+	Expect(err).ToNot(HaveOccurred())
+	return hash
+}
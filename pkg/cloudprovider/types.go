@@ -79,9 +79,44 @@ type InstanceType interface {
 	Price() float64
 }
 
+// AllocatableRecorder is optionally implemented by instance types that refine their resource estimates from the
+// allocatable resources reported by kubelet once a node is initialized
+type AllocatableRecorder interface {
+	RecordAllocatable(allocatable v1.ResourceList)
+}
+
+// Preloader is optionally implemented by cloud providers that prepare data at startup, e.g. instance types, so that the
+// controller isn't ready until the first provisioning decision can use it
+type Preloader interface {
+	// Preloaded returns a channel that's closed once the cloud provider is done preloading
+	Preloaded() <-chan struct{}
+}
+
+// Scored is optionally implemented by instance types that are ranked for selection by something other than Price(),
+// where lower scores are preferred
+type Scored interface {
+	Score() float64
+}
+
 // An Offering describes where an InstanceType is available to be used, with the expectation that its properties
 // may be tightly coupled (e.g. the availability of an instance type in some zone is scoped to a capacity type)
 type Offering struct {
 	CapacityType string
 	Zone         string
+	// CapacityReservationID is set if the offering launches into a capacity reservation, which is preferred over
+	// equivalent offerings without a reservation
+	CapacityReservationID string
+	// CarbonIntensity is the carbon intensity of the electricity grid that powers the offering's zone, in grams of CO2
+	// equivalent per kWh. It's zero if the cloud provider doesn't know the carbon intensity of the zone.
+	CarbonIntensity float64
+}
+
+// Requirements describes the single zone and capacity type of the offering, so that an offering can be matched
+// against scheduling requirements as a unit rather than through the union of its instance type's offerings. Price
+// is not a label, so it's left to the instance type.
+func (o Offering) Requirements() scheduling.Requirements {
+	return scheduling.NewLabelRequirements(map[string]string{
+		v1.LabelTopologyZone:       o.Zone,
+		v1alpha5.LabelCapacityType: o.CapacityType,
+	})
 }
@@ -17,6 +17,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/rest"
@@ -24,6 +25,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/aws/karpenter/pkg/cloudprovider"
 )
 
 type GenericControllerManager struct {
@@ -59,3 +62,15 @@ func (m *GenericControllerManager) RegisterControllers(ctx context.Context, cont
 	}
 	return m
 }
+
+// Preloaded is a readiness check that fails until the cloud provider is done preloading
+func Preloaded(preloader cloudprovider.Preloader) healthz.Checker {
+	return func(_ *http.Request) error {
+		select {
+		case <-preloader.Preloaded():
+			return nil
+		default:
+			return fmt.Errorf("cloud provider is preloading")
+		}
+	}
+}
@@ -50,6 +50,10 @@ func (r *Initialization) Reconcile(ctx context.Context, provisioner *v1alpha5.Pr
 		return reconcile.Result{}, nil
 	}
 
+	// Feed the allocatable resources reported by kubelet back to the cloud provider to refine its estimates
+	if recorder, ok := instanceType.(cloudprovider.AllocatableRecorder); ok {
+		recorder.RecordAllocatable(n.Status.Allocatable)
+	}
 	n.Labels[v1alpha5.LabelNodeInitialized] = "true"
 	return reconcile.Result{}, nil
 }
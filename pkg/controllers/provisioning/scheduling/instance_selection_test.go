@@ -474,6 +474,34 @@ var _ = Describe("Instance Type Selection", func() {
 		ExpectNotScheduled(ctx, env.Client, pod[0])
 		Expect(cloudProv.CreateCalls).To(HaveLen(0))
 	})
+	It("should not schedule if no single offering matches both the zone and capacity type", func() {
+		// spot is only offered in zone-1 and on-demand only in zone-2, so the union of offerings covers spot in zone-2
+		cloudProv.InstanceTypes = []cloudprovider.InstanceType{
+			fake.NewInstanceType(fake.InstanceTypeOptions{
+				Name: "split-offerings",
+				Offerings: []cloudprovider.Offering{
+					{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1"},
+					{CapacityType: v1alpha1.CapacityTypeOnDemand, Zone: "test-zone-2"},
+				},
+			}),
+		}
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, controller, test.UnschedulablePod(
+			test.PodOptions{NodeRequirements: []v1.NodeSelectorRequirement{
+				{
+					Key:      v1alpha5.LabelCapacityType,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{v1alpha1.CapacityTypeSpot},
+				},
+				{
+					Key:      v1.LabelTopologyZone,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{"test-zone-2"},
+				},
+			}}))
+		ExpectNotScheduled(ctx, env.Client, pod[0])
+		Expect(cloudProv.CreateCalls).To(HaveLen(0))
+	})
 	It("should schedule on an instance with enough resources", func() {
 		// this is a pretty thorough exercise of scheduling, so we also check an invariant that scheduling doesn't
 		// modify the instance type's Overhead() or Resources() maps so they can return the same map every time instead
@@ -20,6 +20,7 @@ import (
 	"sync/atomic"
 
 	v1 "k8s.io/api/core/v1"
+	stringsets "k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/samber/lo"
 
@@ -131,15 +132,13 @@ func compatible(instanceType cloudprovider.InstanceType, requirements scheduling
 }
 
 func fits(instanceType cloudprovider.InstanceType, requests v1.ResourceList) bool {
-	fmt.Println(resources.Merge(requests, instanceType.Overhead()))
-	fmt.Println(instanceType.Resources())
 	return resources.Fits(resources.Merge(requests, instanceType.Overhead()), instanceType.Resources())
 }
 
 func hasOffering(instanceType cloudprovider.InstanceType, requirements scheduling.Requirements) bool {
+	keys := stringsets.NewString(v1.LabelTopologyZone, v1alpha5.LabelCapacityType)
 	for _, offering := range instanceType.Offerings() {
-		if (!requirements.Has(v1.LabelTopologyZone) || requirements.Get(v1.LabelTopologyZone).Has(offering.Zone)) &&
-			(!requirements.Has(v1alpha5.LabelCapacityType) || requirements.Get(v1alpha5.LabelCapacityType).Has(offering.CapacityType)) {
+		if offering.Requirements().Intersects(requirements, keys) == nil {
 			return true
 		}
 	}
@@ -35,8 +35,9 @@ import (
 
 func NewScheduler(nodeTemplates []*scheduling.NodeTemplate, provisioners []v1alpha5.Provisioner, cluster *state.Cluster, topology *Topology, instanceTypes map[string][]cloudprovider.InstanceType, daemonOverhead map[*scheduling.NodeTemplate]v1.ResourceList, recorder events.Recorder) *Scheduler {
 	for provisioner := range instanceTypes {
-		sort.Slice(instanceTypes[provisioner], func(i, j int) bool {
-			return instanceTypes[provisioner][i].Price() < instanceTypes[provisioner][j].Price()
+		// Stable, so that equally scored instance types keep the order chosen by the cloud provider
+		sort.SliceStable(instanceTypes[provisioner], func(i, j int) bool {
+			return score(instanceTypes[provisioner][i]) < score(instanceTypes[provisioner][j])
 		})
 	}
 	s := &Scheduler{
@@ -83,6 +84,14 @@ func NewScheduler(nodeTemplates []*scheduling.NodeTemplate, provisioners []v1alp
 	return s
 }
 
+// score ranks an instance type for selection, preferring its own score when it provides one over its price
+func score(instanceType cloudprovider.InstanceType) float64 {
+	if scored, ok := instanceType.(cloudprovider.Scored); ok {
+		return scored.Score()
+	}
+	return instanceType.Price()
+}
+
 type Scheduler struct {
 	nodes              []*Node
 	inflight           []*InFlightNode
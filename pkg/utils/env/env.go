@@ -17,6 +17,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // WithDefaultInt returns the int value of the supplied environment variable or, if not present,
@@ -56,3 +57,17 @@ func WithDefaultBool(key string, def bool) bool {
 	}
 	return parsedVal
 }
+
+// WithDefaultDuration returns the duration value of the supplied environment variable or, if not present,
+// the supplied default value. If the duration conversion fails, returns the default
+func WithDefaultDuration(key string, def time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsedVal, err := time.ParseDuration(val)
+	if err != nil {
+		return def
+	}
+	return parsedVal
+}
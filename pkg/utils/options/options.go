@@ -18,6 +18,7 @@ import (
 	"flag"
 	"fmt"
 	"net/url"
+	"time"
 
 	"go.uber.org/multierr"
 
@@ -45,6 +46,10 @@ func MustParse() Options {
 	flag.BoolVar(&opts.AWSENILimitedPodDensity, "aws-eni-limited-pod-density", env.WithDefaultBool("AWS_ENI_LIMITED_POD_DENSITY", true), "Indicates whether new nodes should use ENI-based pod density")
 	flag.StringVar(&opts.AWSDefaultInstanceProfile, "aws-default-instance-profile", env.WithDefaultString("AWS_DEFAULT_INSTANCE_PROFILE", ""), "The default instance profile to use when provisioning nodes in AWS")
 	flag.BoolVar(&opts.AWSEnablePodENI, "aws-enable-pod-eni", env.WithDefaultBool("AWS_ENABLE_POD_ENI", false), "If true then instances that support pod ENI will report a vpc.amazonaws.com/pod-eni resource")
+	flag.BoolVar(&opts.AWSEnableMemoryCorrection, "aws-enable-memory-correction", env.WithDefaultBool("AWS_ENABLE_MEMORY_CORRECTION", false), "If true then the memory of instance types is corrected using the allocatable memory reported by launched nodes, and corrections are persisted to the karpenter-memory-corrections config map")
+	flag.BoolVar(&opts.AWSPreloadInstanceTypes, "aws-preload-instance-types", env.WithDefaultBool("AWS_PRELOAD_INSTANCE_TYPES", false), "If true then the instance types of existing provisioners are computed at startup, before the first provisioning decision")
+	flag.DurationVar(&opts.AWSPriceRefreshInterval, "aws-price-refresh-interval", env.WithDefaultDuration("AWS_PRICE_REFRESH_INTERVAL", time.Hour), "The interval at which cached EC2 prices are refreshed")
+	flag.DurationVar(&opts.AWSSpotPriceMaxAge, "aws-spot-price-max-age", env.WithDefaultDuration("AWS_SPOT_PRICE_MAX_AGE", 6*time.Hour), "The age after which cached EC2 spot prices are no longer trusted and spot offerings are priced as on-demand, or 0 to always trust them")
 	flag.Parse()
 	if err := opts.Validate(); err != nil {
 		panic(err)
@@ -66,6 +71,10 @@ type Options struct {
 	AWSENILimitedPodDensity   bool
 	AWSDefaultInstanceProfile string
 	AWSEnablePodENI           bool
+	AWSEnableMemoryCorrection bool
+	AWSPreloadInstanceTypes   bool
+	AWSPriceRefreshInterval   time.Duration
+	AWSSpotPriceMaxAge        time.Duration
 }
 
 func (o Options) Validate() (err error) {